@@ -0,0 +1,47 @@
+// protoc-gen-stred imports the files passed by `protoc` into documents, and
+// reports as an error every file `protoc` accepts but the core rejects.
+//
+// usage: protoc --stred_out=. file.proto...
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/plugin"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-stred: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return err
+	}
+	res := &pluginpb.CodeGeneratorResponse{
+		// receive files with `optional` fields, so they can be reported
+		SupportedFeatures: proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)),
+	}
+	if _, err := plugin.Import(req); err != nil {
+		res.Error = proto.String(err.Error())
+	}
+	out, err := proto.Marshal(res)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
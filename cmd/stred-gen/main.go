@@ -0,0 +1,74 @@
+// stred-gen runs a `protoc` plugin on documents, without involving `protoc`.
+//
+// usage: stred-gen -plugin go [-I dir] [-param parameter] [-out dir] file.proto...
+//
+// files are parsed relative to the include directory, and files they import
+// are loaded from there, too. the plugin is either a path to an executable,
+// or a name such as `go`, which refers to `protoc-gen-go` on the search path.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/plugin"
+)
+
+func main() {
+	include := flag.String("I", ".", "directory to resolve file names in")
+	name := flag.String("plugin", "", "plugin name or path to plugin executable")
+	parameter := flag.String("param", "", "parameter passed to the plugin")
+	out := flag.String("out", ".", "output directory")
+	flag.Parse()
+	if *name == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*include, *name, *parameter, *out, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "stred-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(include, name, parameter, out string, files []string) error {
+	executable, err := lookupPlugin(name)
+	if err != nil {
+		return err
+	}
+	w := core.NewWorkspace()
+	open := func(path string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(include, filepath.FromSlash(path)))
+	}
+	generate := make([]string, len(files))
+	for i, f := range files {
+		generate[i] = filepath.ToSlash(filepath.Clean(f))
+		if _, err := w.Load(generate[i], open); err != nil {
+			return err
+		}
+	}
+	req, err := plugin.NewRequest(w, parameter, generate...)
+	if err != nil {
+		return err
+	}
+	res, err := plugin.Run(executable, req)
+	if err != nil {
+		return err
+	}
+	return plugin.WriteFiles(out, res)
+}
+
+func lookupPlugin(name string) (string, error) {
+	if strings.ContainsRune(name, os.PathSeparator) || strings.ContainsRune(name, '/') {
+		return name, nil
+	}
+	if !strings.HasPrefix(name, "protoc-gen-") {
+		name = "protoc-gen-" + name
+	}
+	return exec.LookPath(name)
+}
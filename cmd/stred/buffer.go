@@ -0,0 +1,100 @@
+package main
+
+import "strings"
+
+// style of a cell on screen
+type style int
+
+const (
+	plain style = iota
+	highlight
+	failure
+	status
+)
+
+type cell struct {
+	r     rune
+	style style
+}
+
+// buffer of cells making up the screen. the user interface draws into it,
+// and the terminal shows it, so it can be inspected without a terminal.
+type buffer struct {
+	width  int
+	height int
+	cells  []cell
+}
+
+func newBuffer(width, height int) *buffer {
+	b := &buffer{width: width, height: height, cells: make([]cell, width*height)}
+	b.clear()
+	return b
+}
+
+func (b *buffer) clear() {
+	for i := range b.cells {
+		b.cells[i] = cell{' ', plain}
+	}
+}
+
+// set a cell, ignoring positions outside of the buffer
+func (b *buffer) set(x, y int, r rune, s style) {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return
+	}
+	b.cells[y*b.width+x] = cell{r, s}
+}
+
+func (b *buffer) cell(x, y int) cell {
+	return b.cells[y*b.width+x]
+}
+
+// write text into a line, starting at a column, and return the column
+// following it
+func (b *buffer) write(x, y int, text string, s style) int {
+	for _, r := range text {
+		b.set(x, y, r, s)
+		x++
+	}
+	return x
+}
+
+// line of text, without trailing spaces
+func (b *buffer) line(y int) string {
+	runes := make([]rune, b.width)
+	for x := range runes {
+		runes[x] = b.cell(x, y).r
+	}
+	return strings.TrimRight(string(runes), " ")
+}
+
+// styled text of a line, with highlighted cells enclosed in brackets and
+// failures in braces
+func (b *buffer) styled(y int) string {
+	var out strings.Builder
+	current := plain
+	for x := 0; x < b.width; x++ {
+		c := b.cell(x, y)
+		if c.style != current {
+			out.WriteString(closing[current])
+			out.WriteString(opening[c.style])
+			current = c.style
+		}
+		out.WriteRune(c.r)
+	}
+	out.WriteString(closing[current])
+	return strings.TrimRight(out.String(), " ")
+}
+
+var (
+	opening = map[style]string{highlight: "[", failure: "{"}
+	closing = map[style]string{highlight: "]", failure: "}"}
+)
+
+func (b *buffer) String() string {
+	lines := make([]string, b.height)
+	for y := range lines {
+		lines[y] = b.line(y)
+	}
+	return strings.Join(lines, "\n")
+}
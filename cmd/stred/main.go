@@ -0,0 +1,64 @@
+// stred edits a document in the terminal, showing it as it will be written.
+//
+// usage: stred file.proto
+//
+// the file is created if it does not exist. files it imports are loaded from
+// its directory, and can be navigated into, but only the file itself is
+// written. keys are those of the editor
+// package, and in addition `^S` writes the file and `^Q` quits. errors of the
+// last key pressed are shown below the selected item.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: stred file.proto")
+		os.Exit(2)
+	}
+	if err := run(os.Args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "stred: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	d, err := load(path)
+	if err != nil {
+		return err
+	}
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	width, height, err := t.size()
+	if err != nil {
+		t.close()
+		return err
+	}
+	err = newUI(d, path).run(newBuffer(width, height), t.key, t.show)
+	if closeErr := t.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// load a document into a workspace rooted at its directory, such that the
+// files it imports are loaded from there
+func load(path string) (*core.Document, error) {
+	dir, name := filepath.Split(path)
+	w := core.NewWorkspace()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return w.NewDocument(name)
+	}
+	return w.Load(name, func(path string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(path)))
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/editor"
+)
+
+// terminal the user interface runs in. it is put into raw mode with `stty`,
+// such that keys arrive as they are pressed, and drawn to with ANSI escape
+// sequences.
+type terminal struct {
+	in    *bufio.Reader
+	out   *bufio.Writer
+	saved string
+}
+
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	t := &terminal{
+		in:    bufio.NewReader(os.Stdin),
+		out:   bufio.NewWriter(os.Stdout),
+		saved: strings.TrimSpace(saved),
+	}
+	// switch to the alternate screen and hide the cursor
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+	return t, t.out.Flush()
+}
+
+// close the terminal, restoring the screen and settings found before opening
+func (t *terminal) close() error {
+	fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+	if err := t.out.Flush(); err != nil {
+		return err
+	}
+	_, err := stty(t.saved)
+	return err
+}
+
+// size of the terminal in columns and rows
+func (t *terminal) size() (width, height int, err error) {
+	out, err := stty("size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(out, &height, &width); err != nil {
+		return 0, 0, fmt.Errorf("cannot determine terminal size: %w", err)
+	}
+	return width, height, nil
+}
+
+func (t *terminal) key() (editor.Key, error) {
+	r, _, err := t.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	// some terminals send `^H` for backspace
+	if r == '\b' {
+		return editor.Backspace, nil
+	}
+	return editor.Key(r), nil
+}
+
+var sequences = map[style]string{
+	plain:     "\x1b[0m",
+	highlight: "\x1b[0;7m",
+	failure:   "\x1b[0;31m",
+	status:    "\x1b[0;1m",
+}
+
+// show a buffer on the terminal
+func (t *terminal) show(b *buffer) error {
+	fmt.Fprint(t.out, "\x1b[H")
+	for y := 0; y < b.height; y++ {
+		current := style(-1)
+		for x := 0; x < b.width; x++ {
+			c := b.cell(x, y)
+			if c.style != current {
+				fmt.Fprint(t.out, sequences[c.style])
+				current = c.style
+			}
+			fmt.Fprint(t.out, string(c.r))
+		}
+		fmt.Fprint(t.out, sequences[plain])
+		if y < b.height-1 {
+			fmt.Fprint(t.out, "\r\n")
+		}
+	}
+	return t.out.Flush()
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	var out strings.Builder
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("stty %s: %w", strings.Join(args, " "), err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/editor"
+)
+
+// keys handled by the user interface instead of the editor
+const (
+	interrupt editor.Key = 0x03
+	quit      editor.Key = 0x11
+	save      editor.Key = 0x13
+)
+
+// ui shows a document being edited, and passes keys on to the editor
+type ui struct {
+	editor *editor.Editor
+	// document opened, which is written to path even if the cursor is in
+	// another one
+	document *core.Document
+	path     string
+	// err of the last key pressed, shown below the selected item
+	err error
+	// message shown in the status line
+	message string
+	// top line of the document on screen
+	top int
+}
+
+func newUI(d *core.Document, path string) *ui {
+	return &ui{editor: editor.New(d), document: d, path: path, message: path}
+}
+
+// run the user interface until the user quits, showing the screen before
+// reading each key
+func (u *ui) run(b *buffer, keys func() (editor.Key, error), show func(*buffer) error) error {
+	for {
+		u.draw(b)
+		if err := show(b); err != nil {
+			return err
+		}
+		k, err := keys()
+		if err != nil {
+			return err
+		}
+		if !u.press(k) {
+			return nil
+		}
+	}
+}
+
+// press a key, and return whether to continue
+func (u *ui) press(k editor.Key) bool {
+	switch k {
+	case quit, interrupt:
+		return false
+	case save:
+		u.err = nil
+		u.message = fmt.Sprintf("wrote %s", u.path)
+		if err := ioutil.WriteFile(u.path, []byte(u.document.String()+"\n"), 0644); err != nil {
+			u.message = err.Error()
+		}
+	default:
+		u.message = ""
+		u.err = u.editor.Press(k)
+	}
+	return true
+}
+
+// line of text on screen, with cells styled individually
+type line []cell
+
+func newLine(text string, s style) line {
+	out := make(line, 0, len(text))
+	for _, r := range text {
+		out = append(out, cell{r, s})
+	}
+	return out
+}
+
+// indented line of text, leaving the indentation unstyled
+func indented(indent int, text string, s style) line {
+	if text == "" {
+		return nil
+	}
+	return append(newLine(strings.Repeat(" ", indent), plain), newLine(text, s)...)
+}
+
+// draw the document and the status line into a buffer. the selected item is
+// highlighted, unless an item is being added, in which case that one is
+// shown in its place. errors are shown right below.
+func (u *ui) draw(b *buffer) {
+	b.clear()
+	lines, first, last := u.lines()
+	view := b.height - 1
+	if first < u.top {
+		u.top = first
+	}
+	if last >= u.top+view {
+		u.top = last - view + 1
+		if u.top > first {
+			u.top = first
+		}
+	}
+	for y := 0; y < view && u.top+y < len(lines); y++ {
+		for x, c := range lines[u.top+y] {
+			b.set(x, y, c.r, c.style)
+		}
+	}
+	u.status(b)
+}
+
+// lines of the document, and the range of lines to keep on screen
+func (u *ui) lines() (lines []line, first, last int) {
+	lines, first, last = highlighted(u.editor.Document(), u.editor.Cursor().Item())
+	if tentative, placement := u.editor.Tentative(); tentative != nil {
+		for _, l := range lines[first : last+1] {
+			for x := range l {
+				l[x].style = plain
+			}
+		}
+		lines, first, last = u.insert(lines, first, last, tentative, placement)
+	}
+	if u.err != nil {
+		message := indented(indentation(lines[first]), fmt.Sprint("^ ", u.err), failure)
+		lines = append(lines[:last+1], append([]line{message}, lines[last+1:]...)...)
+		last++
+	}
+	return lines, first, last
+}
+
+// insert the lines of a tentative item relative to the selected one, which
+// spans from the first to the last line given
+func (u *ui) insert(lines []line, first, last int, tentative interface{}, placement editor.Placement) ([]line, int, int) {
+	indent := indentation(lines[first])
+	at := last + 1
+	switch placement {
+	case editor.Above:
+		at = first
+	case editor.Inside:
+		if _, ok := u.editor.Cursor().Item().(*core.Document); ok {
+			indent, at = 0, len(lines)
+			break
+		}
+		indent += len(core.DefaultPrinter.Indent)
+		if last > first {
+			at = last
+		}
+	}
+	var item []line
+	for _, l := range strings.Split(fmt.Sprint(tentative), "\n") {
+		item = append(item, indented(indent, l, highlight))
+	}
+	// definitions are separated by empty lines
+	var before, after []line
+	switch tentative.(type) {
+	case *core.NewMessage, *core.NewEnum:
+		if placement == editor.Above {
+			after = []line{nil}
+		} else {
+			before = []line{nil}
+		}
+	}
+	out := make([]line, 0, len(lines)+len(item)+1)
+	out = append(out, lines[:at]...)
+	out = append(out, before...)
+	out = append(out, item...)
+	out = append(out, after...)
+	out = append(out, lines[at:]...)
+	start := at + len(before)
+	return out, start, start + len(item) - 1
+}
+
+// status line, with the path or a message in normal mode, and the pending
+// input or choices otherwise
+func (u *ui) status(b *buffer) {
+	y := b.height - 1
+	for x := 0; x < b.width; x++ {
+		b.set(x, y, ' ', status)
+	}
+	e := u.editor
+	switch e.Mode() {
+	case editor.Normal:
+		b.write(0, y, u.message, status)
+	case editor.Insert:
+		x := b.write(0, y, fmt.Sprintf("%s: %s", e.Prompt(), e.Input()), status)
+		b.set(x, y, ' ', highlight)
+	case editor.Select:
+		x := b.write(0, y, fmt.Sprintf("%s:", e.Prompt()), status)
+		choices, selected := e.Choices()
+		// skip choices until the selected one fits
+		start := 0
+		for width(x, choices[start:selected+1]) > b.width {
+			start++
+		}
+		for i := start; i < len(choices); i++ {
+			x = b.write(x, y, " ", status)
+			s := status
+			if i == selected {
+				s = highlight
+			}
+			x = b.write(x, y, choices[i], s)
+		}
+	}
+}
+
+// width of a line with choices following a column
+func width(x int, choices []string) int {
+	for _, c := range choices {
+		x += 1 + len([]rune(c))
+	}
+	return x
+}
+
+func indentation(l line) int {
+	for i, c := range l {
+		if c.r != ' ' {
+			return i
+		}
+	}
+	return len(l)
+}
+
+// markers enclosing the highlighted item in printer output. they are taken
+// from the private use area of Unicode, so they cannot clash with the text of
+// a document.
+const (
+	begin = '\uE000'
+	end   = '\uE001'
+)
+
+// highlighted lines of a document, and the first and last line containing
+// the selected item
+func highlighted(d *core.Document, selected interface{}) (lines []line, first, last int) {
+	printer := d.Printer
+	h := highlighter{printer, selected}
+	if p, ok := printer.(core.Print); ok {
+		// resolve type references once for the whole document
+		h.Printer = p.Resolving(d)
+	}
+	d.Printer = h
+	text := d.String()
+	d.Printer = printer
+
+	first, last = -1, -1
+	current := plain
+	for i, l := range strings.Split(text, "\n") {
+		out := make(line, 0, len(l))
+		for _, r := range l {
+			switch r {
+			case begin:
+				current = highlight
+				first = i
+			case end:
+				current = plain
+				last = i
+			default:
+				out = append(out, cell{r, current})
+			}
+		}
+		lines = append(lines, out)
+	}
+	if first < 0 {
+		return lines, 0, 0
+	}
+	return lines, first, last
+}
+
+// highlighter marks the output of a printer for the selected item
+type highlighter struct {
+	core.Printer
+	selected interface{}
+}
+
+func (h highlighter) mark(item interface{}, text string) string {
+	if item != h.selected {
+		return text
+	}
+	return fmt.Sprint(string(begin), text, string(end))
+}
+
+func (h highlighter) Document(d *core.Document) string {
+	return h.mark(d, h.Printer.Document(d))
+}
+
+func (h highlighter) Package(p *core.Package) string {
+	return h.mark(p, h.Printer.Package(p))
+}
+
+func (h highlighter) Import(i *core.Import) string {
+	return h.mark(i, h.Printer.Import(i))
+}
+
+func (h highlighter) Service(s *core.Service) string {
+	return h.mark(s, h.Printer.Service(s))
+}
+
+func (h highlighter) RPC(r *core.RPC) string {
+	return h.mark(r, h.Printer.RPC(r))
+}
+
+func (h highlighter) Message(m core.Message) string {
+	return h.mark(m, h.Printer.Message(m))
+}
+
+func (h highlighter) Field(f *core.Field) string {
+	return h.mark(f, h.Printer.Field(f))
+}
+
+func (h highlighter) Map(m *core.Map) string {
+	return h.mark(m, h.Printer.Map(m))
+}
+
+func (h highlighter) OneOf(o *core.OneOf) string {
+	return h.mark(o, h.Printer.OneOf(o))
+}
+
+func (h highlighter) OneOfField(f *core.OneOfField) string {
+	return h.mark(f, h.Printer.OneOfField(f))
+}
+
+func (h highlighter) Enum(e core.Enum) string {
+	return h.mark(e, h.Printer.Enum(e))
+}
+
+func (h highlighter) Variant(v *core.Variant) string {
+	return h.mark(v, h.Printer.Variant(v))
+}
+
+func (h highlighter) ReservedNumber(r *core.ReservedNumber) string {
+	return h.mark(r, h.Printer.ReservedNumber(r))
+}
+
+func (h highlighter) ReservedRange(r *core.ReservedRange) string {
+	return h.mark(r, h.Printer.ReservedRange(r))
+}
+
+func (h highlighter) ReservedLabel(r *core.ReservedLabel) string {
+	return h.mark(r, h.Printer.ReservedLabel(r))
+}
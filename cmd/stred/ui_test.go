@@ -0,0 +1,206 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/editor"
+)
+
+const document = `syntax = "proto3";
+
+message A {
+  string foo = 1;
+}`
+
+func newTestUI(t *testing.T, input string) *ui {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	return newUI(d, "test.proto")
+}
+
+// screen after pressing keys, with highlights in brackets and errors in braces
+func screen(t *testing.T, u *ui, script string, width, height int) []string {
+	keys, err := editor.ParseKeys(script)
+	require.Nil(t, err)
+	for _, k := range keys {
+		require.True(t, u.press(k))
+	}
+	b := newBuffer(width, height)
+	u.draw(b)
+	lines := make([]string, height)
+	for y := range lines {
+		lines[y] = b.styled(y)
+	}
+	return lines
+}
+
+func TestHighlight(t *testing.T) {
+	u := newTestUI(t, document)
+	assert.Equal(t, []string{
+		`[syntax = "proto3";]`,
+		"",
+		"[message A {]",
+		"[  string foo = 1;]",
+		"[}]",
+		"test.proto",
+	}, screen(t, u, "", 30, 6), "document is selected")
+	assert.Equal(t, []string{
+		`syntax = "proto3";`,
+		"",
+		"message A {",
+		"  [string foo = 1;]",
+		"}",
+		"",
+	}, screen(t, u, "ll", 30, 6))
+}
+
+func TestTentative(t *testing.T) {
+	u := newTestUI(t, document)
+	assert.Equal(t, []string{
+		`syntax = "proto3";`,
+		"",
+		"message A {",
+		"  string foo = 1;",
+		"  [█ █ = █;]",
+		"}",
+		"label: [ ]",
+	}, screen(t, u, "llo", 30, 7), "unset values are blank")
+	assert.Equal(t, []string{
+		`syntax = "proto3";`,
+		"",
+		"message A {",
+		"  string foo = 1;",
+		"  [█ bar = █;]",
+		"  {^ field number 1 conflicts w}",
+		"}",
+		"number: 1[ ]",
+	}, screen(t, u, "bar<Enter>1<Enter>", 30, 8), "errors are shown inline")
+	lines := screen(t, u, "<BS>2<Enter>", 30, 8)
+	assert.Equal(t, "  [█ bar = 2;]", lines[4], "error is cleared")
+	assert.Equal(t, "type: [double] float int32 int64", lines[7])
+	lines = screen(t, u, "jjjjjjjjjjjjj", 30, 8)
+	assert.Equal(t, "type: sfixed64 bool [string] byt", lines[7], "selected choice is shown")
+	assert.Equal(t, []string{
+		`syntax = "proto3";`,
+		"",
+		"message A {",
+		"  string foo = 1;",
+		"  [string bar = 2;]",
+		"}",
+		"",
+		"",
+	}, screen(t, u, "<Enter>", 30, 8))
+
+	assert.Equal(t, []string{
+		`syntax = "proto3";`,
+		"",
+		"[message █ {}]",
+		"",
+		"message A {",
+		"  string foo = 1;",
+		"  string bar = 2;",
+		"}",
+		"label: [ ]",
+	}, screen(t, u, "hO", 30, 9), "definitions are separated")
+	assert.Equal(t, "[message A {]", screen(t, u, "<Esc>", 30, 9)[2], "cancelled item is discarded")
+}
+
+func TestScroll(t *testing.T) {
+	u := newTestUI(t, `syntax = "proto3";
+
+message A {
+  string a = 1;
+  string b = 2;
+  string c = 3;
+  string d = 4;
+}`)
+	assert.Equal(t, []string{
+		"  string b = 2;",
+		"  [string c = 3;]",
+		"",
+	}, screen(t, u, "lljj", 20, 3))
+	assert.Equal(t, []string{
+		"  [string d = 4;]",
+		"  {^ cannot move there}",
+		"",
+	}, screen(t, u, "jj", 25, 3))
+	assert.Equal(t, []string{
+		"[message A {]",
+		"[  string a = 1;]",
+		"",
+	}, screen(t, u, "h", 25, 3), "top of the item stays visible")
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stred")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.proto")
+
+	u := newTestUI(t, document)
+	u.path = path
+	keys, err := editor.ParseKeys("llrbar<Enter>\x13x\x11")
+	require.Nil(t, err)
+	shown := 0
+	err = u.run(newBuffer(30, 6),
+		func() (editor.Key, error) {
+			k := keys[0]
+			keys = keys[1:]
+			return k, nil
+		},
+		func(b *buffer) error {
+			shown++
+			return nil
+		})
+	require.Nil(t, err)
+	assert.Empty(t, keys, "quit after the last key")
+	assert.Equal(t, 10, shown, "screen is shown before each key")
+	written, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, `syntax = "proto3";
+
+message A {
+  string bar = 1;
+}
+`, string(written), "changes after saving are not written")
+}
+
+func TestLoadImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stred")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a.proto"), []byte(`syntax = "proto3";
+message A {}
+`), 0644))
+	b := `syntax = "proto3";
+
+import "a.proto";
+
+message B {
+  A a = 1;
+}
+`
+	path := filepath.Join(dir, "b.proto")
+	require.Nil(t, ioutil.WriteFile(path, []byte(b), 0644))
+
+	d, err := load(path)
+	require.Nil(t, err, "imported types are resolved")
+	u := newUI(d, path)
+	lines := screen(t, u, "ljlg\x13", 30, 6)
+	assert.Equal(t, "[message A {}]", lines[2], "cursor is in a.proto")
+	written, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, b, string(written), "the opened document is written")
+
+	d, err = load(filepath.Join(dir, "c.proto"))
+	require.Nil(t, err)
+	assert.Empty(t, d.Messages(), "missing files are created")
+}
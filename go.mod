@@ -2,4 +2,7 @@ module github.com/fricklerhandwerk/stred-proto
 
 go 1.13
 
-require github.com/stretchr/testify v1.5.1
+require (
+	github.com/stretchr/testify v1.5.1
+	google.golang.org/protobuf v1.27.1
+)
@@ -0,0 +1,451 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// building a document from the syntax tree happens in two passes: first all
+// messages, enums and services are declared, such that in the second pass
+// type references can be resolved regardless of declaration order.
+
+func (f *syntaxFile) build(d *Document) error {
+	d.comments = f.comments
+	if f._package != nil {
+		if err := d.Package().Set(f._package.text); err != nil {
+			return f._package.wrap(err)
+		}
+		d._package.comments = f.packageComments
+	}
+	for _, i := range f.imports {
+		if err := i.build(d); err != nil {
+			return err
+		}
+	}
+	if err := setOptions(f.options, map[string]*Flag{"deprecated": d.Deprecated()}, d.Options().List()); err != nil {
+		return err
+	}
+	if err := declare(d, f.definitions); err != nil {
+		return err
+	}
+	if err := define(d, d.symbols(), f.definitions); err != nil {
+		return err
+	}
+	// loading the document is not an edit which can be undone
+	d.ClearHistory()
+	return nil
+}
+
+func (i *syntaxImport) build(d *Document) error {
+	n := d.NewImport()
+	if err := n.Path().Set(i.path.text); err != nil {
+		return i.path.wrap(err)
+	}
+	if err := n.Public().Set(i.public); err != nil {
+		return i.path.wrap(err)
+	}
+	n.comments = i.comments
+	return i.path.wrap(n.InsertIntoParent())
+}
+
+func declare(c DefinitionContainer, definitions []interface{}) error {
+	for _, definition := range definitions {
+		switch v := definition.(type) {
+		case *syntaxMessage:
+			n := c.NewMessage()
+			if err := n.Label().Set(v.label.text); err != nil {
+				return v.label.wrap(err)
+			}
+			if err := n.InsertIntoParent(); err != nil {
+				return v.label.wrap(err)
+			}
+			v.message = FindMessage(c, v.label.text)
+			*v.message.Comments() = v.comments
+			if err := declare(v.message, v.children); err != nil {
+				return err
+			}
+		case *syntaxEnum:
+			n := c.NewEnum()
+			if err := n.Label().Set(v.label.text); err != nil {
+				return v.label.wrap(err)
+			}
+			if err := n.InsertIntoParent(); err != nil {
+				return v.label.wrap(err)
+			}
+			v.enum = FindEnum(c, v.label.text)
+			*v.enum.Comments() = v.comments
+		case *syntaxService:
+			d := c.(*Document)
+			s := d.NewService()
+			if err := s.Label().Set(v.label.text); err != nil {
+				return v.label.wrap(err)
+			}
+			if err := s.InsertIntoParent(); err != nil {
+				return v.label.wrap(err)
+			}
+			s.comments = v.comments
+			v.service = s
+		}
+	}
+	return nil
+}
+
+func define(c DefinitionContainer, s symbols, definitions []interface{}) error {
+	scope := fullName(c)
+	for _, definition := range definitions {
+		var err error
+		switch v := definition.(type) {
+		case *syntaxMessage:
+			err = v.define(s)
+		case *syntaxEnum:
+			err = v.define()
+		case *syntaxService:
+			err = v.define(s, scope)
+		case *syntaxField:
+			err = v.define(c.(Message), s, scope)
+		case *syntaxMap:
+			err = v.define(c.(Message), s, scope)
+		case *syntaxOneOf:
+			err = v.define(c.(Message), s, scope)
+		case *syntaxReserved:
+			err = v.define(c.(Message))
+		default:
+			panic(fmt.Sprintf("unhandled definition type %T", v))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *syntaxMessage) define(s symbols) error {
+	if err := setOptions(m.options, map[string]*Flag{"deprecated": m.message.Deprecated()}, m.message.Options().List()); err != nil {
+		return err
+	}
+	return define(m.message, s, m.children)
+}
+
+func (f *syntaxField) define(m Message, s symbols, scope string) error {
+	n := m.NewField()
+	if err := f.build(&n.field, n.Type(), s, scope); err != nil {
+		return err
+	}
+	if err := n.Repeated().Set(f.repeated); err != nil {
+		return f.label.wrap(err)
+	}
+	// options last, since they may depend on type and repetition
+	if err := setOptions(f.options, map[string]*Flag{"deprecated": n.Deprecated()}, n.Options().List()); err != nil {
+		return err
+	}
+	return f.label.wrap(n.InsertIntoParent())
+}
+
+// build the attributes shared between message fields and one-of fields
+func (f *syntaxField) build(field *field, t *Type, s symbols, scope string) error {
+	field.comments = f.comments
+	if err := field.label.Set(f.label.text); err != nil {
+		return f.label.wrap(err)
+	}
+	if err := setNumber(&field.number, f.number); err != nil {
+		return err
+	}
+	return setType(t, f._type, s, scope)
+}
+
+func (f *syntaxMap) define(m Message, s symbols, scope string) error {
+	n := m.NewMap()
+	n.comments = f.comments
+	if err := n.Label().Set(f.label.text); err != nil {
+		return f.label.wrap(err)
+	}
+	if err := setNumber(n.Number(), f.number); err != nil {
+		return err
+	}
+	key, ok := scalarTypes[f.keyType.text].(MapKeyType)
+	if !ok {
+		return f.keyType.errorf("invalid map key type %s", f.keyType)
+	}
+	if err := n.KeyType().Set(key); err != nil {
+		return f.keyType.wrap(err)
+	}
+	if err := setType(n.Type(), f._type, s, scope); err != nil {
+		return err
+	}
+	if err := setOptions(f.options, map[string]*Flag{"deprecated": n.Deprecated()}, n.Options().List()); err != nil {
+		return err
+	}
+	return f.label.wrap(n.InsertIntoParent())
+}
+
+func (o *syntaxOneOf) define(m Message, s symbols, scope string) error {
+	n := m.NewOneOf()
+	n.comments = o.comments
+	if err := n.Label().Set(o.label.text); err != nil {
+		return o.label.wrap(err)
+	}
+	if len(o.options) > 0 {
+		return unsupportedOption(o.options[0])
+	}
+	for _, f := range o.fields {
+		if f.repeated {
+			return f._type.errorf("repeated fields are not allowed in oneof")
+		}
+		v := n.NewField()
+		if err := f.build(&v.field, v.Type(), s, scope); err != nil {
+			return err
+		}
+		if err := setOptions(f.options, map[string]*Flag{"deprecated": v.Deprecated()}, v.Options().List()); err != nil {
+			return err
+		}
+		if err := v.InsertIntoParent(); err != nil {
+			return f.label.wrap(err)
+		}
+	}
+	return o.label.wrap(n.InsertIntoParent())
+}
+
+func (r *syntaxReserved) define(d Definition) error {
+	for _, rng := range r.ranges {
+		if rng.end == nil || rng.end.text == rng.start.text {
+			n := d.NewReservedNumber()
+			if err := setNumber(&n.number, rng.start); err != nil {
+				return err
+			}
+			if err := rng.start.wrap(n.InsertIntoParent()); err != nil {
+				return err
+			}
+			continue
+		}
+		n := d.NewReservedRange()
+		if err := setNumber(n.Start(), rng.start); err != nil {
+			return err
+		}
+		end := *rng.end
+		if end.text == "max" {
+			end.text = fmt.Sprint(maxNumber(d))
+		}
+		if err := setNumber(n.End(), end); err != nil {
+			return err
+		}
+		if err := rng.start.wrap(n.InsertIntoParent()); err != nil {
+			return err
+		}
+	}
+	for _, l := range r.labels {
+		n := d.NewReservedLabel()
+		if err := n.Set(l.text); err != nil {
+			return l.wrap(err)
+		}
+		if err := l.wrap(n.InsertIntoParent()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxNumber is the value of `max` in reserved ranges
+func maxNumber(d Definition) int32 {
+	switch d.(type) {
+	case Enum:
+		return math.MaxInt32
+	default:
+		return maxFieldNumber
+	}
+}
+
+func (e *syntaxEnum) define() error {
+	// options first, since `allow_alias` must be in place before inserting
+	// aliased variants
+	flags := map[string]*Flag{
+		"allow_alias": e.enum.AllowAlias(),
+		"deprecated":  e.enum.Deprecated(),
+	}
+	if err := setOptions(e.options, flags, nil); err != nil {
+		return err
+	}
+	for _, child := range e.children {
+		switch v := child.(type) {
+		case *syntaxVariant:
+			n := e.enum.NewVariant()
+			n.comments = v.comments
+			if err := n.Label().Set(v.label.text); err != nil {
+				return v.label.wrap(err)
+			}
+			if err := setNumber(n.Number(), v.number); err != nil {
+				return err
+			}
+			if err := setOptions(v.options, map[string]*Flag{"deprecated": n.Deprecated()}, nil); err != nil {
+				return err
+			}
+			if err := v.label.wrap(n.InsertIntoParent()); err != nil {
+				return err
+			}
+		case *syntaxReserved:
+			if err := v.define(e.enum); err != nil {
+				return err
+			}
+		default:
+			panic(fmt.Sprintf("unhandled enum field type %T", v))
+		}
+	}
+	return nil
+}
+
+func (s *syntaxService) define(symbols symbols, scope string) error {
+	if err := setOptions(s.options, map[string]*Flag{"deprecated": s.service.Deprecated()}, nil); err != nil {
+		return err
+	}
+	scope = qualify(scope, s.label.text)
+	for _, r := range s.rpcs {
+		n := s.service.NewRPC()
+		n.comments = r.comments
+		if err := n.Label().Set(r.label.text); err != nil {
+			return r.label.wrap(err)
+		}
+		if err := setMessageType(n.Request(), r.request, r.requestStream, symbols, scope); err != nil {
+			return err
+		}
+		if err := setMessageType(n.Response(), r.response, r.responseStream, symbols, scope); err != nil {
+			return err
+		}
+		if err := setOptions(r.options, map[string]*Flag{"deprecated": n.Deprecated()}, n.Options().List()); err != nil {
+			return err
+		}
+		if err := r.label.wrap(n.InsertIntoParent()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setNumber(n *Number, t token) error {
+	magnitude, err := parseInteger(strings.TrimPrefix(t.text, "-"), 64)
+	value := int64(magnitude)
+	if strings.HasPrefix(t.text, "-") {
+		value = -value
+	}
+	if err == nil && (magnitude > 1<<31 || value > math.MaxInt32) {
+		err = strconv.ErrRange
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return t.errorf("number %s out of range: must fit into 32 bits", t)
+	}
+	if err != nil {
+		return t.errorf("invalid number %s", t)
+	}
+	return t.wrap(n.Set(int32(value)))
+}
+
+func setType(t *Type, name token, s symbols, scope string) error {
+	value, ok := scalarTypes[name.text]
+	if !ok {
+		value = s.lookup(scope, name.text)
+	}
+	if value == nil {
+		return name.errorf("unknown type %s", name)
+	}
+	return name.wrap(t.Set(value))
+}
+
+func setMessageType(m *MessageType, name token, stream bool, s symbols, scope string) error {
+	value, ok := s.lookup(scope, name.text).(Message)
+	if !ok {
+		return name.errorf("unknown message type %s", name)
+	}
+	if err := m.Set(value); err != nil {
+		return name.wrap(err)
+	}
+	return name.wrap(m.Stream().Set(stream))
+}
+
+// setOptions of an item from its declarations. `flags` are the options
+// modelled as flags on the item itself.
+func setOptions(options []*syntaxOption, flags map[string]*Flag, typed []Option) error {
+	seen := make(map[string]bool, len(options))
+	for _, o := range options {
+		if seen[o.label.text] {
+			return o.label.errorf("option %s already set", o.label.text)
+		}
+		seen[o.label.text] = true
+		if f, ok := flags[o.label.text]; ok {
+			value, err := boolOption(o)
+			if err != nil {
+				return err
+			}
+			if err := f.Set(value); err != nil {
+				return o.label.wrap(err)
+			}
+			continue
+		}
+		if err := setOption(o, typed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setOption(o *syntaxOption, options []Option) error {
+	for _, option := range options {
+		if option.Name() != o.label.text {
+			continue
+		}
+		switch v := option.(type) {
+		case *BoolOption:
+			value, err := boolOption(o)
+			if err != nil {
+				return err
+			}
+			return o.label.wrap(v.Set(value))
+		case *StringOption:
+			if o.value.kind != tokenString {
+				return o.value.errorf("option %s must be a string", o.label.text)
+			}
+			return o.label.wrap(v.Set(o.value.text))
+		case *EnumOption:
+			if o.value.kind != tokenIdentifier {
+				return o.value.errorf("option %s must be an identifier", o.label.text)
+			}
+			return o.value.wrap(v.Set(o.value.text))
+		default:
+			panic(fmt.Sprintf("unhandled option type %T", v))
+		}
+	}
+	return unsupportedOption(o)
+}
+
+func boolOption(o *syntaxOption) (bool, error) {
+	switch {
+	case o.value.kind == tokenIdentifier && o.value.text == "true":
+		return true, nil
+	case o.value.kind == tokenIdentifier && o.value.text == "false":
+		return false, nil
+	default:
+		return false, o.value.errorf("option %s must be %q or %q", o.label.text, "true", "false")
+	}
+}
+
+func unsupportedOption(o *syntaxOption) error {
+	return o.label.errorf("unsupported option %s", o.label.text)
+}
+
+var scalarTypes = map[string]ValueType{
+	string(Double):   Double,
+	string(Float):    Float,
+	string(Int32):    Int32,
+	string(Int64):    Int64,
+	string(Uint32):   Uint32,
+	string(Uint64):   Uint64,
+	string(Sint32):   Sint32,
+	string(Sint64):   Sint64,
+	string(Fixed32):  Fixed32,
+	string(Fixed64):  Fixed64,
+	string(Sfixed32): Sfixed32,
+	string(Sfixed64): Sfixed64,
+	string(Bool):     Bool,
+	string(String):   String,
+	string(Bytes):    Bytes,
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// Comments attached to an item, in the sense of `protoc`: the leading comment
+// immediately precedes the item, detached comments precede the leading one
+// and are separated from it and from each other by blank lines, and the
+// trailing comment follows the item on the same line. for items with a
+// block, the trailing comment follows the opening brace.
+//
+// comment text is stored without comment markers, and lines are separated by
+// newlines.
+type Comments struct {
+	leading  string
+	trailing string
+	detached []string
+}
+
+func (c Comments) Leading() string {
+	return c.leading
+}
+
+func (c *Comments) SetLeading(text string) {
+	c.leading = text
+}
+
+func (c Comments) Trailing() string {
+	return c.trailing
+}
+
+// SetTrailing comment, which must fit on a single line.
+func (c *Comments) SetTrailing(text string) error {
+	if strings.Contains(text, "\n") {
+		return errors.New("trailing comment must be a single line")
+	}
+	c.trailing = text
+	return nil
+}
+
+func (c Comments) Detached() []string {
+	out := make([]string, len(c.detached))
+	copy(out, c.detached)
+	return out
+}
+
+func (c *Comments) SetDetached(texts []string) {
+	c.detached = make([]string, len(texts))
+	copy(c.detached, texts)
+}
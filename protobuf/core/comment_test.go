@@ -0,0 +1,109 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentsRoundTrip(t *testing.T) {
+	input := `// license header
+
+// about this file
+syntax = "proto3"; // proto3 only
+
+// the package
+package pkg; // trailing package
+
+// first import
+import "a.proto"; // trailing import
+
+// the service
+//
+// spans multiple lines
+service S { // trailing service
+  // get an item
+  rpc Get (M) returns (M); // trailing rpc
+}
+
+enum E { // trailing enum
+  // detached in enum
+
+  // zero
+  ZERO = 0; // trailing variant
+}
+
+// a message
+message M {
+  // detached in message
+
+  // the id
+  int32 id = 1; // trailing field
+  map <string,string> labels = 2; // trailing map
+  // a choice
+  oneof choice { // trailing oneof
+    // in oneof
+    string name = 3; // trailing oneof field
+  }
+
+  // nested
+  message Nested { // trailing nested
+  }
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, input, d.String())
+
+	assert.Equal(t, []string{"license header"}, d.Comments().Detached())
+	assert.Equal(t, "about this file", d.Comments().Leading())
+	assert.Equal(t, "proto3 only", d.Comments().Trailing())
+	assert.Equal(t, "the package", d.Package().Comments().Leading())
+	assert.Equal(t, "first import", d.Imports()[0].Comments().Leading())
+	s := d.Services()[0]
+	assert.Equal(t, "the service\n\nspans multiple lines", s.Comments().Leading())
+	assert.Equal(t, "trailing rpc", s.RPCs()[0].Comments().Trailing())
+	m := FindMessage(d, "M")
+	id := m.Fields()[0].(*Field)
+	assert.Equal(t, []string{"detached in message"}, id.Comments().Detached())
+	assert.Equal(t, "the id", id.Comments().Leading())
+	assert.Equal(t, "trailing field", id.Comments().Trailing())
+	assert.Equal(t, "trailing map", m.Fields()[1].(*Map).Comments().Trailing())
+	o := m.Fields()[2].(*OneOf)
+	assert.Equal(t, "a choice", o.Comments().Leading())
+	assert.Equal(t, "in oneof", o.Fields()[0].Comments().Leading())
+	variant := FindEnum(d, "E").Fields()[0].(*Variant)
+	assert.Equal(t, "zero", variant.Comments().Leading())
+}
+
+func TestBlockComments(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+/*
+ * a message
+ * with a block comment
+ */
+message M {
+  int32 id = 1; /* trailing */
+  /* leading */ int32 other = 2;
+}
+`))
+	require.Nil(t, err)
+	m := FindMessage(d, "M")
+	assert.Equal(t, "a message\nwith a block comment", m.Comments().Leading())
+	assert.Equal(t, "trailing", m.Fields()[0].(*Field).Comments().Trailing())
+	assert.Equal(t, "leading", m.Fields()[1].(*Field).Comments().Leading())
+}
+
+func TestSetComments(t *testing.T) {
+	d := NewDocument()
+	n := d.NewMessage()
+	require.Nil(t, n.Label().Set("M"))
+	require.Nil(t, n.InsertIntoParent())
+	m := FindMessage(d, "M")
+	m.Comments().SetLeading("leading\n\nparagraph")
+	assert.NotNil(t, m.Comments().SetTrailing("two\nlines"))
+	assert.Nil(t, m.Comments().SetTrailing("trailing"))
+	m.Comments().SetDetached([]string{"detached"})
+	assert.Equal(t, "// detached\n\n// leading\n//\n// paragraph\nmessage M { // trailing\n}", m.String())
+}
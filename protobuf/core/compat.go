@@ -0,0 +1,418 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// compatibility is checked by matching items of two versions of a document:
+// definitions and services by their names relative to the package, fields and
+// variants by number, and RPCs by name. renaming a definition therefore looks
+// like removing it and adding another one.
+
+// Breakage tells which consumers of a document are affected by a change
+type Breakage uint
+
+const (
+	// BreaksWire means that the binary encoding of one version cannot be read
+	// correctly by the other one
+	BreaksWire Breakage = 1 << iota
+	// BreaksJSON means the same for the canonical JSON mapping
+	BreaksJSON
+	// BreaksSource means that code written against the generated code of one
+	// version may not compile against the other one
+	BreaksSource
+)
+
+func (b Breakage) String() string {
+	names := make([]string, 0, 3)
+	if b&BreaksWire != 0 {
+		names = append(names, "wire")
+	}
+	if b&BreaksJSON != 0 {
+		names = append(names, "JSON")
+	}
+	if b&BreaksSource != 0 {
+		names = append(names, "source")
+	}
+	return strings.Join(names, ", ")
+}
+
+// Incompatibility between two versions of a document. `Old` is the affected
+// item in the old version and `New` the corresponding item in the new one. if
+// the item was removed, `New` is the parent it was removed from.
+type Incompatibility struct {
+	Old    interface{}
+	New    interface{}
+	Breaks Breakage
+	Reason string
+}
+
+func (i Incompatibility) String() string {
+	return fmt.Sprintf("%s (breaks %s)", i.Reason, i.Breaks)
+}
+
+// CompareCompatibility lists the changes from `old` to `new` which break
+// consumers of the old version, in the order of declarations in `old`.
+func CompareCompatibility(old, new *Document) []Incompatibility {
+	c := &compatibility{old: old, new: new}
+	if o, n := old._package.label.value, new._package.label.value; o != n {
+		c.report(old, new, BreaksWire|BreaksSource, "package changed from %q to %q", o, n)
+	}
+	for _, s := range old.services {
+		t := findService(new, s.label.value)
+		if t == nil {
+			c.report(s, new, BreaksWire|BreaksSource, "service %s removed", s.label.value)
+			continue
+		}
+		c.service(s, t)
+	}
+	c.definitions(old, new, "")
+	return c.out
+}
+
+type compatibility struct {
+	old, new *Document
+	out      []Incompatibility
+}
+
+func (c *compatibility) report(old, new interface{}, breaks Breakage, format string, args ...interface{}) {
+	c.out = append(c.out, Incompatibility{old, new, breaks, fmt.Sprintf(format, args...)})
+}
+
+func findService(d *Document, label string) *Service {
+	for _, s := range d.services {
+		if s.label.value == label {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *compatibility) service(old, new *Service) {
+	for _, r := range old.rpcs {
+		name := qualify(old.label.value, r.label.value)
+		var s *RPC
+		for _, t := range new.rpcs {
+			if t.label.value == r.label.value {
+				s = t
+			}
+		}
+		if s == nil {
+			c.report(r, new, BreaksWire|BreaksSource, "rpc %s removed", name)
+			continue
+		}
+		c.messageType(name, "request", &r.request, &s.request)
+		c.messageType(name, "response", &r.response, &s.response)
+	}
+}
+
+func (c *compatibility) messageType(rpc, kind string, old, new *MessageType) {
+	if o, n := typeName(old.value, c.old), typeName(new.value, c.new); o != n {
+		c.report(old.parent, new.parent, BreaksWire|BreaksJSON|BreaksSource, "%s of rpc %s changed from %s to %s", kind, rpc, o, n)
+	}
+	if old.stream.value != new.stream.value {
+		c.report(old.parent, new.parent, BreaksWire|BreaksSource, "%s of rpc %s changed from %s to %s", kind, rpc, streaming(old), streaming(new))
+	}
+}
+
+func streaming(m *MessageType) string {
+	if m.stream.value {
+		return "streaming"
+	}
+	return "not streaming"
+}
+
+func (c *compatibility) definitions(old, new DefinitionContainer, scope string) {
+	for _, e := range old.Enums() {
+		name := qualify(scope, e.Label().Get())
+		f := FindEnum(new, e.Label().Get())
+		if f == nil {
+			c.report(e, new, BreaksSource, "enum %s removed", name)
+			continue
+		}
+		c.enum(e, f, name)
+	}
+	for _, m := range old.Messages() {
+		name := qualify(scope, m.Label().Get())
+		n := FindMessage(new, m.Label().Get())
+		if n == nil {
+			c.report(m, new, BreaksSource, "message %s removed", name)
+			continue
+		}
+		c.message(m, n, name)
+		c.definitions(m, n, name)
+	}
+}
+
+func (c *compatibility) enum(old, new Enum, scope string) {
+	for _, field := range old.Fields() {
+		v, ok := field.(*Variant)
+		if !ok {
+			continue
+		}
+		name := qualify(scope, v.label.value)
+		number := *v.number.value
+		candidates := new.Aliases()[number]
+		if len(candidates) == 0 {
+			if breaks := removed(new, number, v.label.value); breaks != 0 {
+				c.report(v, new, breaks, "variant %s removed without reservation", name)
+			}
+			continue
+		}
+		renamed := true
+		for _, w := range candidates {
+			if w.label.value == v.label.value {
+				renamed = false
+			}
+		}
+		if renamed {
+			c.report(v, candidates[0], BreaksJSON|BreaksSource, "variant %s renamed to %s", name, candidates[0].label.value)
+		}
+	}
+}
+
+// removed field or variant breaks the wire format if its number can be
+// reused, and JSON if its label can be reused
+func removed(d Definition, number int32, label string) Breakage {
+	var breaks Breakage
+	if !reservedNumber(d, number) {
+		breaks |= BreaksWire | BreaksSource
+	}
+	if !reservedLabel(d, label) {
+		breaks |= BreaksJSON | BreaksSource
+	}
+	return breaks
+}
+
+func reservations(d Definition) (out []interface{}) {
+	switch p := d.(type) {
+	case *enum:
+		for _, f := range p.fields {
+			out = append(out, f)
+		}
+	case *message:
+		for _, f := range p.fields {
+			out = append(out, f)
+		}
+	default:
+		panic(fmt.Sprintf("unhandled definition type %T", p))
+	}
+	return
+}
+
+func reservedNumber(d Definition, number int32) bool {
+	probe := &Number{value: &number}
+	for _, f := range reservations(d) {
+		switch r := f.(type) {
+		case *ReservedNumber:
+			if probe.intersects(&r.number) {
+				return true
+			}
+		case *ReservedRange:
+			if probe.intersects(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func reservedLabel(d Definition, label string) bool {
+	for _, f := range reservations(d) {
+		if r, ok := f.(*ReservedLabel); ok && r.label.value == label {
+			return true
+		}
+	}
+	return false
+}
+
+// compatField is the common view of fields, map fields and oneof fields
+type compatField struct {
+	item     interface{}
+	label    string
+	json     string
+	number   int32
+	_type    ValueType
+	keyType  MapKeyType
+	repeated bool
+	oneof    *OneOf
+}
+
+func compatFields(m Message) (out []compatField) {
+	for _, f := range m.Fields() {
+		switch v := f.(type) {
+		case *Field:
+			out = append(out, compatField{v, v.label.value, jsonName(v.label.value, &v.options), *v.number.value, v._type.value, nil, v.repeated.value, nil})
+		case *Map:
+			out = append(out, compatField{v, v.label.value, jsonName(v.label.value, &v.options), *v.number.value, v._type.value, v.keyType.value, true, nil})
+		case *OneOf:
+			for _, g := range v.fields {
+				out = append(out, compatField{g, g.label.value, jsonName(g.label.value, &g.options), *g.number.value, g._type.value, nil, false, v})
+			}
+		}
+	}
+	return
+}
+
+func (c *compatibility) message(old, new Message, scope string) {
+	fields := make(map[int32]compatField)
+	for _, f := range compatFields(new) {
+		fields[f.number] = f
+	}
+	for _, f := range compatFields(old) {
+		name := qualify(scope, f.label)
+		g, ok := fields[f.number]
+		if !ok {
+			if breaks := removed(new, f.number, f.label); breaks != 0 {
+				c.report(f.item, new, breaks, "field %s removed without reservation", name)
+			}
+			continue
+		}
+		c.field(f, g, name)
+	}
+}
+
+func (c *compatibility) field(old, new compatField, name string) {
+	if old.label != new.label {
+		breaks := BreaksSource
+		if old.json != new.json {
+			breaks |= BreaksJSON
+		}
+		c.report(old.item, new.item, breaks, "field %s renamed to %s", name, new.label)
+	} else if old.json != new.json {
+		c.report(old.item, new.item, BreaksJSON, "JSON name of field %s changed from %q to %q", name, old.json, new.json)
+	}
+
+	switch {
+	case (old.keyType == nil) != (new.keyType == nil):
+		c.report(old.item, new.item, BreaksWire|BreaksJSON|BreaksSource, "field %s changed from %s to %s", name, fieldKind(old), fieldKind(new))
+		return
+	case old.keyType != new.keyType:
+		c.report(old.item, new.item, BreaksWire|BreaksJSON|BreaksSource, "key type of field %s changed from %s to %s", name, old.keyType, new.keyType)
+	case old.repeated != new.repeated:
+		c.report(old.item, new.item, BreaksWire|BreaksJSON|BreaksSource, "field %s changed from %s to %s", name, fieldKind(old), fieldKind(new))
+	}
+
+	if o, n := typeName(old._type, c.old), typeName(new._type, c.new); o != n {
+		breaks := BreaksSource
+		if wireClass(old._type) == "" || wireClass(old._type) != wireClass(new._type) {
+			breaks |= BreaksWire
+		}
+		if jsonClass(old._type) == "" || jsonClass(old._type) != jsonClass(new._type) {
+			breaks |= BreaksJSON
+		}
+		c.report(old.item, new.item, breaks, "type of field %s changed from %s to %s", name, o, n)
+	}
+
+	switch {
+	case old.oneof == nil && new.oneof == nil:
+	case old.oneof == nil:
+		breaks := BreaksSource
+		// a field moved into a oneof of its own keeps its encoding, but it
+		// now excludes others which could be set together before
+		if len(new.oneof.fields) > 1 {
+			breaks |= BreaksWire | BreaksJSON
+		}
+		c.report(old.item, new.item, breaks, "field %s moved into oneof %s", name, new.oneof.label.value)
+	case new.oneof == nil:
+		c.report(old.item, new.item, BreaksWire|BreaksSource, "field %s moved out of oneof %s", name, old.oneof.label.value)
+	case old.oneof.label.value != new.oneof.label.value:
+		c.report(old.item, new.item, BreaksWire|BreaksSource, "field %s moved from oneof %s to %s", name, old.oneof.label.value, new.oneof.label.value)
+	}
+}
+
+func fieldKind(f compatField) string {
+	switch {
+	case f.keyType != nil:
+		return "map"
+	case f.repeated:
+		return "repeated"
+	default:
+		return "singular"
+	}
+}
+
+// typeName of a value type, comparable across versions of a document.
+// definitions from the document itself are named relative to its package, so
+// that a changed package is not reported again for every reference.
+func typeName(v ValueType, d *Document) string {
+	switch t := v.(type) {
+	case Message:
+		if t.Document() == d {
+			return strings.TrimPrefix(definitionName(t), d._package.label.value+".")
+		}
+		return definitionName(t)
+	case Enum:
+		if t.Document() == d {
+			return strings.TrimPrefix(definitionName(t), d._package.label.value+".")
+		}
+		return definitionName(t)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// wireClass of types which can be changed into each other without breaking
+// the wire format. other types are only compatible with themselves.
+func wireClass(v ValueType) string {
+	if _, ok := v.(Enum); ok {
+		return "varint"
+	}
+	switch v {
+	case Int32, Int64, Uint32, Uint64, Bool:
+		return "varint"
+	case Sint32, Sint64:
+		return "zigzag"
+	case Fixed32, Sfixed32:
+		return "fixed32"
+	case Fixed64, Sfixed64:
+		return "fixed64"
+	case String, Bytes:
+		return "length-delimited"
+	default:
+		return ""
+	}
+}
+
+// jsonClass of types with the same JSON representation. other types are
+// only compatible with themselves.
+func jsonClass(v ValueType) string {
+	switch v {
+	case Int32, Uint32, Sint32, Fixed32, Sfixed32:
+		return "number"
+	case Int64, Uint64, Sint64, Fixed64, Sfixed64:
+		return "integer string"
+	default:
+		return ""
+	}
+}
+
+// jsonName of a field: the `json_name` option if set, otherwise the name
+// `protoc` derives from the label
+func jsonName(label string, o *FieldOptions) string {
+	if o.jsonName.value != nil {
+		return *o.jsonName.value
+	}
+	return DefaultJSONName(label)
+}
+
+// DefaultJSONName `protoc` derives from a field label: underscores are dropped
+// and the letters following them are upper cased.
+func DefaultJSONName(label string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range label {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareCompatibility(t *testing.T) {
+	old, err := Parse(strings.NewReader(`syntax = "proto3";
+package pkg;
+service S {
+  rpc Get (M) returns (M);
+  rpc Watch (M) returns (stream M);
+  rpc Gone (M) returns (M);
+}
+enum E {
+  ZERO = 0;
+  ONE = 1;
+  TWO = 2;
+  THREE = 3;
+}
+message M {
+  int32 id = 1;
+  string name = 2;
+  repeated int64 values = 3;
+  map <string,int32> counts = 4;
+  bool flag = 5;
+  uint32 kept = 6;
+  fixed32 fixed = 7;
+  string gone = 8;
+  string retired = 9;
+  oneof choice {
+    string a = 10;
+  }
+  string b = 11;
+  M loose = 12;
+  message Nested {}
+}
+message Other {}
+`))
+	require.Nil(t, err)
+	new, err := Parse(strings.NewReader(`syntax = "proto3";
+package pkg;
+service S {
+  rpc Get (Other) returns (M);
+  rpc Watch (M) returns (M);
+}
+enum E {
+  ZERO = 0;
+  UNO = 1;
+  THREE = 3;
+  reserved 2;
+}
+message M {
+  int32 id = 1;
+  string title = 2;
+  int64 values = 3;
+  map <int32,int32> counts = 4;
+  string flag = 5;
+  uint64 kept = 6;
+  sfixed32 fixed = 7;
+  reserved 9;
+  reserved "retired";
+  string a = 10;
+  oneof choice {
+    string b = 11;
+    M loose = 12;
+  }
+}
+message Other {}
+`))
+	require.Nil(t, err)
+
+	wire, json, source := BreaksWire, BreaksJSON, BreaksSource
+	expected := []struct {
+		breaks Breakage
+		reason string
+	}{
+		{wire | json | source, "request of rpc S.Get changed from M to Other"},
+		{wire | source, "response of rpc S.Watch changed from streaming to not streaming"},
+		{wire | source, "rpc S.Gone removed"},
+		{json | source, "variant E.ONE renamed to UNO"},
+		{json | source, "variant E.TWO removed without reservation"},
+		{json | source, "field M.name renamed to title"},
+		{wire | json | source, "field M.values changed from repeated to singular"},
+		{wire | json | source, "key type of field M.counts changed from string to int32"},
+		{wire | json | source, "type of field M.flag changed from bool to string"},
+		{json | source, "type of field M.kept changed from uint32 to uint64"},
+		{source, "type of field M.fixed changed from fixed32 to sfixed32"},
+		{wire | json | source, "field M.gone removed without reservation"},
+		{wire | source, "field M.a moved out of oneof choice"},
+		{wire | json | source, "field M.b moved into oneof choice"},
+		{wire | json | source, "field M.loose moved into oneof choice"},
+		{source, "message M.Nested removed"},
+	}
+	found := CompareCompatibility(old, new)
+	require.Len(t, found, len(expected))
+	for i, e := range expected {
+		assert.Equal(t, e.reason, found[i].Reason)
+		assert.Equal(t, e.breaks, found[i].Breaks, e.reason)
+	}
+
+	name := FindMessage(old, "M").Fields()[1]
+	assert.Equal(t, name, found[5].Old)
+	assert.Equal(t, FindMessage(new, "M").Fields()[1], found[5].New)
+	assert.Equal(t, "field M.name renamed to title (breaks JSON, source)", found[5].String())
+	assert.Equal(t, FindMessage(new, "M"), found[11].New)
+
+	assert.Empty(t, CompareCompatibility(old, old))
+}
+
+func TestCompareCompatibilityPackage(t *testing.T) {
+	old, err := Parse(strings.NewReader(`syntax = "proto3";
+package a;
+message M {
+  M next = 1;
+}
+`))
+	require.Nil(t, err)
+	new, err := Parse(strings.NewReader(`syntax = "proto3";
+package b;
+message M {
+  M next = 1 [json_name="following"];
+}
+`))
+	require.Nil(t, err)
+	found := CompareCompatibility(old, new)
+	require.Len(t, found, 2)
+	assert.Equal(t, `package changed from "a" to "b" (breaks wire, source)`, found[0].String())
+	assert.Equal(t, `JSON name of field M.next changed from "next" to "following" (breaks JSON)`, found[1].String())
+}
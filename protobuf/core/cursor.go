@@ -0,0 +1,276 @@
+package core
+
+import "fmt"
+
+// Cursor points at an item in the tree of a document, which is traversed in
+// the order items are printed: the document holds its package, if set,
+// imports, services, enums and messages. services hold RPCs, and messages hold
+// fields, oneofs and reservations, followed by nested enums and messages.
+// oneofs hold their fields, and enums their variants and reservations.
+//
+// the cursor stays on its item across edits, even if it is moved elsewhere.
+// once the item is removed, the cursor goes to the sibling at its former
+// index, as of when the cursor was last used, or the last sibling if there is
+// none, or the parent if there are no siblings left.
+type Cursor struct {
+	document *Document
+	// trail from the document to the item, as observed when the cursor was
+	// last used
+	trail []step
+}
+
+type step struct {
+	item  interface{}
+	index int
+}
+
+// Cursor pointing at the document itself
+func (d *Document) Cursor() *Cursor {
+	return &Cursor{document: d}
+}
+
+// Item the cursor points at
+func (c *Cursor) Item() interface{} {
+	c.resolve()
+	return c.item()
+}
+
+// Document the item the cursor points at belongs to. it changes when jumping
+// to a definition in another document.
+func (c *Cursor) Document() *Document {
+	c.resolve()
+	return c.document
+}
+
+// MoveTo an item of a document
+func (c *Cursor) MoveTo(item interface{}) error {
+	d, trail, ok := trailTo(item)
+	if !ok {
+		return fmt.Errorf("cannot move to %T: %w", item, errNotInserted)
+	}
+	c.document = d
+	c.trail = trail
+	return nil
+}
+
+// Parent moves to the item containing the current one, and reports whether
+// there is one.
+func (c *Cursor) Parent() bool {
+	c.resolve()
+	if len(c.trail) == 0 {
+		return false
+	}
+	c.trail = c.trail[:len(c.trail)-1]
+	return true
+}
+
+// FirstChild moves to the first item contained in the current one, and
+// reports whether there is one.
+func (c *Cursor) FirstChild() bool {
+	c.resolve()
+	children := childrenOf(c.item())
+	if len(children) == 0 {
+		return false
+	}
+	c.trail = append(c.trail, step{children[0], 0})
+	return true
+}
+
+// NextSibling moves to the following item in the same parent, and reports
+// whether there is one.
+func (c *Cursor) NextSibling() bool {
+	return c.sibling(1)
+}
+
+// PrevSibling moves to the preceding item in the same parent, and reports
+// whether there is one.
+func (c *Cursor) PrevSibling() bool {
+	return c.sibling(-1)
+}
+
+func (c *Cursor) sibling(offset int) bool {
+	c.resolve()
+	if len(c.trail) == 0 {
+		return false
+	}
+	last := len(c.trail) - 1
+	siblings := childrenOf(c.parent(last))
+	index := c.trail[last].index + offset
+	if index < 0 || index >= len(siblings) {
+		return false
+	}
+	c.trail[last] = step{siblings[index], index}
+	return true
+}
+
+// Definition moves to the message or enum referenced by the type of the
+// current field, or by the request of the current RPC, and reports whether
+// there is one.
+func (c *Cursor) Definition() bool {
+	c.resolve()
+	var value interface{}
+	switch v := c.item().(type) {
+	case *Field:
+		value = v._type.value
+	case *Map:
+		value = v._type.value
+	case *OneOfField:
+		value = v._type.value
+	case *RPC:
+		value = v.request.value
+	}
+	switch value.(type) {
+	case Message, Enum:
+		return c.MoveTo(value) == nil
+	default:
+		return false
+	}
+}
+
+func (c *Cursor) item() interface{} {
+	if len(c.trail) == 0 {
+		return c.document
+	}
+	return c.trail[len(c.trail)-1].item
+}
+
+// parent of the item at a position in the trail
+func (c *Cursor) parent(i int) interface{} {
+	if i == 0 {
+		return c.document
+	}
+	return c.trail[i-1].item
+}
+
+// resolve the trail after edits, keeping the item if it is still part of a
+// document
+func (c *Cursor) resolve() {
+	for i := len(c.trail) - 1; i >= -1; i-- {
+		var ancestor interface{} = c.document
+		if i >= 0 {
+			ancestor = c.trail[i].item
+		}
+		d, trail, ok := trailTo(ancestor)
+		if !ok {
+			continue
+		}
+		c.document = d
+		if i == len(c.trail)-1 {
+			c.trail = trail
+			return
+		}
+		// the item or one of its ancestors is gone, so go to the item in its
+		// place
+		index := c.trail[i+1].index
+		c.trail = trail
+		if children := childrenOf(ancestor); len(children) > 0 {
+			if index >= len(children) {
+				index = len(children) - 1
+			}
+			c.trail = append(c.trail, step{children[index], index})
+		}
+		return
+	}
+}
+
+// trailTo an item from its document, if the item is part of it
+func trailTo(item interface{}) (*Document, []step, bool) {
+	var trail []step
+	for {
+		if d, ok := item.(*Document); ok {
+			return d, trail, true
+		}
+		parent := parentOf(item)
+		if parent == nil {
+			return nil, nil, false
+		}
+		index := indexOf(childrenOf(parent), item)
+		if index < 0 {
+			return nil, nil, false
+		}
+		trail = append([]step{{item, index}}, trail...)
+		item = parent
+	}
+}
+
+// parentOf an item in the tree of its document
+func parentOf(item interface{}) interface{} {
+	switch v := item.(type) {
+	case *Package:
+		return v.parent
+	case *Import:
+		return v.parent
+	case *Service:
+		return v.parent
+	case *RPC:
+		return v.parent
+	case *message:
+		return v.parent
+	case *enum:
+		return v.parent
+	case *Field:
+		return v.parent
+	case *Map:
+		return v.parent
+	case *OneOf:
+		return v.parent
+	case *OneOfField:
+		return v.parent
+	case *Variant:
+		return v.parent
+	case *ReservedNumber:
+		return v.parent
+	case *ReservedRange:
+		return v.parent
+	case *ReservedLabel:
+		return v.parent
+	default:
+		return nil
+	}
+}
+
+// childrenOf an item in the tree of its document, in the order they are
+// printed
+func childrenOf(item interface{}) (out []interface{}) {
+	switch v := item.(type) {
+	case *Document:
+		if v._package.label.value != "" {
+			out = append(out, v.Package())
+		}
+		for _, i := range v.imports {
+			out = append(out, i)
+		}
+		for _, s := range v.services {
+			out = append(out, s)
+		}
+		for _, e := range v.enums {
+			out = append(out, e)
+		}
+		for _, m := range v.messages {
+			out = append(out, m)
+		}
+	case *Service:
+		for _, r := range v.rpcs {
+			out = append(out, r)
+		}
+	case *message:
+		for _, f := range v.fields {
+			out = append(out, f)
+		}
+		for _, e := range v.enums {
+			out = append(out, e)
+		}
+		for _, m := range v.messages {
+			out = append(out, m)
+		}
+	case *OneOf:
+		for _, f := range v.fields {
+			out = append(out, f)
+		}
+	case *enum:
+		for _, f := range v.fields {
+			out = append(out, f)
+		}
+	}
+	return out
+}
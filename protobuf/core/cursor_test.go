@@ -0,0 +1,104 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+package p;
+service S {
+  rpc Get (A) returns (A);
+}
+enum E {
+  NONE = 0;
+}
+message A {
+  E e = 1;
+  oneof choice {
+    A a = 2;
+  }
+  message B {}
+}`))
+	require.Nil(t, err)
+	a := FindMessage(d, "A")
+	e := FindEnum(d, "E")
+	c := d.Cursor()
+	assert.Equal(t, d, c.Item())
+	assert.False(t, c.Parent())
+	assert.False(t, c.NextSibling())
+
+	require.True(t, c.FirstChild())
+	assert.Equal(t, d.Package(), c.Item())
+	assert.False(t, c.PrevSibling())
+	assert.False(t, c.FirstChild())
+	require.True(t, c.NextSibling())
+	assert.Equal(t, d.Services()[0], c.Item())
+	require.True(t, c.FirstChild())
+	rpc := d.Services()[0].RPCs()[0]
+	assert.Equal(t, rpc, c.Item())
+	require.True(t, c.Definition())
+	assert.Equal(t, a, c.Item())
+
+	require.True(t, c.FirstChild())
+	assert.Equal(t, a.Fields()[0], c.Item())
+	require.True(t, c.NextSibling())
+	o := a.Fields()[1].(*OneOf)
+	assert.Equal(t, o, c.Item())
+	require.True(t, c.FirstChild())
+	assert.Equal(t, o.Fields()[0], c.Item())
+	require.True(t, c.Parent())
+	require.True(t, c.NextSibling())
+	assert.Equal(t, FindMessage(a, "B"), c.Item(), "nested definitions follow fields")
+	assert.False(t, c.NextSibling())
+
+	require.Nil(t, c.MoveTo(a.Fields()[0]))
+	require.True(t, c.Definition())
+	assert.Equal(t, e, c.Item())
+	require.True(t, c.PrevSibling())
+	assert.Equal(t, d.Services()[0], c.Item())
+	assert.NotNil(t, c.MoveTo(d.NewService()), "not inserted")
+}
+
+func TestCursorEdits(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message A {
+  string foo = 1;
+  string bar = 2;
+  string baz = 3;
+}
+message B {}`))
+	require.Nil(t, err)
+	a := FindMessage(d, "A")
+	foo := a.Fields()[0].(*Field)
+	bar := a.Fields()[1].(*Field)
+	baz := a.Fields()[2].(*Field)
+	c := d.Cursor()
+	require.Nil(t, c.MoveTo(bar))
+
+	require.Nil(t, bar.Position().Set(2))
+	assert.Equal(t, bar, c.Item())
+	assert.True(t, c.PrevSibling(), "cursor follows moved item")
+	assert.Equal(t, baz, c.Item())
+
+	require.Nil(t, baz.Remove())
+	assert.Equal(t, bar, c.Item(), "item in place of removed one")
+	require.Nil(t, bar.Remove())
+	assert.Equal(t, foo, c.Item(), "last sibling")
+	require.Nil(t, foo.Remove())
+	assert.Equal(t, a, c.Item(), "parent without children")
+
+	require.Nil(t, d.Undo())
+	require.True(t, c.FirstChild())
+	assert.Equal(t, foo, c.Item())
+	require.Nil(t, c.MoveTo(foo))
+	require.Nil(t, a.Remove())
+	assert.Equal(t, FindMessage(d, "B"), c.Item(), "ancestor removed")
+	require.Nil(t, d.Undo())
+	require.Nil(t, c.MoveTo(foo))
+	assert.Equal(t, a, c.Document().Messages()[0])
+}
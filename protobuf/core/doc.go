@@ -43,4 +43,12 @@ package core
 // the parent, except for `Message` and `Enum`, which are created by copying
 // contents. to continue operating on the resulting object in these special
 // cases you have to fetch it back from its parent by comparing labels, which
-// must be unique. this is not elegant, but preserves a consistent interface.
+// must be unique, with `FindMessage` or `FindEnum`. this is not elegant, but
+// preserves a consistent interface.
+// messages and enums already inserted are moved into other containers with
+// `MoveInto`, which keeps the item, such that references to it stay intact.
+
+// children are kept in the order they were inserted in, which is also the
+// order in which they are printed. `InsertIntoParentAt` and `Position` allow
+// placing them explicitly among their siblings of the same kind. the order
+// carries no meaning for validation.
@@ -5,16 +5,43 @@ import (
 )
 
 func NewDocument() *Document {
-	return &Document{Printer: DefaultPrinter}
+	d := &Document{Printer: DefaultPrinter}
+	d.deprecated.parent = d
+	d.options.init(d)
+	return d
 }
 
 type Document struct {
 	Printer
-	_package Package
-	imports  map[*Import]struct{}
-	services map[*Service]struct{}
-	messages map[*message]struct{}
-	enums    map[*enum]struct{}
+	workspace *Workspace
+	path      string
+	// wire compatibility of edits is checked against the baseline
+	baseline *Document
+	// edits which can be undone and redone
+	history history
+	// notified of changes
+	listeners []*Listener
+	_package  Package
+	// reserve numbers and labels of removed fields and variants
+	reserveRemoved Flag
+	deprecated     Flag
+	options        FileOptions
+	comments       Comments
+	imports        []*Import
+	services       []*Service
+	messages       []*message
+	enums          []*enum
+}
+
+// Path of the document in its workspace. it is empty for standalone
+// documents.
+func (d *Document) Path() string {
+	return d.path
+}
+
+// Workspace the document belongs to, or nil for standalone documents.
+func (d *Document) Workspace() *Workspace {
+	return d.workspace
 }
 
 func (d *Document) Package() *Package {
@@ -27,14 +54,38 @@ func (d *Document) Package() *Package {
 
 func (d Document) Imports() []*Import {
 	out := make([]*Import, len(d.imports))
-	j := 0
-	for i := range d.imports {
-		out[j] = i
-		j++
-	}
+	copy(out, d.imports)
 	return out
 }
 
+// ReserveRemoved makes removing a field or variant add a reserved number and
+// label in its place, so they cannot be reused by accident.
+func (d *Document) ReserveRemoved() *Flag {
+	d.reserveRemoved.parent = d
+	return &d.reserveRemoved
+}
+
+// Comments of the document are attached to the syntax declaration.
+func (d *Document) Comments() *Comments {
+	return &d.comments
+}
+
+func (d *Document) Deprecated() *Flag {
+	return &d.deprecated
+}
+
+func (d *Document) Options() *FileOptions {
+	return &d.options
+}
+
+func (d *Document) validateFlag(f *Flag) error {
+	return nil
+}
+
+func (d *Document) validateOption(Option) error {
+	return nil
+}
+
 func (d *Document) NewImport() *Import {
 	i := &Import{parent: d}
 	i.path.parent = i
@@ -44,26 +95,21 @@ func (d *Document) NewImport() *Import {
 
 func (d Document) Services() (out []*Service) {
 	out = make([]*Service, len(d.services))
-	i := 0
-	for s := range d.services {
-		out[i] = s
-		i++
-	}
+	copy(out, d.services)
 	return
 }
 
 func (d *Document) NewService() *Service {
 	s := &Service{parent: d}
 	s.label.parent = s
+	s.deprecated.parent = s
 	return s
 }
 
 func (d Document) Messages() (out []Message) {
 	out = make([]Message, len(d.messages))
-	i := 0
-	for m := range d.messages {
+	for i, m := range d.messages {
 		out[i] = m
-		i++
 	}
 	return
 }
@@ -76,10 +122,8 @@ func (d *Document) NewMessage() *NewMessage {
 
 func (d Document) Enums() (out []Enum) {
 	out = make([]Enum, len(d.enums))
-	i := 0
-	for e := range d.enums {
+	for i, e := range d.enums {
 		out[i] = e
-		i++
 	}
 	return
 }
@@ -94,91 +138,113 @@ func (d *Document) Document() *Document {
 	return d
 }
 
-func (d Document) String() string {
-	return d.Printer.Document(&d)
+func (d *Document) String() string {
+	return d.Printer.Document(d)
 }
 
-func (d *Document) insertImport(i *Import) (err error) {
-	if d.imports == nil {
-		d.imports = make(map[*Import]struct{})
-	}
-	if _, ok := d.imports[i]; ok {
+func (d *Document) insertImport(i *Import, index int) (err error) {
+	if indexOf(d.imports, i) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(d.imports)); err != nil {
+		return err
+	}
 	if err := i.validate(); err != nil {
 		return err
 	}
-	d.imports[i] = struct{}{}
+	d.imports = append(d.imports, i)
+	move(d.imports, len(d.imports)-1, index)
+	recordInsert(d, i, index, func() error {
+		return d.insertImport(i, index)
+	})
 	return nil
 }
 
-func (d *Document) insertService(s *Service) (err error) {
-	if d.services == nil {
-		d.services = make(map[*Service]struct{})
-	}
-	if _, ok := d.services[s]; ok {
+func (d *Document) insertService(s *Service, index int) (err error) {
+	if indexOf(d.services, s) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(d.services)); err != nil {
+		return err
+	}
 	if err := s.validate(); err != nil {
 		return err
 	}
-	d.services[s] = struct{}{}
+	d.services = append(d.services, s)
+	move(d.services, len(d.services)-1, index)
+	recordInsert(d, s, index, func() error {
+		return d.insertService(s, index)
+	})
 	return nil
 }
 
-func (d *Document) insertMessage(m *message) (err error) {
-	if d.messages == nil {
-		d.messages = make(map[*message]struct{})
-	}
-	if _, ok := d.messages[m]; ok {
+func (d *Document) insertMessage(m *message, index int) (err error) {
+	if indexOf(d.messages, m) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(d.messages)); err != nil {
+		return err
+	}
 	if err := m.validate(); err != nil {
 		return err
 	}
-	d.messages[m] = struct{}{}
+	d.messages = append(d.messages, m)
+	move(d.messages, len(d.messages)-1, index)
+	recordInsert(d, m, index, func() error {
+		return d.insertMessage(m, index)
+	})
 	return nil
 }
 
-func (d *Document) insertEnum(e *enum) (err error) {
-	if d.enums == nil {
-		d.enums = make(map[*enum]struct{})
-	}
-	if _, ok := d.enums[e]; ok {
+func (d *Document) insertEnum(e *enum, index int) (err error) {
+	if indexOf(d.enums, e) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(d.enums)); err != nil {
+		return err
+	}
 	if err := e.validate(); err != nil {
 		return err
 	}
-	d.enums[e] = struct{}{}
+	d.enums = append(d.enums, e)
+	move(d.enums, len(d.enums)-1, index)
+	recordInsert(d, e, index, func() error {
+		return d.insertEnum(e, index)
+	})
 	return nil
 }
 
-func (d Document) validateLabel(l *Label) error {
-	for s := range d.services {
-		if s.hasLabel(l) {
-			// TODO: return error type which contains other declaration
-			return fmt.Errorf("label %s already declared for a service", l.value)
+func (d *Document) validateLabel(l *Label) error {
+	for _, s := range d.services {
+		if existing := s.findLabel(l); existing != nil {
+			return &DuplicateLabelError{existing, l, l.value}
 		}
 	}
-	for m := range d.messages {
-		if m.hasLabel(l) {
-			// TODO: return error type which contains other declaration
-			return fmt.Errorf("label %s already declared for other message", l.value)
+	for _, m := range d.messages {
+		if existing := m.findLabel(l); existing != nil {
+			return &DuplicateLabelError{existing, l, l.value}
 		}
 	}
-	for e := range d.enums {
-		if e.hasLabel(l) {
-			// TODO: return error type which contains other declaration
-			return fmt.Errorf("label %s already declared for other enum", l.value)
+	for _, e := range d.enums {
+		if existing := e.findLabel(l); existing != nil {
+			return &DuplicateLabelError{existing, l, l.value}
 		}
 	}
+	name := qualify(d._package.label.value, l.value)
+	if existing := d.definedIn(d.loadSet(), name); existing != nil {
+		return &DuplicateLabelError{existing, l, l.value}
+	}
 	return nil
 }
 
 type Package struct {
-	label  Label
-	parent *Document
+	label    Label
+	comments Comments
+	parent   *Document
+}
+
+func (p *Package) Comments() *Comments {
+	return &p.comments
 }
 
 func (p Package) Get() string {
@@ -190,8 +256,22 @@ func (p *Package) Set(value string) error {
 }
 
 func (p *Package) Unset() error {
-	// TODO: check if there is a condition where unsetting is impossible
+	safety := p.parent.safety()
+	old := p.label.value
 	p.label.value = ""
+	err := p.parent.validateDefinitions()
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
+		p.label.value = old
+		return err
+	}
+	if old != "" {
+		record(&LabelChanged{&p.label, old, ""}, func() error {
+			return p.Set(old)
+		}, p.Unset)
+	}
 	return nil
 }
 
@@ -203,30 +283,52 @@ func (p *Package) Document() *Document {
 	return p.parent
 }
 
-func (p Package) String() string {
-	return p.parent.Printer.Package(&p)
+func (p *Package) String() string {
+	return p.parent.Printer.Package(p)
 }
 
 func (p *Package) validateLabel(l *Label) error {
-	return nil
+	// moving definitions to another package may make them collide with
+	// definitions in other documents
+	return p.parent.validateDefinitions()
 }
 
 type Import struct {
-	path   Label
-	public Flag
-	parent *Document
+	path     Label
+	public   Flag
+	comments Comments
+	parent   *Document
 }
 
 func (i *Import) Path() *Label {
 	return &i.path
 }
 
+func (i *Import) Comments() *Comments {
+	return &i.comments
+}
+
 func (i *Import) Public() *Flag {
 	return &i.public
 }
 
 func (i *Import) InsertIntoParent() error {
-	return i.parent.insertImport(i)
+	return i.parent.insertImport(i, len(i.parent.imports))
+}
+
+// InsertIntoParentAt inserts the import at an index among the other imports.
+func (i *Import) InsertIntoParentAt(index int) error {
+	return i.parent.insertImport(i, index)
+}
+
+// Remove the import. this fails if definitions from the imported document
+// are still referenced.
+func (i *Import) Remove() error {
+	return i.parent.removeImport(i)
+}
+
+func (i *Import) Position() *Position {
+	return &Position{i, func() interface{} { return i.parent.imports }}
 }
 
 func (i Import) Parent() *Document {
@@ -237,19 +339,45 @@ func (i Import) Document() *Document {
 	return i.parent
 }
 
-func (i Import) String() string {
-	return i.parent.Printer.Import(&i)
+func (i *Import) String() string {
+	return i.parent.Printer.Import(i)
 }
 
-func (i Import) validateLabel(l *Label) error {
-	// TODO: here would come the interesting part of checking if the file we want
-	// to import actually exists
-	return nil
+func (i *Import) validateLabel(l *Label) error {
+	d := i.parent
+	for _, other := range d.imports {
+		if other != i && other.path.value == l.value {
+			return &DuplicateLabelError{&other.path, l, l.value}
+		}
+	}
+	// standalone documents cannot resolve imports
+	if d.workspace == nil {
+		return nil
+	}
+	imported := d.workspace.documents[l.value]
+	if imported == nil {
+		return fmt.Errorf("document %s not found", l.value)
+	}
+	for _, dep := range imported.dependencies() {
+		if dep == d {
+			return fmt.Errorf("importing %s would create an import cycle", l.value)
+		}
+	}
+	// check the document graph as it would be with the import in place
+	if indexOf(d.imports, i) < 0 {
+		imports := d.imports
+		d.imports = append(imports[:len(imports):len(imports)], i)
+		defer func() { d.imports = imports }()
+	}
+	return d.validateImports()
 }
 
-func (i Import) validateFlag(f *Flag) error {
-	// TODO: "safe mode"
-	return nil
+func (i *Import) validateFlag(f *Flag) error {
+	if indexOf(i.parent.imports, i) < 0 || i.parent.workspace == nil {
+		return nil
+	}
+	// dependents may rely on definitions exported by a public import
+	return i.parent.validateImports()
 }
 
 func (i Import) validate() error {
@@ -1,15 +1,13 @@
 package core
 
-import (
-	"errors"
-	"fmt"
-	"strings"
-)
+import "fmt"
 
 type Enum interface {
 	Label() *Label
+	Deprecated() *Flag
 	AllowAlias() *Flag
-	Aliases() map[uint][]*Variant
+	Comments() *Comments
+	Aliases() map[int32][]*Variant
 
 	Fields() []EnumField
 
@@ -20,13 +18,17 @@ type Enum interface {
 
 	Parent() DefinitionContainer
 	Document() *Document
+	Position() *Position
+	MoveInto(DefinitionContainer) error
+	MoveIntoAt(DefinitionContainer, int) error
+	Remove() error
 	String() string
 
 	validate() error
-	hasLabel(*Label) bool
+	findLabel(*Label) *Label
 	validateLabel(*Label) error
 	validateNumber(FieldNumber) error
-	insertField(EnumField) error
+	insertField(EnumField, int) error
 
 	addReference(*Type)
 	removeReference(*Type)
@@ -36,8 +38,10 @@ type Enum interface {
 
 type enum struct {
 	label      Label
+	deprecated Flag
 	allowAlias Flag
-	fields     map[EnumField]struct{}
+	comments   Comments
+	fields     []EnumField
 	references map[*Type]struct{}
 	parent     DefinitionContainer
 
@@ -48,21 +52,29 @@ type EnumField interface {
 	String() string
 	Document() *Document
 	validateAsEnumField() error
-	hasLabel(*Label) bool
-	hasNumber(FieldNumber) bool
+	findLabel(*Label) *Label
+	findNumber(FieldNumber) FieldNumber
 }
 
 func (e *enum) Label() *Label {
 	return &e.label
 }
 
+func (e *enum) Deprecated() *Flag {
+	return &e.deprecated
+}
+
+func (e *enum) Comments() *Comments {
+	return &e.comments
+}
+
 func (e *enum) AllowAlias() *Flag {
 	return &e.allowAlias
 }
 
-func (e *enum) Aliases() map[uint][]*Variant {
-	numbers := make(map[uint][]*Variant, len(e.fields))
-	for field := range e.fields {
+func (e *enum) Aliases() map[int32][]*Variant {
+	numbers := make(map[int32][]*Variant, len(e.fields))
+	for _, field := range e.fields {
 		switch f := field.(type) {
 		case *Variant:
 			n := *f.number.value
@@ -72,16 +84,18 @@ func (e *enum) Aliases() map[uint][]*Variant {
 	return numbers
 }
 
-func (e enum) validateFlag(f *Flag) error {
-	for n, a := range e.Aliases() {
+func (e *enum) validateFlag(f *Flag) error {
+	if f != &e.allowAlias {
+		return nil
+	}
+	for _, variants := range e.Aliases() {
 		// check if aliasing is in place
-		if len(a) > 1 && !f.value {
-			// TODO: return error type with references to aliased fields
-			lines := []string{
-				fmt.Sprintf("field number %d is used multiple times.", n),
-				fmt.Sprintf("remove aliasing before setting %q.", "allow_alias = false"),
+		if len(variants) > 1 && !f.value {
+			return &AliasingRequiredError{
+				Enum:     e,
+				Number:   *variants[0].number.value,
+				Variants: variants,
 			}
-			return errors.New(strings.Join(lines, " "))
 		}
 	}
 	return nil
@@ -89,25 +103,35 @@ func (e enum) validateFlag(f *Flag) error {
 
 func (e *enum) Fields() (out []EnumField) {
 	out = make([]EnumField, len(e.fields))
-	i := 0
-	for f := range e.fields {
-		out[i] = f
-		i++
-	}
+	copy(out, e.fields)
 	return
 }
 
-func (e *enum) insertField(f EnumField) error {
-	if e.fields == nil {
-		e.fields = make(map[EnumField]struct{})
-	}
-	if _, ok := e.fields[f]; ok {
+func (e *enum) insertField(f EnumField, index int) error {
+	if indexOf(e.fields, f) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(e.fields)); err != nil {
+		return err
+	}
 	if err := f.validateAsEnumField(); err != nil {
 		return err
 	}
-	e.fields[f] = struct{}{}
+	safety := e.Document().safety()
+	e.fields = append(e.fields, f)
+	move(e.fields, len(e.fields)-1, index)
+	err := e.validateFirst()
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
+		move(e.fields, index, len(e.fields)-1)
+		e.fields = e.fields[:len(e.fields)-1]
+		return err
+	}
+	recordInsert(e, f.(removable), index, func() error {
+		return e.insertField(f, index)
+	})
 	return nil
 }
 
@@ -153,6 +177,19 @@ func (e enum) Parent() DefinitionContainer {
 	return e.parent
 }
 
+func (e *enum) Position() *Position {
+	return &Position{e, func() interface{} {
+		switch p := e.parent.(type) {
+		case *Document:
+			return p.enums
+		case *message:
+			return p.enums
+		default:
+			panic(fmt.Sprintf("unhandled definition container type %T", p))
+		}
+	}}
+}
+
 func (e *enum) Document() *Document {
 	return e.parent.Document()
 }
@@ -161,8 +198,20 @@ func (e *enum) String() string {
 	return e.Document().Printer.Enum(e)
 }
 
-func (e *enum) hasLabel(l *Label) bool {
-	return e.label.hasLabel(l)
+// findLabel of the enum or one of its variants. as in C++, variants are
+// declared in the scope enclosing the enum, not in the enum itself.
+func (e *enum) findLabel(l *Label) *Label {
+	if existing := e.label.findLabel(l); existing != nil {
+		return existing
+	}
+	for _, f := range e.fields {
+		if v, ok := f.(*Variant); ok {
+			if existing := v.label.findLabel(l); existing != nil {
+				return existing
+			}
+		}
+	}
+	return nil
 }
 
 func (e *enum) validateLabel(l *Label) error {
@@ -170,55 +219,82 @@ func (e *enum) validateLabel(l *Label) error {
 	case &e.label:
 		return e.parent.validateLabel(l)
 	default:
-		for f := range e.fields {
-			if f.hasLabel(l) {
-				return fmt.Errorf("label %s already declared", l.value)
+		for _, f := range e.fields {
+			if existing := f.findLabel(l); existing != nil {
+				return &DuplicateLabelError{existing, l, l.value}
 			}
 		}
+		if _, ok := l.parent.(*Variant); ok {
+			return e.parent.validateLabel(l)
+		}
 	}
 	return nil
 }
 
-func (e enum) validateNumber(n FieldNumber) error {
-	// TODO: check that 0 is present and not reserved
-	// TODO: check valid values
-	// https://developers.google.com/protocol-buffers/docs/proto3#assigning-field-numbers
-	for f := range e.fields {
-		if f.hasNumber(n) {
-			switch f.(type) {
-			case *Variant:
-				switch n := n.(type) {
-				case *Number:
-					switch n.parent.(type) {
-					case *Variant:
-						if e.allowAlias.value {
-							return nil
-						}
-						lines := []string{
-							fmt.Sprintf("field number %d already in use.", *n.value),
-							fmt.Sprintf("set %q to allow multiple labels for one number.", "allow_alias = true"),
-						}
-						return errors.New(strings.Join(lines, " "))
+func (e *enum) validateNumber(n FieldNumber) error {
+	if reserved(n) {
+		zero := int32(0)
+		if n.intersects(&Number{value: &zero}) {
+			return &ZeroReservedError{e, n}
+		}
+	} else if v := n.(*Number).parent.(*Variant); indexOf(e.fields, v) >= 0 {
+		if err := e.validateFirst(); err != nil {
+			return err
+		}
+	}
+	for _, f := range e.fields {
+		existing := f.findNumber(n)
+		if existing == nil {
+			continue
+		}
+		if v, ok := f.(*Variant); ok {
+			if n, ok := n.(*Number); ok {
+				if attempted, ok := n.parent.(*Variant); ok {
+					if e.allowAlias.value {
+						return nil
+					}
+					return &AliasingRequiredError{
+						Enum:     e,
+						Number:   *n.value,
+						Variants: []*Variant{v, attempted},
 					}
 				}
 			}
-			// TODO: return error type with instance of duplication, no need to be so
-			// verbose
-			var source string
-			switch v := n.(type) {
-			case *Number:
-				source = fmt.Sprintf("field number %d", *v.value)
-			case *ReservedRange:
-				source = fmt.Sprintf("range %d to %d", *v.start.value, *v.end.value)
-			default:
-				panic(fmt.Sprintf("unhandled number type %T", n))
+		}
+		return numberConflict(existing, n)
+	}
+	return nil
+}
+
+// validateFirst checks that the first variant, if any, has number 0. proto3
+// requires it as the default value.
+func (e *enum) validateFirst() error {
+	for _, f := range e.fields {
+		if v, ok := f.(*Variant); ok {
+			if *v.number.value != 0 {
+				return &FirstVariantError{e, v, *v.number.value}
 			}
-			return fmt.Errorf("%s already in use", source)
+			return nil
 		}
 	}
 	return nil
 }
 
+// reserved tells if a number belongs to a reservation rather than a field or
+// variant
+func reserved(n FieldNumber) bool {
+	switch v := n.(type) {
+	case *ReservedRange:
+		return true
+	case *Number:
+		switch v.parent.(type) {
+		case *ReservedNumber, *ReservedRange:
+			return true
+		}
+	}
+	return false
+}
+
 func (e *enum) validate() (err error) {
 	return e.label.validate()
 }
@@ -229,7 +305,11 @@ type NewEnum struct {
 }
 
 func (e *NewEnum) InsertIntoParent() error {
-	return e.parent.insertEnum(e.toEnum())
+	return e.parent.insertEnum(e.toEnum(), len(e.parent.Enums()))
+}
+
+func (e *NewEnum) InsertIntoParentAt(index int) error {
+	return e.parent.insertEnum(e.toEnum(), index)
 }
 
 func (e *NewEnum) toEnum() *enum {
@@ -241,6 +321,7 @@ func (e *NewEnum) toEnum() *enum {
 	}
 	ee.label.parent = ee
 	ee.allowAlias.parent = ee
+	ee.deprecated.parent = ee
 	return ee
 }
 
@@ -270,14 +351,19 @@ type Variant struct {
 }
 
 func (v *Variant) InsertIntoParent() error {
-	vv := &Variant{
-		field:  v.field,
-		parent: v.parent,
-	}
-	vv.label.parent = vv
-	vv.number.parent = vv
-	vv.deprecated.parent = vv
-	return v.parent.insertField(vv)
+	return v.parent.insertField(v, len(v.parent.fields))
+}
+
+func (v *Variant) InsertIntoParentAt(index int) error {
+	return v.parent.insertField(v, index)
+}
+
+func (v *Variant) Remove() error {
+	return v.parent.removeField(v)
+}
+
+func (v *Variant) Position() *Position {
+	return &Position{v, func() interface{} { return v.parent.fields }}
 }
 
 func (v Variant) Parent() Enum {
@@ -305,5 +391,16 @@ func (v Variant) validateNumber(n FieldNumber) error {
 }
 
 func (v Variant) validateFlag(f *Flag) error {
-	return v.parent.validateFlag(f)
+	return nil
+}
+
+// FindEnum in a container by its label, such as one inserted as a `NewEnum`.
+// it returns nil if there is none.
+func FindEnum(c DefinitionContainer, label string) Enum {
+	for _, e := range c.Enums() {
+		if e.Label().Get() == label {
+			return e
+		}
+	}
+	return nil
 }
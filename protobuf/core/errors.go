@@ -0,0 +1,202 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validation errors point to the items involved, such that consumers can
+// inspect them with `errors.As` and navigate to conflicting declarations.
+// note that attempted values are rolled back after a failed validation, so
+// errors also record the value which was rejected.
+
+// DuplicateLabelError is returned when a label is already declared in the
+// same scope. `Existing` may belong to another document loaded together with
+// the one being edited.
+type DuplicateLabelError struct {
+	Existing  *Label
+	Attempted *Label
+	Value     string
+}
+
+func (e *DuplicateLabelError) Error() string {
+	if d := e.Existing.parent.Document(); d != e.Attempted.parent.Document() {
+		return fmt.Sprintf("label %q already declared in %s", e.Value, d.path)
+	}
+	return fmt.Sprintf("label %q already declared", e.Value)
+}
+
+// NumberConflictError is returned when a field number or reserved range
+// intersects with another one of the same message or enum.
+type NumberConflictError struct {
+	Existing  FieldNumber
+	Attempted FieldNumber
+	// description of the attempted value, which is gone after rollback
+	attempted string
+}
+
+func numberConflict(existing, attempted FieldNumber) *NumberConflictError {
+	return &NumberConflictError{existing, attempted, describeNumber(attempted)}
+}
+
+func (e *NumberConflictError) Error() string {
+	return fmt.Sprintf("%s conflicts with %s", e.attempted, describeNumber(e.Existing))
+}
+
+func describeNumber(n FieldNumber) string {
+	switch v := n.(type) {
+	case *Number:
+		if _, ok := v.parent.(*ReservedNumber); ok {
+			return fmt.Sprintf("reserved number %d", *v.value)
+		}
+		return fmt.Sprintf("field number %d", *v.value)
+	case *ReservedRange:
+		return fmt.Sprintf("reserved range %d to %d", *v.start.value, *v.end.value)
+	default:
+		panic(fmt.Sprintf("unhandled field number type %T", v))
+	}
+}
+
+// InvalidIdentifierError is returned when a label does not match the syntax
+// of its kind of identifier.
+type InvalidIdentifierError struct {
+	Label   *Label
+	Value   string
+	Pattern string
+}
+
+func (e *InvalidIdentifierError) Error() string {
+	return fmt.Sprintf("invalid identifier %q: must match %s", e.Value, e.Pattern)
+}
+
+// InvalidPathError is returned when an import path is malformed.
+type InvalidPathError struct {
+	Label  *Label
+	Value  string
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("invalid path %q: %s", e.Value, e.Reason)
+}
+
+// AliasingRequiredError is returned when enum variants would share a number
+// without `allow_alias` set, or when unsetting `allow_alias` while variants
+// share a number.
+type AliasingRequiredError struct {
+	Enum     Enum
+	Number   int32
+	Variants []*Variant
+}
+
+func (e *AliasingRequiredError) Error() string {
+	labels := make([]string, len(e.Variants))
+	for i, v := range e.Variants {
+		labels[i] = v.label.value
+	}
+	return fmt.Sprintf("number %d is used by %s, which requires %q", e.Number, strings.Join(labels, " and "), "allow_alias = true")
+}
+
+// InvalidOptionError is returned when an option value is not allowed, or the
+// option does not apply to the item it is attached to.
+type InvalidOptionError struct {
+	Option Option
+	Reason string
+}
+
+func (e *InvalidOptionError) Error() string {
+	return fmt.Sprintf("option %s: %s", e.Option.Name(), e.Reason)
+}
+
+// NotSetError is returned when a required value is missing. `Value` is one of
+// `*Label`, `*Number`, `*Type`, `*KeyType` or `*MessageType`.
+type NotSetError struct {
+	Value interface{}
+}
+
+func (e *NotSetError) Error() string {
+	switch e.Value.(type) {
+	case *Label:
+		return "label not set"
+	case *Number:
+		return "number not set"
+	case *Type:
+		return "type not set"
+	case *KeyType:
+		return "map key type not set"
+	case *MessageType:
+		return "message type not set"
+	default:
+		panic(fmt.Sprintf("unhandled value type %T", e.Value))
+	}
+}
+
+// InvalidRangeError is returned when the end of a reserved range would not be
+// greater than its start.
+type InvalidRangeError struct {
+	Range *ReservedRange
+	Start int32
+	End   int32
+}
+
+func (e *InvalidRangeError) Error() string {
+	return fmt.Sprintf("end of number range %d to %d must be greater than start", e.Start, e.End)
+}
+
+// IncompatibleChangeError is returned in safe mode when an edit would break
+// wire compatibility with the baseline of the document.
+type IncompatibleChangeError struct {
+	Incompatibility Incompatibility
+}
+
+func (e *IncompatibleChangeError) Error() string {
+	return fmt.Sprintf("incompatible with baseline: %s", e.Incompatibility.Reason)
+}
+
+// NumberOutOfRangeError is returned when a field number, reserved number or
+// end of a reserved range is outside of the bounds protobuf allows.
+type NumberOutOfRangeError struct {
+	Number *Number
+	Value  int32
+	Min    int32
+	Max    int32
+}
+
+func (e *NumberOutOfRangeError) Error() string {
+	return fmt.Sprintf("number %d out of range: must be between %d and %d", e.Value, e.Min, e.Max)
+}
+
+// ImplementationReservedError is returned when a message field number is
+// within the block reserved for the protobuf implementation. reserved numbers
+// and ranges may cover that block.
+type ImplementationReservedError struct {
+	Number *Number
+	Value  int32
+}
+
+func (e *ImplementationReservedError) Error() string {
+	return fmt.Sprintf("number %d is reserved for the protobuf implementation: %d to %d must not be used", e.Value, firstImplementationNumber, lastImplementationNumber)
+}
+
+// FirstVariantError is returned when the first variant of an enum would not
+// have number 0, which proto3 requires as the default value.
+type FirstVariantError struct {
+	Enum    Enum
+	Variant *Variant
+	Value   int32
+}
+
+func (e *FirstVariantError) Error() string {
+	return fmt.Sprintf("first variant %s of enum %s must have number 0, not %d", e.Variant.label.value, e.Enum.Label().Get(), e.Value)
+}
+
+// ZeroReservedError is returned when reserving number 0 in an enum, which
+// must remain available for the first variant.
+type ZeroReservedError struct {
+	Enum     Enum
+	Reserved FieldNumber
+}
+
+func (e *ZeroReservedError) Error() string {
+	return fmt.Sprintf("number 0 must not be reserved in enum %s", e.Enum.Label().Get())
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message A {
+  int32 a = 1;
+  reserved 5 to 10;
+}
+enum E {
+  option allow_alias = true;
+  X = 0;
+  Y = 0;
+}
+`))
+	require.Nil(t, err)
+	a := FindMessage(d, "A")
+	e := FindEnum(d, "E")
+	field := a.Fields()[0].(*Field)
+
+	f := a.NewField()
+	err = f.Label().Set("a")
+	var duplicate *DuplicateLabelError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, field.Label(), duplicate.Existing)
+	assert.Equal(t, f.Label(), duplicate.Attempted)
+	assert.Equal(t, "a", duplicate.Value)
+	assert.Equal(t, "", f.Label().Get(), "rolled back")
+
+	err = f.Label().Set("1a")
+	var invalid *InvalidIdentifierError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, f.Label(), invalid.Label)
+	assert.Equal(t, "1a", invalid.Value)
+
+	err = f.Number().Set(7)
+	var conflict *NumberConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, f.Number(), conflict.Attempted)
+	assert.IsType(t, &ReservedRange{}, conflict.Existing)
+	assert.Equal(t, "field number 7 conflicts with reserved range 5 to 10", err.Error())
+
+	err = f.InsertIntoParent()
+	var notSet *NotSetError
+	require.True(t, errors.As(err, &notSet))
+	assert.Equal(t, f.Label(), notSet.Value)
+
+	r := a.NewReservedRange()
+	require.Nil(t, r.Start().Set(12))
+	err = r.End().Set(11)
+	var invalidRange *InvalidRangeError
+	require.True(t, errors.As(err, &invalidRange))
+	assert.Equal(t, r, invalidRange.Range)
+	assert.Equal(t, "end of number range 12 to 11 must be greater than start", err.Error())
+	assert.Nil(t, r.End().Get(), "rolled back")
+
+	err = e.AllowAlias().Set(false)
+	var aliasing *AliasingRequiredError
+	require.True(t, errors.As(err, &aliasing))
+	assert.Equal(t, e, aliasing.Enum)
+	assert.Equal(t, int32(0), aliasing.Number)
+	assert.Len(t, aliasing.Variants, 2)
+	assert.True(t, e.AllowAlias().Get(), "rolled back")
+}
+
+func TestDuplicateLabelAcrossDocuments(t *testing.T) {
+	w := NewWorkspace()
+	_, err := w.Parse("a.proto", strings.NewReader(`syntax = "proto3";
+message A {}
+`))
+	require.Nil(t, err)
+	b, err := w.Parse("b.proto", strings.NewReader(`syntax = "proto3";
+import "a.proto";
+`))
+	require.Nil(t, err)
+
+	m := b.NewMessage()
+	err = m.Label().Set("A")
+	var duplicate *DuplicateLabelError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, w.Document("a.proto"), duplicate.Existing.Parent().Document())
+	assert.Equal(t, `label "A" already declared in a.proto`, err.Error())
+}
+
+func TestFieldNumberRange(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message A {
+  int32 a = 536870911;
+  reserved 19000 to 19999, 18999, 30000 to 40000;
+}
+`))
+	require.Nil(t, err)
+	a := FindMessage(d, "A")
+	f := a.NewField()
+
+	var outOfRange *NumberOutOfRangeError
+	err = f.Number().Set(1 << 29)
+	require.True(t, errors.As(err, &outOfRange))
+	assert.Equal(t, f.Number(), outOfRange.Number)
+	assert.Equal(t, int32(1<<29), outOfRange.Value)
+	assert.Equal(t, int32(1<<29-1), outOfRange.Max)
+	assert.Nil(t, f.Number().Get(), "rolled back")
+	assert.True(t, errors.As(f.Number().Set(0), &outOfRange))
+
+	var implementation *ImplementationReservedError
+	for _, n := range []int32{19000, 19500, 19999} {
+		err = f.Number().Set(n)
+		require.True(t, errors.As(err, &implementation))
+		assert.Equal(t, n, implementation.Value)
+	}
+	assert.Equal(t, "number 19999 is reserved for the protobuf implementation: 19000 to 19999 must not be used", err.Error())
+	assert.Nil(t, f.Number().Set(20000))
+
+	r := a.NewReservedRange()
+	require.Nil(t, r.Start().Set(20001))
+	assert.True(t, errors.As(r.End().Set(1<<29), &outOfRange))
+	assert.Equal(t, r.End(), outOfRange.Number)
+	assert.Nil(t, r.End().Set(20002))
+	assert.True(t, errors.As(r.Start().Set(0), &outOfRange))
+
+	_, err = Parse(strings.NewReader(`syntax = "proto3";
+message A {
+  int32 a = 19123;
+}
+`))
+	require.True(t, errors.As(err, &implementation))
+}
+
+func TestEnumZero(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+enum E {
+  ZERO = 0;
+  ONE = 1;
+}
+`))
+	require.Nil(t, err)
+	e := FindEnum(d, "E")
+	zero := e.Fields()[0].(*Variant)
+	one := e.Fields()[1].(*Variant)
+
+	var first *FirstVariantError
+	require.True(t, errors.As(zero.Number().Set(2), &first))
+	assert.Equal(t, zero, first.Variant)
+	assert.Equal(t, "first variant ZERO of enum E must have number 0, not 2", first.Error())
+	assert.True(t, errors.As(one.Position().MoveUp(), &first))
+	assert.Equal(t, 1, one.Position().Get(), "rolled back")
+	assert.True(t, errors.As(zero.Remove(), &first))
+
+	v := e.NewVariant()
+	require.Nil(t, v.Label().Set("TWO"))
+	require.Nil(t, v.Number().Set(2))
+	assert.True(t, errors.As(v.InsertIntoParentAt(0), &first))
+	assert.Nil(t, v.InsertIntoParent())
+
+	var reserved *ZeroReservedError
+	r := e.NewReservedRange()
+	require.Nil(t, r.Start().Set(-3))
+	require.True(t, errors.As(r.End().Set(0), &reserved))
+	assert.Equal(t, e, reserved.Enum)
+	assert.Nil(t, r.End().Set(-1))
+	assert.True(t, errors.As(e.NewReservedNumber().Set(0), &reserved))
+
+	_, err = Parse(strings.NewReader(`syntax = "proto3";
+enum E {
+  ONE = 1;
+  ZERO = 0;
+}
+`))
+	require.True(t, errors.As(err, &first))
+}
+
+func TestEnumValueScope(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+enum A {
+  UNKNOWN = 0;
+}
+message M {
+  enum B {
+    UNKNOWN = 0;
+  }
+  enum C {
+    C_UNKNOWN = 0;
+  }
+  int32 field = 1;
+}
+`))
+	require.Nil(t, err)
+	a := FindEnum(d, "A")
+	m := FindMessage(d, "M")
+	c := m.Enums()[1]
+
+	var duplicate *DuplicateLabelError
+	v := c.NewVariant()
+	require.True(t, errors.As(v.Label().Set("UNKNOWN"), &duplicate))
+	assert.Equal(t, m.Enums()[0].Fields()[0].(*Variant).Label(), duplicate.Existing)
+	assert.NotNil(t, v.Label().Set("field"))
+	assert.NotNil(t, v.Label().Set("C"))
+	assert.Nil(t, v.Label().Set("A"), "enclosing scope")
+
+	n := d.NewMessage()
+	assert.NotNil(t, n.Label().Set("UNKNOWN"))
+	assert.NotNil(t, a.Label().Set("UNKNOWN"))
+	assert.NotNil(t, m.Fields()[0].(*Field).Label().Set("C_UNKNOWN"))
+
+	// reserved labels are local to the enum
+	r := c.NewReservedLabel()
+	assert.Nil(t, r.Set("field"))
+
+	for _, input := range []string{`syntax = "proto3";
+enum A {
+  UNKNOWN = 0;
+}
+enum B {
+  UNKNOWN = 0;
+}
+`, `syntax = "proto3";
+message UNKNOWN {}
+enum B {
+  UNKNOWN = 0;
+}
+`} {
+		_, err = Parse(strings.NewReader(input))
+		assert.True(t, errors.As(err, &duplicate), input)
+	}
+
+	w := NewWorkspace()
+	_, err = w.Parse("a.proto", strings.NewReader(`syntax = "proto3";
+package pkg;
+enum A {
+  UNKNOWN = 0;
+}
+`))
+	require.Nil(t, err)
+	_, err = w.Parse("b.proto", strings.NewReader(`syntax = "proto3";
+package pkg;
+import "a.proto";
+message UNKNOWN {}
+`))
+	assert.True(t, errors.As(err, &duplicate))
+}
@@ -0,0 +1,208 @@
+package core
+
+import "fmt"
+
+// listeners are notified of every validated change to items inserted into a
+// document, including changes made by undoing and redoing edits. changes
+// consisting of several steps, such as removing a field and reserving its
+// number and label, are only announced once all steps succeeded. listeners
+// are called in the order they subscribed.
+
+// Event describing a validated change to a document. it is one of `*Inserted`,
+// `*Removed`, `*Moved`, `*MovedInto`, `*LabelChanged`, `*NumberChanged`,
+// `*FlagChanged`, `*TypeChanged`, `*KeyTypeChanged`, `*MessageTypeChanged` and
+// `*OptionChanged`.
+type Event interface {
+	String() string
+	// owner is the item which has to be inserted for the change to be part of
+	// the document
+	owner() interface{}
+}
+
+// Listener for events
+type Listener func(Event)
+
+// Subscribe to changes of the document. the returned function cancels the
+// subscription.
+func (d *Document) Subscribe(l Listener) (unsubscribe func()) {
+	subscription := &l
+	d.listeners = append(d.listeners, subscription)
+	return func() {
+		if i := indexOf(d.listeners, subscription); i >= 0 {
+			d.listeners = append(d.listeners[:i:i], d.listeners[i+1:]...)
+		}
+	}
+}
+
+func (d *Document) notify(e Event) {
+	listeners := make([]*Listener, len(d.listeners))
+	copy(listeners, d.listeners)
+	for _, l := range listeners {
+		(*l)(e)
+	}
+}
+
+// Inserted item into its parent, at an index among its siblings
+type Inserted struct {
+	Item   interface{}
+	Parent interface{}
+	Index  int
+}
+
+func (e *Inserted) String() string {
+	return fmt.Sprint("insert ", describe(e.Item))
+}
+
+func (e *Inserted) owner() interface{} {
+	return e.Parent
+}
+
+// Removed item from its parent, at its former index among its siblings
+type Removed struct {
+	Item   interface{}
+	Parent interface{}
+	Index  int
+}
+
+func (e *Removed) String() string {
+	return fmt.Sprint("remove ", describe(e.Item))
+}
+
+func (e *Removed) owner() interface{} {
+	return e.Parent
+}
+
+// Moved item among its siblings
+type Moved struct {
+	Item     interface{}
+	Old, New int
+}
+
+func (e *Moved) String() string {
+	return fmt.Sprint("move ", describe(e.Item))
+}
+
+func (e *Moved) owner() interface{} {
+	return e.Item
+}
+
+// MovedInto another parent, from an index among its former siblings to one
+// among its new siblings
+type MovedInto struct {
+	Item      interface{}
+	OldParent interface{}
+	NewParent interface{}
+	Old, New  int
+}
+
+func (e *MovedInto) String() string {
+	return fmt.Sprint("move ", describe(e.Item))
+}
+
+func (e *MovedInto) owner() interface{} {
+	return e.Item
+}
+
+// LabelChanged of an item. unsetting the package results in an empty label.
+type LabelChanged struct {
+	Label    *Label
+	Old, New string
+}
+
+func (e *LabelChanged) String() string {
+	return fmt.Sprint("set label of ", describe(e.Label.parent))
+}
+
+func (e *LabelChanged) owner() interface{} {
+	return e.Label.parent
+}
+
+// NumberChanged of a field, variant or reservation
+type NumberChanged struct {
+	Number   *Number
+	Old, New int32
+}
+
+func (e *NumberChanged) String() string {
+	return fmt.Sprint("set number of ", describe(e.Number.parent))
+}
+
+func (e *NumberChanged) owner() interface{} {
+	return e.Number.parent
+}
+
+// FlagChanged of an item
+type FlagChanged struct {
+	Flag     *Flag
+	Old, New bool
+}
+
+func (e *FlagChanged) String() string {
+	return fmt.Sprintf("set %s of %s", flagName(e.Flag), describe(e.Flag.parent))
+}
+
+func (e *FlagChanged) owner() interface{} {
+	return e.Flag.parent
+}
+
+// TypeChanged of a field
+type TypeChanged struct {
+	Type     *Type
+	Old, New ValueType
+}
+
+func (e *TypeChanged) String() string {
+	return fmt.Sprint("set type of ", describe(e.Type.parent))
+}
+
+func (e *TypeChanged) owner() interface{} {
+	return e.Type.parent
+}
+
+// KeyTypeChanged of a map field
+type KeyTypeChanged struct {
+	KeyType  *KeyType
+	Old, New MapKeyType
+}
+
+func (e *KeyTypeChanged) String() string {
+	return fmt.Sprint("set ", describe(e.KeyType))
+}
+
+func (e *KeyTypeChanged) owner() interface{} {
+	return e.KeyType
+}
+
+// MessageTypeChanged of an RPC request or response
+type MessageTypeChanged struct {
+	MessageType *MessageType
+	Old, New    Message
+}
+
+func (e *MessageTypeChanged) String() string {
+	return fmt.Sprint("set ", describe(e.MessageType))
+}
+
+func (e *MessageTypeChanged) owner() interface{} {
+	return e.MessageType
+}
+
+// OptionChanged of an item. values are `bool` or `string`, or `nil` if the
+// option is not set.
+type OptionChanged struct {
+	Item     interface{}
+	Option   Option
+	Old, New interface{}
+}
+
+func (e *OptionChanged) String() string {
+	action := "set"
+	if e.New == nil {
+		action = "unset"
+	}
+	return fmt.Sprintf("%s option %s of %s", action, e.Option.Name(), describe(e.Item))
+}
+
+func (e *OptionChanged) owner() interface{} {
+	return e.Item
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+message A {
+  string foo = 1;
+}`))
+	require.Nil(t, err)
+	var events []Event
+	unsubscribe := d.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	a := FindMessage(d, "A")
+	foo := a.Fields()[0].(*Field)
+	require.Nil(t, foo.Label().Set("bar"))
+	assert.NotNil(t, foo.Number().Set(0))
+	require.Nil(t, foo.Number().Set(2))
+	require.Nil(t, foo.Deprecated().Set(true))
+	require.Nil(t, foo.Type().Set(a))
+	require.Nil(t, foo.Options().JSONName().Set("baz"))
+	require.Nil(t, foo.Options().JSONName().Unset())
+	b := a.NewField()
+	require.Nil(t, b.Label().Set("baz"))
+	require.Nil(t, b.Number().Set(3))
+	require.Nil(t, b.Type().Set(String))
+	require.Nil(t, b.InsertIntoParent())
+	require.Nil(t, b.Position().MoveUp())
+
+	require.Len(t, events, 8, "failed and tentative changes are not announced")
+	assert.Equal(t, &LabelChanged{foo.Label(), "foo", "bar"}, events[0])
+	assert.Equal(t, &NumberChanged{foo.Number(), 1, 2}, events[1])
+	assert.Equal(t, &FlagChanged{foo.Deprecated(), false, true}, events[2])
+	assert.Equal(t, &TypeChanged{foo.Type(), String, a}, events[3])
+	assert.Equal(t, &OptionChanged{foo, foo.Options().JSONName(), nil, "baz"}, events[4])
+	assert.Equal(t, &OptionChanged{foo, foo.Options().JSONName(), "baz", nil}, events[5])
+	assert.Equal(t, &Inserted{b, a, 1}, events[6])
+	assert.Equal(t, &Moved{b, 1, 0}, events[7])
+
+	events = nil
+	require.Nil(t, d.Undo())
+	assert.Equal(t, []Event{&Moved{b, 0, 1}}, events, "undoing is announced")
+
+	unsubscribe()
+	require.Nil(t, b.Remove())
+	assert.Len(t, events, 1)
+}
+
+func TestSubscribeRemove(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+message A {
+  string foo = 1;
+  int32 bar = 2;
+}
+
+enum E {
+  ZERO = 0;
+  ONE = 1;
+}`))
+	require.Nil(t, err)
+	require.Nil(t, d.ReserveRemoved().Set(true))
+	var events []Event
+	d.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	a := FindMessage(d, "A")
+	foo := a.Fields()[0]
+	require.Nil(t, foo.(*Field).Remove())
+	require.Len(t, events, 3)
+	assert.Equal(t, &Removed{foo, a, 0}, events[0])
+	assert.IsType(t, &Inserted{}, events[1])
+	assert.IsType(t, &Inserted{}, events[2])
+	assert.Equal(t, "insert reserved number 1 in message A", events[1].String())
+
+	events = nil
+	zero := FindEnum(d, "E").Fields()[0].(*Variant)
+	assert.NotNil(t, zero.Remove(), "first variant must be 0")
+	assert.Empty(t, events, "changes rolled back are not announced")
+}
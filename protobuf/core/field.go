@@ -4,6 +4,7 @@ type field struct {
 	label      Label
 	number     Number
 	deprecated Flag
+	comments   Comments
 }
 
 func (f *field) Label() *Label {
@@ -18,12 +19,16 @@ func (f *field) Deprecated() *Flag {
 	return &f.deprecated
 }
 
-func (f *field) hasLabel(other *Label) bool {
-	return f.label.hasLabel(other)
+func (f *field) Comments() *Comments {
+	return &f.comments
 }
 
-func (f *field) hasNumber(n FieldNumber) bool {
-	return f.number.hasNumber(n)
+func (f *field) findLabel(other *Label) *Label {
+	return f.label.findLabel(other)
+}
+
+func (f *field) findNumber(n FieldNumber) FieldNumber {
+	return f.number.findNumber(n)
 }
 
 func (f *field) validate() (err error) {
@@ -40,13 +45,18 @@ func (f *field) validate() (err error) {
 
 type typedField struct {
 	field
-	_type Type
+	_type   Type
+	options FieldOptions
 }
 
 func (f *typedField) Type() *Type {
 	return &f._type
 }
 
+func (f *typedField) Options() *FieldOptions {
+	return &f.options
+}
+
 func (f *typedField) validate() (err error) {
 	if err = f.field.validate(); err != nil {
 		return
@@ -61,6 +71,7 @@ type Field struct {
 	field
 	_type    Type
 	repeated Flag
+	options  FieldOptions
 	parent   *message
 }
 
@@ -68,12 +79,28 @@ func (r *Field) Type() *Type {
 	return &r._type
 }
 
+func (r *Field) Options() *FieldOptions {
+	return &r.options
+}
+
 func (r *Field) Repeated() *Flag {
 	return &r.repeated
 }
 
 func (r *Field) InsertIntoParent() error {
-	return r.parent.insertField(r)
+	return r.parent.insertField(r, len(r.parent.fields))
+}
+
+func (r *Field) InsertIntoParentAt(index int) error {
+	return r.parent.insertField(r, index)
+}
+
+func (r *Field) Remove() error {
+	return r.parent.removeField(r)
+}
+
+func (r *Field) Position() *Position {
+	return &Position{r, func() interface{} { return r.parent.fields }}
 }
 
 func (r *Field) Parent() Message {
@@ -84,8 +111,8 @@ func (r *Field) Document() *Document {
 	return r.parent.Document()
 }
 
-func (r Field) String() string {
-	return r.Document().Printer.Field(&r)
+func (r *Field) String() string {
+	return r.Document().Printer.Field(r)
 }
 
 func (r *Field) validateAsMessageField() (err error) {
@@ -107,13 +134,19 @@ func (r *Field) validateNumber(n FieldNumber) error {
 }
 
 func (r *Field) validateFlag(f *Flag) error {
-	// TODO: if we ever have "safe mode" to prevent backwards-incompatible
-	// changes, that is where errors whould happen
 	switch f {
 	case &r.deprecated:
 		return nil
 	case &r.repeated:
-		return nil
+		return r.options.validate(r._type.value, r.repeated.value)
 	}
 	return nil
 }
+
+func (r *Field) validateType(t *Type) error {
+	return r.options.validate(t.value, r.repeated.value)
+}
+
+func (r *Field) validateOption(Option) error {
+	return r.options.validate(r._type.value, r.repeated.value)
+}
@@ -10,12 +10,22 @@ func (f Flag) Get() bool {
 }
 
 func (f *Flag) Set(value bool) error {
+	safety := f.parent.Document().safety()
 	old := f.value
 	f.value = value
-	if err := f.parent.validateFlag(f); err != nil {
+	err := f.parent.validateFlag(f)
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
 		f.value = old
 		return err
 	}
+	record(&FlagChanged{f, old, value}, func() error {
+		return f.Set(old)
+	}, func() error {
+		return f.Set(value)
+	})
 	return nil
 }
 
@@ -24,5 +34,6 @@ func (f Flag) Parent() Flagged {
 }
 
 type Flagged interface {
+	Document() *Document
 	validateFlag(*Flag) error
 }
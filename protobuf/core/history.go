@@ -0,0 +1,282 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the history of a document records edits of inserted items: setting labels,
+// numbers, flags, types and options, inserting, removing and moving items.
+// edits of tentative items are not recorded, since they are not part of the
+// document until inserted. comments are not recorded either.
+//
+// undoing and redoing goes through the same validation as the original edit,
+// so it fails if it would result in an invalid document, such as when an
+// undone rename collides with a label introduced later. the edit then stays
+// where it is in the history.
+
+// Edit recorded in the history of a document
+type Edit struct {
+	description string
+	undo        func() error
+	redo        func() error
+	// nested edits which are part of this one, such as reservations made when
+	// removing a field
+	nested []*Edit
+}
+
+func (e *Edit) String() string {
+	return e.description
+}
+
+func (e *Edit) revert() error {
+	for i := len(e.nested) - 1; i >= 0; i-- {
+		if err := e.nested[i].revert(); err != nil {
+			for _, n := range e.nested[i+1:] {
+				_ = n.apply()
+			}
+			return err
+		}
+	}
+	if e.undo == nil {
+		return nil
+	}
+	return e.undo()
+}
+
+func (e *Edit) apply() error {
+	if e.redo != nil {
+		if err := e.redo(); err != nil {
+			return err
+		}
+	}
+	for i, n := range e.nested {
+		if err := n.apply(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = e.nested[j].revert()
+			}
+			if e.undo != nil {
+				_ = e.undo()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type history struct {
+	done   []*Edit
+	undone []*Edit
+	// changes collected for compound edits in progress
+	open [][]change
+	// no edits are recorded while undoing or redoing
+	replaying bool
+}
+
+// change of the document, which is announced by the event and can be undone
+// and redone by the edit
+type change struct {
+	event Event
+	edit  *Edit
+}
+
+// History of edits, oldest first, and of undone edits, the next to be redone
+// first.
+func (d *Document) History() (done, undone []*Edit) {
+	done = make([]*Edit, len(d.history.done))
+	copy(done, d.history.done)
+	undone = make([]*Edit, len(d.history.undone))
+	for i, e := range d.history.undone {
+		undone[len(undone)-1-i] = e
+	}
+	return
+}
+
+// ClearHistory forgets all edits, such as the ones made while loading the
+// document.
+func (d *Document) ClearHistory() {
+	d.history.done = nil
+	d.history.undone = nil
+}
+
+// Undo the latest edit
+func (d *Document) Undo() error {
+	h := &d.history
+	if len(h.done) == 0 {
+		return errors.New("nothing to undo")
+	}
+	e := h.done[len(h.done)-1]
+	h.replaying = true
+	err := e.revert()
+	h.replaying = false
+	if err != nil {
+		return fmt.Errorf("cannot undo %s: %w", e, err)
+	}
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, e)
+	return nil
+}
+
+// Redo the latest undone edit
+func (d *Document) Redo() error {
+	h := &d.history
+	if len(h.undone) == 0 {
+		return errors.New("nothing to redo")
+	}
+	e := h.undone[len(h.undone)-1]
+	h.replaying = true
+	err := e.apply()
+	h.replaying = false
+	if err != nil {
+		return fmt.Errorf("cannot redo %s: %w", e, err)
+	}
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, e)
+	return nil
+}
+
+// commit changes which succeeded as a whole, recording them as one edit and
+// notifying listeners
+func (d *Document) commit(changes []change) {
+	h := &d.history
+	if n := len(h.open); n > 0 {
+		h.open[n-1] = append(h.open[n-1], changes...)
+		return
+	}
+	if !h.replaying && len(changes) > 0 {
+		e := changes[0].edit
+		if len(changes) > 1 {
+			e = &Edit{description: e.description}
+			for _, c := range changes {
+				e.nested = append(e.nested, c.edit)
+			}
+		}
+		h.done = append(h.done, e)
+		h.undone = nil
+	}
+	for _, c := range changes {
+		d.notify(c.event)
+	}
+}
+
+// compound edit consisting of the changes made while running `f`, which has
+// to roll back all of them if it fails. it is described by its first change.
+func (d *Document) compound(f func() error) error {
+	h := &d.history
+	h.open = append(h.open, nil)
+	err := f()
+	changes := h.open[len(h.open)-1]
+	h.open = h.open[:len(h.open)-1]
+	if err != nil {
+		return err
+	}
+	d.commit(changes)
+	return nil
+}
+
+// record a change, if it affects an item inserted into its document
+func record(e Event, undo, redo func() error) {
+	item := e.owner()
+	if !isInserted(item) {
+		return
+	}
+	d := item.(interface{ Document() *Document }).Document()
+	d.commit([]change{{e, &Edit{description: e.String(), undo: undo, redo: redo}}})
+}
+
+type removable interface {
+	Remove() error
+}
+
+// recordInsert of an item at an index, which `insert` puts back in place
+func recordInsert(parent interface{}, item removable, index int, insert func() error) {
+	record(&Inserted{item, parent, index}, item.Remove, insert)
+}
+
+// recordRemove of an item from an index, which `insert` puts back in place
+func recordRemove(parent interface{}, item removable, index int, insert func() error) {
+	record(&Removed{item, parent, index}, insert, item.Remove)
+}
+
+// describe an item by its kind and name
+func describe(item interface{}) string {
+	switch v := item.(type) {
+	case *Document:
+		return "document"
+	case *Package:
+		return "package"
+	case *Import:
+		return fmt.Sprintf("import %q", v.path.value)
+	case *Service:
+		return fmt.Sprint("service ", v.label.value)
+	case *RPC:
+		return fmt.Sprint("rpc ", qualify(v.parent.label.value, v.label.value))
+	case *MessageType:
+		kind := "response"
+		if v == &v.parent.request {
+			kind = "request"
+		}
+		return fmt.Sprintf("%s of %s", kind, describe(v.parent))
+	case *message:
+		return fmt.Sprint("message ", relativeName(v))
+	case *enum:
+		return fmt.Sprint("enum ", qualify(relativeName(v.parent), v.label.value))
+	case *Field:
+		return fmt.Sprint("field ", qualify(relativeName(v.parent), v.label.value))
+	case *Map:
+		return fmt.Sprint("field ", qualify(relativeName(v.parent), v.label.value))
+	case *OneOf:
+		return fmt.Sprint("oneof ", qualify(relativeName(v.parent), v.label.value))
+	case *OneOfField:
+		return fmt.Sprint("field ", qualify(relativeName(v.parent.parent), v.label.value))
+	case *Variant:
+		return fmt.Sprint("variant ", qualify(relativeName(v.parent.parent), v.label.value))
+	case *KeyType:
+		return fmt.Sprint("key type of ", describe(v.parent))
+	case *ReservedNumber:
+		return fmt.Sprintf("reserved number %d in %s", *v.number.value, describe(v.parent))
+	case *ReservedRange:
+		return fmt.Sprintf("reserved range %d to %d in %s", *v.start.value, *v.end.value, describe(v.parent))
+	case *ReservedLabel:
+		return fmt.Sprintf("reserved label %q in %s", v.label.value, describe(v.parent))
+	default:
+		panic(fmt.Sprintf("unhandled item type %T", v))
+	}
+}
+
+// relativeName of a definition container within its package
+func relativeName(c DefinitionContainer) string {
+	switch v := c.(type) {
+	case *Document:
+		return ""
+	case Message:
+		return qualify(relativeName(v.Parent()), v.Label().Get())
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", v))
+	}
+}
+
+// flagName of a flag, as declared in the document
+func flagName(f *Flag) string {
+	switch p := f.parent.(type) {
+	case *Document:
+		if f == &p.reserveRemoved {
+			return "reserve removed"
+		}
+	case *Import:
+		if f == &p.public {
+			return "public"
+		}
+	case *enum:
+		if f == &p.allowAlias {
+			return "allow_alias"
+		}
+	case *Field:
+		if f == &p.repeated {
+			return "repeated"
+		}
+	case *MessageType:
+		return "stream"
+	}
+	return "deprecated"
+}
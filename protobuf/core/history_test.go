@@ -0,0 +1,176 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func descriptions(edits []*Edit) []string {
+	out := make([]string, len(edits))
+	for i, e := range edits {
+		out[i] = e.String()
+	}
+	return out
+}
+
+func TestUndoRedo(t *testing.T) {
+	input := `syntax = "proto3";
+
+message A {
+  string foo = 1;
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	done, undone := d.History()
+	assert.Empty(t, done, "parsing is not recorded")
+	assert.Empty(t, undone)
+	assert.NotNil(t, d.Undo())
+
+	a := FindMessage(d, "A")
+	foo := a.Fields()[0].(*Field)
+	require.Nil(t, foo.Label().Set("bar"))
+	require.Nil(t, foo.Number().Set(2))
+	require.Nil(t, foo.Repeated().Set(true))
+	require.Nil(t, foo.Type().Set(Bytes))
+	require.Nil(t, foo.Options().Packed().Set(false))
+	b := a.NewField()
+	require.Nil(t, b.Label().Set("baz"))
+	require.Nil(t, b.Number().Set(3))
+	require.Nil(t, b.Type().Set(a))
+	require.Nil(t, b.InsertIntoParentAt(0))
+	require.Nil(t, b.Position().MoveDown())
+	require.Nil(t, b.Remove())
+
+	done, _ = d.History()
+	assert.Equal(t, []string{
+		"set label of field A.bar",
+		"set number of field A.bar",
+		"set repeated of field A.bar",
+		"set type of field A.bar",
+		"set option packed of field A.bar",
+		"insert field A.baz",
+		"move field A.baz",
+		"remove field A.baz",
+	}, descriptions(done))
+
+	modified := d.String()
+	for range done {
+		require.Nil(t, d.Undo())
+	}
+	assert.Equal(t, input, d.String())
+	assert.NotNil(t, d.Undo(), "nothing left to undo")
+	done, undone = d.History()
+	assert.Empty(t, done)
+	assert.Equal(t, "set label of field A.bar", undone[0].String(), "described as of the edit")
+
+	for range undone {
+		require.Nil(t, d.Redo())
+	}
+	assert.Equal(t, modified, d.String())
+	assert.NotNil(t, d.Redo(), "nothing left to redo")
+
+	require.Nil(t, d.Undo())
+	require.Nil(t, foo.Label().Set("qux"))
+	_, undone = d.History()
+	assert.Empty(t, undone, "new edits discard undone ones")
+}
+
+func TestUndoValidation(t *testing.T) {
+	w := NewWorkspace()
+	a, err := w.Parse("a.proto", strings.NewReader(`syntax = "proto3";
+package p;
+message Foo {}`))
+	require.Nil(t, err)
+	b, err := w.Parse("b.proto", strings.NewReader(`syntax = "proto3";
+package p;
+import "a.proto";`))
+	require.Nil(t, err)
+
+	require.Nil(t, FindMessage(a, "Foo").Label().Set("Bar"))
+	m := b.NewMessage()
+	require.Nil(t, m.Label().Set("Foo"))
+	require.Nil(t, m.InsertIntoParent())
+
+	err = a.Undo()
+	var duplicate *DuplicateLabelError
+	require.True(t, errors.As(err, &duplicate), "undone rename collides with newer label")
+	assert.Equal(t, `cannot undo set label of message Bar: label "Foo" already declared in b.proto`, err.Error())
+	assert.NotNil(t, FindMessage(a, "Bar"))
+	done, _ := a.History()
+	assert.Len(t, done, 1, "failed undo stays in history")
+
+	require.Nil(t, b.Undo())
+	require.Nil(t, a.Undo())
+	assert.NotNil(t, FindMessage(a, "Foo"))
+}
+
+func TestUndoRemoveWithReservations(t *testing.T) {
+	input := `syntax = "proto3";
+
+message A {
+  string foo = 1;
+  int32 bar = 2;
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	require.Nil(t, d.ReserveRemoved().Set(true))
+	d.ClearHistory()
+
+	a := FindMessage(d, "A")
+	require.Nil(t, a.Fields()[0].(*Field).Remove())
+	reserved := d.String()
+	done, _ := d.History()
+	require.Len(t, done, 1, "reservations are part of the removal")
+	assert.Equal(t, "remove field A.foo", done[0].String())
+	assert.Len(t, a.Fields(), 3)
+
+	require.Nil(t, d.Undo())
+	assert.Equal(t, input, d.String())
+	require.Nil(t, d.Redo())
+	assert.Equal(t, reserved, d.String())
+}
+
+func TestUndoDefinitions(t *testing.T) {
+	d := NewDocument()
+	require.Nil(t, d.Package().Set("pkg"))
+	m := d.NewMessage()
+	require.Nil(t, m.Label().Set("A"))
+	require.Nil(t, m.InsertIntoParent())
+	a := FindMessage(d, "A")
+	e := a.NewEnum()
+	require.Nil(t, e.Label().Set("E"))
+	require.Nil(t, e.InsertIntoParent())
+	v := FindEnum(a, "E").NewVariant()
+	require.Nil(t, v.Label().Set("NONE"))
+	require.Nil(t, v.Number().Set(0))
+	require.Nil(t, v.InsertIntoParent())
+	require.Nil(t, FindEnum(a, "E").Remove())
+
+	done, _ := d.History()
+	assert.Equal(t, []string{
+		"set label of package",
+		"insert message A",
+		"insert enum A.E",
+		"insert variant A.NONE",
+		"remove enum A.E",
+	}, descriptions(done))
+
+	for range done {
+		require.Nil(t, d.Undo())
+	}
+	assert.Equal(t, "", d.Package().Get())
+	assert.Empty(t, d.Messages())
+	for range done {
+		require.Nil(t, d.Redo())
+	}
+	assert.Equal(t, "pkg", d.Package().Get())
+	require.Len(t, d.Messages(), 1)
+	assert.Empty(t, a.Enums())
+	require.Nil(t, d.Undo())
+	require.Len(t, a.Enums(), 1)
+	assert.Len(t, a.Enums()[0].Fields(), 1)
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 type Label struct {
@@ -20,33 +21,92 @@ func (l Label) Get() string {
 }
 
 func (l *Label) Set(label string) error {
+	safety := l.parent.Document().safety()
 	old := l.value
 	l.value = label
-	if err := l.validate(); err != nil {
+	err := l.validate()
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
 		l.value = old
 		return err
 	}
+	record(&LabelChanged{l, old, label}, func() error {
+		if p, ok := l.parent.(*Package); ok && old == "" {
+			return p.Unset()
+		}
+		return l.Set(old)
+	}, func() error {
+		return l.Set(label)
+	})
 	return nil
 }
 
 func (l *Label) validate() error {
 	if l.value == "" {
-		return fmt.Errorf("label not set")
+		return &NotSetError{l}
+	}
+	var err error
+	switch l.parent.(type) {
+	case *Package:
+		err = validateFullIdentifier(l)
+	case *Import:
+		err = validatePath(l)
+	default:
+		err = validateIdentifier(l)
 	}
-	if err := validateIdentifier(l.value); err != nil {
+	if err != nil {
 		return err
 	}
 	return l.parent.validateLabel(l)
 }
 
-func validateIdentifier(value string) (err error) {
-	// TODO: return typed error
-	pattern := "[a-zA-Z]([0-9a-zA-Z_])*"
-	regex := regexp.MustCompile(fmt.Sprintf("^%s$", pattern))
-	if !regex.MatchString(value) {
-		err = fmt.Errorf("Identifier must match %s", pattern)
+const (
+	identifierPattern     = "[a-zA-Z]([0-9a-zA-Z_])*"
+	fullIdentifierPattern = "[a-zA-Z]([0-9a-zA-Z_])*(\\.[a-zA-Z]([0-9a-zA-Z_])*)*"
+	pathPattern           = `[^/\s"'\\]+(/[^/\s"'\\]+)*`
+)
+
+var (
+	identifierRegex     = regexp.MustCompile(fmt.Sprintf("^%s$", identifierPattern))
+	fullIdentifierRegex = regexp.MustCompile(fmt.Sprintf("^%s$", fullIdentifierPattern))
+	pathRegex           = regexp.MustCompile(fmt.Sprintf("^%s$", pathPattern))
+)
+
+func validateIdentifier(l *Label) error {
+	if !identifierRegex.MatchString(l.value) {
+		return &InvalidIdentifierError{Label: l, Value: l.value, Pattern: identifierPattern}
 	}
-	return
+	return nil
+}
+
+func validateFullIdentifier(l *Label) error {
+	if !fullIdentifierRegex.MatchString(l.value) {
+		return &InvalidIdentifierError{Label: l, Value: l.value, Pattern: fullIdentifierPattern}
+	}
+	return nil
+}
+
+// validatePath of an imported file. it must be relative and use forward
+// slashes, and we disallow characters which would have to be escaped.
+func validatePath(l *Label) error {
+	if err := checkPath(l.value); err != "" {
+		return &InvalidPathError{Label: l, Value: l.value, Reason: err}
+	}
+	return nil
+}
+
+func checkPath(value string) string {
+	if !pathRegex.MatchString(value) {
+		return fmt.Sprintf("must match %s", pathPattern)
+	}
+	for _, part := range strings.Split(value, "/") {
+		if part == "." || part == ".." {
+			return fmt.Sprintf("must not contain %q", part)
+		}
+	}
+	return ""
 }
 
 func (l Label) Parent() Labelled {
@@ -57,6 +117,10 @@ func (l Label) String() string {
 	return l.parent.Document().Printer.Label(&l)
 }
 
-func (l *Label) hasLabel(other *Label) bool {
-	return l != other && l.value == other.value
+// findLabel returns the label if it conflicts with another one
+func (l *Label) findLabel(other *Label) *Label {
+	if l != other && l.value == other.value {
+		return l
+	}
+	return nil
 }
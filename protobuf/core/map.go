@@ -1,9 +1,5 @@
 package core
 
-import (
-	"errors"
-)
-
 type KeyType struct {
 	value  MapKeyType
 	parent *Map
@@ -14,8 +10,20 @@ func (t KeyType) Get() MapKeyType {
 }
 
 func (t *KeyType) Set(value MapKeyType) error {
+	safety := t.Document().safety()
+	old := t.value
 	t.value = value
-	// TODO: checks in "safe mode"
+	if err := safety.check(); err != nil {
+		t.value = old
+		return err
+	}
+	if old != nil {
+		record(&KeyTypeChanged{t, old, value}, func() error {
+			return t.Set(old)
+		}, func() error {
+			return t.Set(value)
+		})
+	}
 	return nil
 }
 
@@ -43,7 +51,19 @@ func (m *Map) KeyType() *KeyType {
 }
 
 func (m *Map) InsertIntoParent() error {
-	return m.parent.insertField(m)
+	return m.parent.insertField(m, len(m.parent.fields))
+}
+
+func (m *Map) InsertIntoParentAt(index int) error {
+	return m.parent.insertField(m, index)
+}
+
+func (m *Map) Remove() error {
+	return m.parent.removeField(m)
+}
+
+func (m *Map) Position() *Position {
+	return &Position{m, func() interface{} { return m.parent.fields }}
 }
 
 func (m *Map) Parent() Message {
@@ -54,8 +74,8 @@ func (m *Map) Document() *Document {
 	return m.parent.Document()
 }
 
-func (m Map) String() string {
-	return m.Document().Printer.Map(&m)
+func (m *Map) String() string {
+	return m.Document().Printer.Map(m)
 }
 
 func (m *Map) validateAsMessageField() error {
@@ -63,7 +83,7 @@ func (m *Map) validateAsMessageField() error {
 		return err
 	}
 	if m.keyType.value == nil {
-		return errors.New("map value type not set")
+		return &NotSetError{&m.keyType}
 	}
 	return nil
 }
@@ -79,3 +99,11 @@ func (m *Map) validateNumber(n FieldNumber) error {
 func (m *Map) validateFlag(*Flag) error {
 	return nil
 }
+
+func (m *Map) validateType(*Type) error {
+	return m.options.validateMap()
+}
+
+func (m *Map) validateOption(Option) error {
+	return m.options.validateMap()
+}
@@ -1,12 +1,14 @@
 package core
 
 import (
-	"errors"
 	"fmt"
 )
 
 type Message interface {
 	Label() *Label
+	Deprecated() *Flag
+	Options() *MessageOptions
+	Comments() *Comments
 	Fields() []MessageField
 
 	NewField() *Field
@@ -24,13 +26,19 @@ type Message interface {
 
 	Parent() DefinitionContainer
 	Document() *Document
+	Position() *Position
+	MoveInto(DefinitionContainer) error
+	MoveIntoAt(DefinitionContainer, int) error
+	Remove() error
 	String() string
 
 	validate() error
-	hasLabel(*Label) bool
+	findLabel(*Label) *Label
 	validateLabel(*Label) error
 	validateNumber(FieldNumber) error
-	insertField(MessageField) error
+	insertField(MessageField, int) error
+	insertMessage(*message, int) error
+	insertEnum(*enum, int) error
 
 	addReference(MessageReference)
 	removeReference(MessageReference)
@@ -40,9 +48,12 @@ type Message interface {
 
 type message struct {
 	label      Label
-	fields     map[MessageField]struct{}
-	messages   map[*message]struct{}
-	enums      map[*enum]struct{}
+	deprecated Flag
+	options    MessageOptions
+	comments   Comments
+	fields     []MessageField
+	messages   []*message
+	enums      []*enum
 	references map[MessageReference]struct{}
 	parent     DefinitionContainer
 
@@ -52,28 +63,35 @@ type message struct {
 type MessageField interface {
 	String() string
 	validateAsMessageField() error
-	hasLabel(*Label) bool
-	hasNumber(FieldNumber) bool
+	findLabel(*Label) *Label
+	findNumber(FieldNumber) FieldNumber
 }
 
 func (m *message) Label() *Label {
 	return &m.label
 }
 
+func (m *message) Deprecated() *Flag {
+	return &m.deprecated
+}
+
+func (m *message) Options() *MessageOptions {
+	return &m.options
+}
+
+func (m *message) Comments() *Comments {
+	return &m.comments
+}
+
 func (m message) Fields() (out []MessageField) {
 	out = make([]MessageField, len(m.fields))
-	i := 0
-	for f := range m.fields {
-		out[i] = f
-		i++
-	}
+	copy(out, m.fields)
 	return
 }
 
 func (m message) Messages() (out []Message) {
 	out = make([]Message, len(m.messages))
-	i := 0
-	for d := range m.messages {
+	for i, d := range m.messages {
 		out[i] = d
 	}
 	return
@@ -81,62 +99,76 @@ func (m message) Messages() (out []Message) {
 
 func (m message) Enums() (out []Enum) {
 	out = make([]Enum, len(m.enums))
-	i := 0
-	for e := range m.enums {
+	for i, e := range m.enums {
 		out[i] = e
 	}
 	return
 }
 
-func (m *message) insertField(f MessageField) error {
-	if m.fields == nil {
-		m.fields = make(map[MessageField]struct{})
-	}
-	if _, ok := m.fields[f]; ok {
+func (m *message) insertField(f MessageField, index int) error {
+	if indexOf(m.fields, f) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(m.fields)); err != nil {
+		return err
+	}
 	if err := f.validateAsMessageField(); err != nil {
 		return err
 	}
-	m.fields[f] = struct{}{}
+	safety := m.Document().safety()
+	m.fields = append(m.fields, f)
+	move(m.fields, len(m.fields)-1, index)
+	if err := safety.check(); err != nil {
+		move(m.fields, index, len(m.fields)-1)
+		m.fields = m.fields[:len(m.fields)-1]
+		return err
+	}
+	recordInsert(m, f.(removable), index, func() error {
+		return m.insertField(f, index)
+	})
 	return nil
 }
 
-func (m *message) insertEnum(e *enum) error {
-	if m.enums == nil {
-		m.enums = make(map[*enum]struct{})
-	}
-	if _, ok := m.enums[e]; ok {
+func (m *message) insertEnum(e *enum, index int) error {
+	if indexOf(m.enums, e) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(m.enums)); err != nil {
+		return err
+	}
 	if err := e.validate(); err != nil {
 		return err
 	}
-	m.enums[e] = struct{}{}
+	m.enums = append(m.enums, e)
+	move(m.enums, len(m.enums)-1, index)
+	recordInsert(m, e, index, func() error {
+		return m.insertEnum(e, index)
+	})
 	return nil
 }
 
-func (m *message) insertMessage(n *message) error {
-	if m.messages == nil {
-		m.messages = make(map[*message]struct{})
-	}
-	if _, ok := m.messages[n]; ok {
+func (m *message) insertMessage(n *message, index int) error {
+	if indexOf(m.messages, n) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(m.messages)); err != nil {
+		return err
+	}
 	if err := n.validate(); err != nil {
 		return err
 	}
-	m.messages[n] = struct{}{}
+	m.messages = append(m.messages, n)
+	move(m.messages, len(m.messages)-1, index)
+	recordInsert(m, n, index, func() error {
+		return m.insertMessage(n, index)
+	})
 	return nil
 }
 
 func (m *message) addReference(t MessageReference) {
-	// TODO: check if reference is already in document, otherwise we would have
-	// to do it in bulk when it gets relevant. it is actually even more
-	// complicated: once something containing a reference is inserted, that
-	// reference must be added, too. maybe there is no point in holding these
-	// references after all, as managing them is too much of a hassle, and we
-	// should collect them from the document on demand.
+	// references are also recorded for tentative items, and only filtered by
+	// whether they are part of the document when it matters, which is on
+	// removal.
 	if m.references == nil {
 		m.references = make(map[MessageReference]struct{})
 	}
@@ -158,6 +190,7 @@ func (m *message) NewField() *Field {
 	f.deprecated.parent = f
 	f._type.parent = f
 	f.repeated.parent = f
+	f.options.init(f)
 	return f
 }
 
@@ -168,6 +201,7 @@ func (m *message) NewMap() *Map {
 	f.deprecated.parent = f
 	f._type.parent = f
 	f.keyType.parent = f
+	f.options.init(f)
 	return f
 }
 
@@ -212,6 +246,19 @@ func (m *message) Parent() DefinitionContainer {
 	return m.parent
 }
 
+func (m *message) Position() *Position {
+	return &Position{m, func() interface{} {
+		switch p := m.parent.(type) {
+		case *Document:
+			return p.messages
+		case *message:
+			return p.messages
+		default:
+			panic(fmt.Sprintf("unhandled definition container type %T", p))
+		}
+	}}
+}
+
 func (m message) Document() *Document {
 	return m.parent.Document()
 }
@@ -220,8 +267,8 @@ func (m *message) String() string {
 	return m.Document().Printer.Message(m)
 }
 
-func (m message) hasLabel(l *Label) bool {
-	return m.label.hasLabel(l)
+func (m *message) findLabel(l *Label) *Label {
+	return m.label.findLabel(l)
 }
 
 func (m *message) validateLabel(l *Label) error {
@@ -229,54 +276,77 @@ func (m *message) validateLabel(l *Label) error {
 	case &m.label:
 		return m.parent.validateLabel(l)
 	default:
-		for f := range m.fields {
-			if f.hasLabel(l) {
-				// TODO: return error type with reference to other declaration
-				return fmt.Errorf("label %q already declared", l.value)
+		for _, f := range m.fields {
+			if existing := f.findLabel(l); existing != nil {
+				return &DuplicateLabelError{existing, l, l.value}
 			}
 		}
-		for d := range m.messages {
-			// TODO: return error type with reference to other declaration
-			if d.label.hasLabel(l) {
-				return fmt.Errorf("label %q already declared", l.value)
+		for _, d := range m.messages {
+			if existing := d.findLabel(l); existing != nil {
+				return &DuplicateLabelError{existing, l, l.value}
 			}
 		}
-		for e := range m.enums {
-			// TODO: return error type with reference to other declaration
-			if e.label.hasLabel(l) {
-				return fmt.Errorf("label %q already declared", l.value)
+		for _, e := range m.enums {
+			if existing := e.findLabel(l); existing != nil {
+				return &DuplicateLabelError{existing, l, l.value}
 			}
 		}
 	}
 	return nil
 }
 
+func (m *message) validateFlag(*Flag) error {
+	return nil
+}
+
+func (m *message) validateOption(Option) error {
+	return nil
+}
+
+// https://developers.google.com/protocol-buffers/docs/proto3#assigning-field-numbers
+const (
+	minFieldNumber = 1
+	maxFieldNumber = 1<<29 - 1
+	// numbers reserved for the protobuf implementation, which only reserved
+	// numbers and ranges may cover
+	firstImplementationNumber = 19000
+	lastImplementationNumber  = 19999
+)
+
 func (m message) validateNumber(n FieldNumber) error {
-	// TODO: check valid values
-	// https://developers.google.com/protocol-buffers/docs/proto3#assigning-field-numbers
 	switch v := n.(type) {
 	case *Number:
-		if *v.value < 1 {
-			return errors.New("message field number must be >= 1")
+		if err := checkFieldNumber(v); err != nil {
+			return err
+		}
+		if !reserved(v) && *v.value >= firstImplementationNumber && *v.value <= lastImplementationNumber {
+			return &ImplementationReservedError{v, *v.value}
 		}
 	case *ReservedRange:
-		if *v.start.value < 1 {
-			return errors.New("message field numbers must be >= 1")
+		if err := checkFieldNumber(&v.start); err != nil {
+			return err
 		}
-		if *v.end.value < 1 {
-			return errors.New("message field numbers must be >= 1")
+		if err := checkFieldNumber(&v.end); err != nil {
+			return err
 		}
 	default:
 		panic(fmt.Sprintf("unhandled field number type %T", v))
 	}
-	for f := range m.fields {
-		if f.hasNumber(n) {
-			return fmt.Errorf("field number %s already in use", n)
+	for _, f := range m.fields {
+		if existing := f.findNumber(n); existing != nil {
+			return numberConflict(existing, n)
 		}
 	}
 	return nil
 }
 
+func checkFieldNumber(n *Number) error {
+	if *n.value < minFieldNumber || *n.value > maxFieldNumber {
+		return &NumberOutOfRangeError{n, *n.value, minFieldNumber, maxFieldNumber}
+	}
+	return nil
+}
+
 func (m *message) validate() (err error) {
 	return m.label.validate()
 }
@@ -291,7 +361,11 @@ func (m *NewMessage) Label() *Label {
 }
 
 func (m *NewMessage) InsertIntoParent() error {
-	return m.parent.insertMessage(m.toMessage())
+	return m.parent.insertMessage(m.toMessage(), len(m.parent.Messages()))
+}
+
+func (m *NewMessage) InsertIntoParentAt(index int) error {
+	return m.parent.insertMessage(m.toMessage(), index)
 }
 
 func (m *NewMessage) toMessage() *message {
@@ -302,6 +376,8 @@ func (m *NewMessage) toMessage() *message {
 		},
 	}
 	mm.label.parent = mm
+	mm.deprecated.parent = mm
+	mm.options.init(mm)
 	return mm
 }
 
@@ -320,3 +396,14 @@ func (m *NewMessage) String() string {
 func (m *NewMessage) validateLabel(l *Label) error {
 	return m.parent.validateLabel(l)
 }
+
+// FindMessage in a container by its label, such as one inserted as a
+// `NewMessage`. it returns nil if there is none.
+func FindMessage(c DefinitionContainer, label string) Message {
+	for _, m := range c.Messages() {
+		if m.Label().Get() == label {
+			return m
+		}
+	}
+	return nil
+}
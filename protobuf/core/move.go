@@ -0,0 +1,199 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// moving a message or an enum into another container keeps the item itself,
+// unlike inserting a new one, so references to it and to everything nested in
+// it keep pointing at it. they are printed with names relative to the new
+// scope. labels are validated in the target scope before the item is
+// attached there, and the labels of variants along with that of their enum,
+// since they are scoped like their enum.
+//
+// within a workspace, definitions can also be moved into another document. the
+// move fails if any reference to or from the moved item would not be visible
+// any more, or in safe mode if either document would become incompatible with
+// its baseline.
+
+func (m *message) MoveInto(c DefinitionContainer) error {
+	if c == m.parent {
+		return m.Position().Set(len(m.parent.Messages()) - 1)
+	}
+	return m.MoveIntoAt(c, len(c.Messages()))
+}
+
+func (m *message) MoveIntoAt(c DefinitionContainer, index int) error {
+	from := m.parent
+	if c == from {
+		return m.Position().Set(index)
+	}
+	if err := checkMove(m, c); err != nil {
+		return err
+	}
+	source, target := messagesOf(from), messagesOf(c)
+	if err := checkIndex(index, len(*target)); err != nil {
+		return err
+	}
+	safe := moveSafety(from, c)
+	old := indexOf(*source, m)
+	*source = append((*source)[:old:old], (*source)[old+1:]...)
+	m.parent = c
+	err := m.label.validate()
+	if err == nil {
+		*target = append(*target, m)
+		move(*target, len(*target)-1, index)
+		if err = validateMove(from, c); err == nil {
+			err = safe()
+		}
+		if err != nil {
+			*target = append((*target)[:index:index], (*target)[index+1:]...)
+		}
+	}
+	if err != nil {
+		m.parent = from
+		*source = append(*source, m)
+		move(*source, len(*source)-1, old)
+		return err
+	}
+	record(&MovedInto{m, from, c, old, index}, func() error {
+		return m.MoveIntoAt(from, old)
+	}, func() error {
+		return m.MoveIntoAt(c, index)
+	})
+	return nil
+}
+
+func (e *enum) MoveInto(c DefinitionContainer) error {
+	if c == e.parent {
+		return e.Position().Set(len(e.parent.Enums()) - 1)
+	}
+	return e.MoveIntoAt(c, len(c.Enums()))
+}
+
+func (e *enum) MoveIntoAt(c DefinitionContainer, index int) error {
+	from := e.parent
+	if c == from {
+		return e.Position().Set(index)
+	}
+	if err := checkMove(e, c); err != nil {
+		return err
+	}
+	source, target := enumsOf(from), enumsOf(c)
+	if err := checkIndex(index, len(*target)); err != nil {
+		return err
+	}
+	safe := moveSafety(from, c)
+	old := indexOf(*source, e)
+	*source = append((*source)[:old:old], (*source)[old+1:]...)
+	e.parent = c
+	err := e.validateLabels()
+	if err == nil {
+		*target = append(*target, e)
+		move(*target, len(*target)-1, index)
+		if err = validateMove(from, c); err == nil {
+			err = safe()
+		}
+		if err != nil {
+			*target = append((*target)[:index:index], (*target)[index+1:]...)
+		}
+	}
+	if err != nil {
+		e.parent = from
+		*source = append(*source, e)
+		move(*source, len(*source)-1, old)
+		return err
+	}
+	record(&MovedInto{e, from, c, old, index}, func() error {
+		return e.MoveIntoAt(from, old)
+	}, func() error {
+		return e.MoveIntoAt(c, index)
+	})
+	return nil
+}
+
+// validateLabels of an enum and its variants in the scope of its parent
+func (e *enum) validateLabels() error {
+	if err := e.label.validate(); err != nil {
+		return err
+	}
+	for _, f := range e.fields {
+		if v, ok := f.(*Variant); ok {
+			if err := e.parent.validateLabel(&v.label); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkMove of a definition into a container, which must not be nested in it
+func checkMove(item interface{}, c DefinitionContainer) error {
+	if !isInserted(item) || !isInserted(c) {
+		return errNotInserted
+	}
+	for p := c; ; {
+		if p == item {
+			return errors.New("cannot move a definition into itself")
+		}
+		m, ok := p.(Message)
+		if !ok {
+			return nil
+		}
+		p = m.Parent()
+	}
+}
+
+// validateMove of a definition between documents, after which all references
+// to and from it must still be visible
+func validateMove(from, to DefinitionContainer) error {
+	source, target := from.Document(), to.Document()
+	if source == target {
+		return nil
+	}
+	for _, d := range append([]*Document{source, target}, source.dependents()...) {
+		if err := d.validateReferences(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSafety checks a move in safe mode, in the document a definition is
+// moved out of and in the one it is moved into
+func moveSafety(from, to DefinitionContainer) func() error {
+	source := from.Document().safety()
+	var target *safety
+	if to.Document() != from.Document() {
+		target = to.Document().safety()
+	}
+	return func() error {
+		if err := source.check(); err != nil {
+			return err
+		}
+		return target.check()
+	}
+}
+
+func messagesOf(c DefinitionContainer) *[]*message {
+	switch v := c.(type) {
+	case *Document:
+		return &v.messages
+	case *message:
+		return &v.messages
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", v))
+	}
+}
+
+func enumsOf(c DefinitionContainer) *[]*enum {
+	switch v := c.(type) {
+	case *Document:
+		return &v.enums
+	case *message:
+		return &v.enums
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", v))
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMove(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+message A {
+  B b = 1;
+
+  enum E {
+    NONE = 0;
+  }
+}
+
+message B {
+  A.E e = 1;
+}`))
+	require.Nil(t, err)
+	var events []string
+	d.Subscribe(func(e Event) {
+		events = append(events, e.String())
+	})
+	a, b := d.Messages()[0], d.Messages()[1]
+	require.Nil(t, b.MoveInto(a))
+	require.Nil(t, a.Enums()[0].MoveIntoAt(d, 0))
+	assert.Equal(t, `syntax = "proto3";
+
+enum E {
+  NONE = 0;
+}
+
+message A {
+  B b = 1;
+
+  message B {
+    E e = 1;
+  }
+}`, d.String())
+	assert.Equal(t, []string{"move message A.B", "move enum E"}, events)
+	assert.Equal(t, b, a.Messages()[0], "moved item is kept")
+
+	require.Nil(t, d.Undo())
+	require.Nil(t, d.Undo())
+	assert.Equal(t, `syntax = "proto3";
+
+message A {
+  B b = 1;
+
+  enum E {
+    NONE = 0;
+  }
+}
+
+message B {
+  A.E e = 1;
+}`, d.String())
+	require.Nil(t, d.Redo())
+	assert.Equal(t, a, b.Parent())
+}
+
+func TestMoveInvalid(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+message A {
+  string B = 1;
+
+  message C {}
+
+  enum F {
+    NONE = 0;
+  }
+}
+
+message B {}
+
+enum E {
+  NONE = 0;
+}`))
+	require.Nil(t, err)
+	a, b := d.Messages()[0], d.Messages()[1]
+	c := a.Messages()[0]
+	before := d.String()
+
+	assert.NotNil(t, b.MoveInto(a), "label is used by a field")
+	assert.NotNil(t, d.Enums()[0].MoveInto(a), "variant label is used")
+	assert.NotNil(t, a.MoveInto(c), "cannot move into a nested message")
+	assert.NotNil(t, a.MoveInto(a), "cannot move into itself")
+	assert.NotNil(t, c.MoveIntoAt(b, 1), "index out of range")
+	assert.NotNil(t, d.NewMessage().Label().Set("B"))
+	assert.Equal(t, before, d.String(), "failed moves leave the document unchanged")
+	done, _ := d.History()
+	assert.Empty(t, done)
+
+	require.Nil(t, c.MoveInto(d))
+	assert.Equal(t, d, c.Parent())
+	assert.NotNil(t, d.NewMessage().Label().Set("C"), "label is used in the new scope")
+	require.Nil(t, a.NewMessage().Label().Set("C"), "label is free in the old scope")
+}
+
+func TestMoveAcrossDocuments(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3";
+package a;
+message A {}
+message Used {}
+message Ref {
+  Used used = 1;
+}`)
+	b := parseInto(t, w, "b.proto", `syntax = "proto3";
+package b;
+import "a.proto";
+message B {
+  a.A a = 1;
+}`)
+	parseInto(t, w, "c.proto", `syntax = "proto3";
+import "b.proto";
+message C {}`)
+
+	assert.NotNil(t, a.Messages()[1].MoveInto(b), "a.proto would reference b.proto")
+	assert.Equal(t, "Used", a.Messages()[1].Label().Get())
+
+	m := a.Messages()[0]
+	require.Nil(t, m.MoveInto(b.Messages()[0]))
+	assert.Equal(t, b, m.Document())
+	assert.Contains(t, b.String(), `
+message B {
+  A a = 1;
+
+  message A {}
+}`)
+	assert.NotContains(t, a.String(), "message A")
+	assert.NotNil(t, m.MoveInto(w.Document("c.proto")), "b.proto does not import c.proto")
+	require.Nil(t, b.Undo())
+	assert.Equal(t, a, m.Document())
+}
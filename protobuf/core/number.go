@@ -4,8 +4,10 @@ import (
 	"fmt"
 )
 
+// Number of a field, variant or reservation. numbers are signed to allow for
+// negative enum values, while message field numbers are always positive.
 type Number struct {
-	value  *uint
+	value  *int32
 	parent Numbered
 }
 
@@ -18,7 +20,7 @@ type FieldNumber interface {
 	intersects(FieldNumber) bool
 }
 
-func (n Number) Get() *uint {
+func (n Number) Get() *int32 {
 	if n.value == nil {
 		return nil
 	}
@@ -26,7 +28,8 @@ func (n Number) Get() *uint {
 	return &out
 }
 
-func (n *Number) Set(value uint) (err error) {
+func (n *Number) Set(value int32) (err error) {
+	safety := n.parent.Document().safety()
 	if n.value == nil {
 		n.value = &value
 		defer func() {
@@ -40,15 +43,24 @@ func (n *Number) Set(value uint) (err error) {
 		defer func() {
 			if err != nil {
 				*n.value = old
+				return
 			}
+			record(&NumberChanged{n, old, value}, func() error {
+				return n.Set(old)
+			}, func() error {
+				return n.Set(value)
+			})
 		}()
 	}
-	return n.validate()
+	if err = n.validate(); err != nil {
+		return
+	}
+	return safety.check()
 }
 
 func (n *Number) validate() error {
 	if n.value == nil {
-		return fmt.Errorf("number not set")
+		return &NotSetError{n}
 	}
 	return n.parent.validateNumber(n)
 }
@@ -61,8 +73,12 @@ func (n Number) String() string {
 	return n.parent.Document().Printer.Number(&n)
 }
 
-func (n *Number) hasNumber(other FieldNumber) bool {
-	return n != other && n.intersects(other)
+// findNumber returns the number if it conflicts with another one
+func (n *Number) findNumber(other FieldNumber) FieldNumber {
+	if n != other && n.intersects(other) {
+		return n
+	}
+	return nil
 }
 
 func (n *Number) intersects(other FieldNumber) bool {
@@ -5,35 +5,50 @@ import (
 )
 
 type OneOf struct {
-	label  Label
-	fields map[*OneOfField]struct{}
-	parent *message
+	label    Label
+	comments Comments
+	fields   []*OneOfField
+	parent   *message
 }
 
 func (o *OneOf) Label() *Label {
 	return &o.label
 }
 
+func (o *OneOf) Comments() *Comments {
+	return &o.comments
+}
+
 func (o *OneOf) NewField() *OneOfField {
 	v := &OneOfField{parent: o}
 	v.typedField.label.parent = v
 	v.typedField.number.parent = v
 	v.typedField.deprecated.parent = v
 	v.typedField._type.parent = v
+	v.options.init(v)
 	return v
 }
 
 func (o OneOf) Fields() (out []*OneOfField) {
 	out = make([]*OneOfField, len(o.fields))
-	i := 0
-	for f := range o.fields {
-		out[i] = f
-	}
+	copy(out, o.fields)
 	return
 }
 
 func (o *OneOf) InsertIntoParent() error {
-	return o.parent.insertField(o)
+	return o.parent.insertField(o, len(o.parent.fields))
+}
+
+func (o *OneOf) InsertIntoParentAt(index int) error {
+	return o.parent.insertField(o, index)
+}
+
+func (o *OneOf) Remove() error {
+	return o.parent.removeField(o)
+}
+
+func (o *OneOf) Position() *Position {
+	return &Position{o, func() interface{} { return o.parent.fields }}
 }
 
 func (o *OneOf) Parent() Message {
@@ -44,34 +59,44 @@ func (o *OneOf) Document() *Document {
 	return o.parent.Document()
 }
 
-func (o OneOf) String() string {
-	return o.Document().Printer.OneOf(&o)
+func (o *OneOf) String() string {
+	return o.Document().Printer.OneOf(o)
 }
 
-func (o *OneOf) insertField(f *OneOfField) error {
-	if o.fields == nil {
-		o.fields = make(map[*OneOfField]struct{})
-	}
-	if _, ok := o.fields[f]; ok {
+func (o *OneOf) insertField(f *OneOfField, index int) error {
+	if indexOf(o.fields, f) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(o.fields)); err != nil {
+		return err
+	}
 	if err := f.validate(); err != nil {
 		return err
 	}
-	o.fields[f] = struct{}{}
+	safety := o.Document().safety()
+	o.fields = append(o.fields, f)
+	move(o.fields, len(o.fields)-1, index)
+	if err := safety.check(); err != nil {
+		move(o.fields, index, len(o.fields)-1)
+		o.fields = o.fields[:len(o.fields)-1]
+		return err
+	}
+	recordInsert(o, f, index, func() error {
+		return o.insertField(f, index)
+	})
 	return nil
 }
 
 func (o *OneOf) validateLabel(l *Label) error {
-	if o.hasLabel(l) {
-		return fmt.Errorf("field label %s already in use", l)
+	if existing := o.findLabel(l); existing != nil {
+		return &DuplicateLabelError{existing, l, l.value}
 	}
 	return o.parent.validateLabel(l)
 }
 
 func (o OneOf) validateNumber(n FieldNumber) error {
-	if o.hasNumber(n) {
-		return fmt.Errorf("field number %s already in use", n)
+	if existing := o.findNumber(n); existing != nil {
+		return numberConflict(existing, n)
 	}
 	return o.parent.validateNumber(n)
 }
@@ -80,7 +105,7 @@ func (o *OneOf) validateAsMessageField() error {
 	if err := o.label.validate(); err != nil {
 		return err
 	}
-	for f := range o.fields {
+	for _, f := range o.fields {
 		if err := f.validate(); err != nil {
 			return err
 		}
@@ -88,22 +113,22 @@ func (o *OneOf) validateAsMessageField() error {
 	return nil
 }
 
-func (o OneOf) hasNumber(n FieldNumber) bool {
-	for f := range o.fields {
-		if f.hasNumber(n) {
-			return true
+func (o OneOf) findNumber(n FieldNumber) FieldNumber {
+	for _, f := range o.fields {
+		if existing := f.findNumber(n); existing != nil {
+			return existing
 		}
 	}
-	return false
+	return nil
 }
 
-func (o *OneOf) hasLabel(l *Label) bool {
-	for f := range o.fields {
-		if f.hasLabel(l) {
-			return true
+func (o *OneOf) findLabel(l *Label) *Label {
+	for _, f := range o.fields {
+		if existing := f.findLabel(l); existing != nil {
+			return existing
 		}
 	}
-	return o.label.hasLabel(l)
+	return o.label.findLabel(l)
 }
 
 type OneOfField struct {
@@ -112,7 +137,19 @@ type OneOfField struct {
 }
 
 func (f *OneOfField) InsertIntoParent() error {
-	return f.parent.insertField(f)
+	return f.parent.insertField(f, len(f.parent.fields))
+}
+
+func (f *OneOfField) InsertIntoParentAt(index int) error {
+	return f.parent.insertField(f, index)
+}
+
+func (f *OneOfField) Remove() error {
+	return f.parent.removeField(f)
+}
+
+func (f *OneOfField) Position() *Position {
+	return &Position{f, func() interface{} { return f.parent.fields }}
 }
 
 func (f OneOfField) Parent() *OneOf {
@@ -123,8 +160,8 @@ func (f OneOfField) Document() *Document {
 	return f.parent.Document()
 }
 
-func (f OneOfField) String() string {
-	return f.Document().Printer.OneOfField(&f)
+func (f *OneOfField) String() string {
+	return f.Document().Printer.OneOfField(f)
 }
 
 func (f OneOfField) validateLabel(l *Label) error {
@@ -138,3 +175,11 @@ func (f OneOfField) validateNumber(n FieldNumber) error {
 func (f OneOfField) validateFlag(*Flag) error {
 	return nil
 }
+
+func (f *OneOfField) validateType(t *Type) error {
+	return f.options.validate(t.value, false)
+}
+
+func (f *OneOfField) validateOption(Option) error {
+	return f.options.validate(f._type.value, false)
+}
@@ -0,0 +1,433 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Option is one of the built-in options from `google/protobuf/descriptor.proto`.
+// options are optional values, so unlike flags they can be unset again.
+//
+// `deprecated` and `allow_alias` are modelled as flags on the items themselves,
+// since their default of `false` is the same as not setting them.
+type Option interface {
+	Name() string
+	IsSet() bool
+	Unset() error
+}
+
+type Optioned interface {
+	validateOption(Option) error
+}
+
+type option struct {
+	name   string
+	parent Optioned
+}
+
+func (o option) Name() string {
+	return o.name
+}
+
+type BoolOption struct {
+	value *bool
+	option
+}
+
+func (o BoolOption) Get() *bool {
+	if o.value == nil {
+		return nil
+	}
+	out := *o.value
+	return &out
+}
+
+func (o *BoolOption) Set(value bool) error {
+	old := o.value
+	o.value = &value
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	record(&OptionChanged{o.parent, o, boolValue(old), value}, func() error {
+		return o.restore(old)
+	}, func() error {
+		return o.Set(value)
+	})
+	return nil
+}
+
+func (o *BoolOption) IsSet() bool {
+	return o.value != nil
+}
+
+func (o *BoolOption) Unset() error {
+	old := o.value
+	o.value = nil
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	if old != nil {
+		record(&OptionChanged{o.parent, o, boolValue(old), nil}, func() error {
+			return o.restore(old)
+		}, o.Unset)
+	}
+	return nil
+}
+
+func (o *BoolOption) restore(value *bool) error {
+	if value == nil {
+		return o.Unset()
+	}
+	return o.Set(*value)
+}
+
+// boolValue of an option for events
+func boolValue(value *bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+type StringOption struct {
+	value *string
+	option
+}
+
+func (o StringOption) Get() *string {
+	if o.value == nil {
+		return nil
+	}
+	out := *o.value
+	return &out
+}
+
+func (o *StringOption) Set(value string) error {
+	old := o.value
+	o.value = &value
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	record(&OptionChanged{o.parent, o, stringValue(old), value}, func() error {
+		return o.restore(old)
+	}, func() error {
+		return o.Set(value)
+	})
+	return nil
+}
+
+func (o *StringOption) IsSet() bool {
+	return o.value != nil
+}
+
+func (o *StringOption) Unset() error {
+	old := o.value
+	o.value = nil
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	if old != nil {
+		record(&OptionChanged{o.parent, o, stringValue(old), nil}, func() error {
+			return o.restore(old)
+		}, o.Unset)
+	}
+	return nil
+}
+
+func (o *StringOption) restore(value *string) error {
+	if value == nil {
+		return o.Unset()
+	}
+	return o.Set(*value)
+}
+
+// stringValue of an option for events
+func stringValue(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// EnumOption takes one of a fixed set of identifiers, which are the values of
+// the corresponding enum in `descriptor.proto`.
+type EnumOption struct {
+	value  *string
+	values []string
+	option
+}
+
+func (o EnumOption) Get() *string {
+	if o.value == nil {
+		return nil
+	}
+	out := *o.value
+	return &out
+}
+
+func (o *EnumOption) Set(value string) error {
+	valid := false
+	for _, v := range o.values {
+		if v == value {
+			valid = true
+		}
+	}
+	if !valid {
+		return &InvalidOptionError{o, fmt.Sprintf("%q is not one of %s", value, strings.Join(o.values, ", "))}
+	}
+	old := o.value
+	o.value = &value
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	record(&OptionChanged{o.parent, o, stringValue(old), value}, func() error {
+		return o.restore(old)
+	}, func() error {
+		return o.Set(value)
+	})
+	return nil
+}
+
+// Values the option can take
+func (o EnumOption) Values() []string {
+	out := make([]string, len(o.values))
+	copy(out, o.values)
+	return out
+}
+
+func (o *EnumOption) IsSet() bool {
+	return o.value != nil
+}
+
+func (o *EnumOption) Unset() error {
+	old := o.value
+	o.value = nil
+	if err := o.parent.validateOption(o); err != nil {
+		o.value = old
+		return err
+	}
+	if old != nil {
+		record(&OptionChanged{o.parent, o, stringValue(old), nil}, func() error {
+			return o.restore(old)
+		}, o.Unset)
+	}
+	return nil
+}
+
+func (o *EnumOption) restore(value *string) error {
+	if value == nil {
+		return o.Unset()
+	}
+	return o.Set(*value)
+}
+
+// FileOptions of a document
+type FileOptions struct {
+	javaPackage          StringOption
+	javaOuterClassname   StringOption
+	javaMultipleFiles    BoolOption
+	javaStringCheckUtf8  BoolOption
+	optimizeFor          EnumOption
+	goPackage            StringOption
+	ccGenericServices    BoolOption
+	javaGenericServices  BoolOption
+	pyGenericServices    BoolOption
+	ccEnableArenas       BoolOption
+	objcClassPrefix      StringOption
+	csharpNamespace      StringOption
+	swiftPrefix          StringOption
+	phpClassPrefix       StringOption
+	phpNamespace         StringOption
+	phpMetadataNamespace StringOption
+	rubyPackage          StringOption
+}
+
+func (o *FileOptions) init(parent Optioned) {
+	o.javaPackage.option = option{"java_package", parent}
+	o.javaOuterClassname.option = option{"java_outer_classname", parent}
+	o.javaMultipleFiles.option = option{"java_multiple_files", parent}
+	o.javaStringCheckUtf8.option = option{"java_string_check_utf8", parent}
+	o.optimizeFor.option = option{"optimize_for", parent}
+	o.optimizeFor.values = []string{"SPEED", "CODE_SIZE", "LITE_RUNTIME"}
+	o.goPackage.option = option{"go_package", parent}
+	o.ccGenericServices.option = option{"cc_generic_services", parent}
+	o.javaGenericServices.option = option{"java_generic_services", parent}
+	o.pyGenericServices.option = option{"py_generic_services", parent}
+	o.ccEnableArenas.option = option{"cc_enable_arenas", parent}
+	o.objcClassPrefix.option = option{"objc_class_prefix", parent}
+	o.csharpNamespace.option = option{"csharp_namespace", parent}
+	o.swiftPrefix.option = option{"swift_prefix", parent}
+	o.phpClassPrefix.option = option{"php_class_prefix", parent}
+	o.phpNamespace.option = option{"php_namespace", parent}
+	o.phpMetadataNamespace.option = option{"php_metadata_namespace", parent}
+	o.rubyPackage.option = option{"ruby_package", parent}
+}
+
+func (o *FileOptions) JavaPackage() *StringOption          { return &o.javaPackage }
+func (o *FileOptions) JavaOuterClassname() *StringOption   { return &o.javaOuterClassname }
+func (o *FileOptions) JavaMultipleFiles() *BoolOption      { return &o.javaMultipleFiles }
+func (o *FileOptions) JavaStringCheckUtf8() *BoolOption    { return &o.javaStringCheckUtf8 }
+func (o *FileOptions) OptimizeFor() *EnumOption            { return &o.optimizeFor }
+func (o *FileOptions) GoPackage() *StringOption            { return &o.goPackage }
+func (o *FileOptions) CcGenericServices() *BoolOption      { return &o.ccGenericServices }
+func (o *FileOptions) JavaGenericServices() *BoolOption    { return &o.javaGenericServices }
+func (o *FileOptions) PyGenericServices() *BoolOption      { return &o.pyGenericServices }
+func (o *FileOptions) CcEnableArenas() *BoolOption         { return &o.ccEnableArenas }
+func (o *FileOptions) ObjcClassPrefix() *StringOption      { return &o.objcClassPrefix }
+func (o *FileOptions) CsharpNamespace() *StringOption      { return &o.csharpNamespace }
+func (o *FileOptions) SwiftPrefix() *StringOption          { return &o.swiftPrefix }
+func (o *FileOptions) PhpClassPrefix() *StringOption       { return &o.phpClassPrefix }
+func (o *FileOptions) PhpNamespace() *StringOption         { return &o.phpNamespace }
+func (o *FileOptions) PhpMetadataNamespace() *StringOption { return &o.phpMetadataNamespace }
+func (o *FileOptions) RubyPackage() *StringOption          { return &o.rubyPackage }
+
+// List all options in the order of `descriptor.proto`, whether set or not
+func (o *FileOptions) List() []Option {
+	return []Option{
+		&o.javaPackage,
+		&o.javaOuterClassname,
+		&o.javaMultipleFiles,
+		&o.javaStringCheckUtf8,
+		&o.optimizeFor,
+		&o.goPackage,
+		&o.ccGenericServices,
+		&o.javaGenericServices,
+		&o.pyGenericServices,
+		&o.ccEnableArenas,
+		&o.objcClassPrefix,
+		&o.csharpNamespace,
+		&o.swiftPrefix,
+		&o.phpClassPrefix,
+		&o.phpNamespace,
+		&o.phpMetadataNamespace,
+		&o.rubyPackage,
+	}
+}
+
+// MessageOptions of a message. `map_entry` and `message_set_wire_format` are
+// not available, since `protoc` only allows them for generated map entries
+// and proto2 extensions, respectively.
+type MessageOptions struct {
+	noStandardDescriptorAccessor BoolOption
+}
+
+func (o *MessageOptions) init(parent Optioned) {
+	o.noStandardDescriptorAccessor.option = option{"no_standard_descriptor_accessor", parent}
+}
+
+func (o *MessageOptions) NoStandardDescriptorAccessor() *BoolOption {
+	return &o.noStandardDescriptorAccessor
+}
+
+// List all options in the order of `descriptor.proto`, whether set or not
+func (o *MessageOptions) List() []Option {
+	return []Option{&o.noStandardDescriptorAccessor}
+}
+
+// FieldOptions of message fields, map fields and oneof fields. `json_name` is
+// not part of `FieldOptions` in `descriptor.proto`, but declared the same way.
+type FieldOptions struct {
+	ctype    EnumOption
+	packed   BoolOption
+	jstype   EnumOption
+	lazy     BoolOption
+	jsonName StringOption
+}
+
+func (o *FieldOptions) init(parent Optioned) {
+	o.ctype.option = option{"ctype", parent}
+	o.ctype.values = []string{"STRING", "CORD", "STRING_PIECE"}
+	o.packed.option = option{"packed", parent}
+	o.jstype.option = option{"jstype", parent}
+	o.jstype.values = []string{"JS_NORMAL", "JS_STRING", "JS_NUMBER"}
+	o.lazy.option = option{"lazy", parent}
+	o.jsonName.option = option{"json_name", parent}
+}
+
+func (o *FieldOptions) CType() *EnumOption      { return &o.ctype }
+func (o *FieldOptions) Packed() *BoolOption     { return &o.packed }
+func (o *FieldOptions) JSType() *EnumOption     { return &o.jstype }
+func (o *FieldOptions) Lazy() *BoolOption       { return &o.lazy }
+func (o *FieldOptions) JSONName() *StringOption { return &o.jsonName }
+
+// List all options in the order of `descriptor.proto`, whether set or not
+func (o *FieldOptions) List() []Option {
+	return []Option{&o.ctype, &o.packed, &o.jstype, &o.lazy, &o.jsonName}
+}
+
+// validate options against the type of the field they are attached to
+func (o *FieldOptions) validate(t ValueType, repeated bool) error {
+	if o.ctype.value != nil && t != String && t != Bytes {
+		return &InvalidOptionError{&o.ctype, "only allowed for string and bytes fields"}
+	}
+	if o.packed.value != nil && *o.packed.value && !(repeated && packable(t)) {
+		return &InvalidOptionError{&o.packed, "only allowed for repeated scalar numeric and enum fields"}
+	}
+	if o.jstype.value != nil {
+		switch t {
+		case Int64, Uint64, Sint64, Fixed64, Sfixed64:
+		default:
+			return &InvalidOptionError{&o.jstype, "only allowed for 64 bit integer fields"}
+		}
+	}
+	if o.lazy.value != nil && *o.lazy.value {
+		if _, ok := t.(Message); !ok {
+			return &InvalidOptionError{&o.lazy, "only allowed for message fields"}
+		}
+	}
+	if o.jsonName.value != nil && *o.jsonName.value == "" {
+		return &InvalidOptionError{&o.jsonName, "must not be empty"}
+	}
+	return nil
+}
+
+// validateMap options against the map entry, which is a repeated message
+// regardless of the map's value type
+func (o *FieldOptions) validateMap() error {
+	switch {
+	case o.ctype.value != nil:
+		return &InvalidOptionError{&o.ctype, "not allowed for map fields"}
+	case o.packed.value != nil && *o.packed.value:
+		return &InvalidOptionError{&o.packed, "not allowed for map fields"}
+	case o.jstype.value != nil:
+		return &InvalidOptionError{&o.jstype, "not allowed for map fields"}
+	case o.jsonName.value != nil && *o.jsonName.value == "":
+		return &InvalidOptionError{&o.jsonName, "must not be empty"}
+	}
+	return nil
+}
+
+// packable types can be encoded in packed form when repeated
+func packable(t ValueType) bool {
+	switch t.(type) {
+	case Enum:
+		return true
+	case Message:
+		return false
+	}
+	return t != nil && t != String && t != Bytes
+}
+
+// MethodOptions of an RPC
+type MethodOptions struct {
+	idempotencyLevel EnumOption
+}
+
+func (o *MethodOptions) init(parent Optioned) {
+	o.idempotencyLevel.option = option{"idempotency_level", parent}
+	o.idempotencyLevel.values = []string{"IDEMPOTENCY_UNKNOWN", "NO_SIDE_EFFECTS", "IDEMPOTENT"}
+}
+
+func (o *MethodOptions) IdempotencyLevel() *EnumOption {
+	return &o.idempotencyLevel
+}
+
+// List all options in the order of `descriptor.proto`, whether set or not
+func (o *MethodOptions) List() []Option {
+	return []Option{&o.idempotencyLevel}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsRoundTrip(t *testing.T) {
+	input := `syntax = "proto3";
+
+option java_package = "com.example.foo";
+option java_multiple_files = true;
+option optimize_for = LITE_RUNTIME;
+option go_package = "example.com/foo;foo";
+
+service S {
+  option deprecated = true;
+  rpc Get (M) returns (M) {
+    option deprecated = true;
+    option idempotency_level = IDEMPOTENT;
+  }
+}
+
+enum E {
+  option deprecated = true;
+  A = 0 [deprecated=true];
+}
+
+message M {
+  option deprecated = true;
+  option no_standard_descriptor_accessor = true;
+
+  repeated int32 ids = 1 [packed=false];
+  string name = 2 [deprecated=true, ctype=CORD, json_name="Name \"quoted\""];
+  M next = 3 [lazy=true];
+  map <string,int64> values = 4 [json_name="vals"];
+  oneof choice {
+    uint64 big = 5 [jstype=JS_STRING];
+  }
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, input, d.String())
+
+	m := FindMessage(d, "M")
+	name := m.Fields()[1].(*Field)
+	assert.Equal(t, `Name "quoted"`, *name.Options().JSONName().Get())
+	assert.Equal(t, "CORD", *name.Options().CType().Get())
+	assert.False(t, name.Options().Packed().IsSet())
+}
+
+func TestOptionValidation(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message M {
+  repeated int32 ids = 1 [packed=true];
+  string name = 2;
+}
+`))
+	require.Nil(t, err)
+	m := FindMessage(d, "M")
+	ids := m.Fields()[0].(*Field)
+	name := m.Fields()[1].(*Field)
+
+	err = name.Options().Packed().Set(true)
+	var invalid *InvalidOptionError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, name.Options().Packed(), invalid.Option)
+	assert.False(t, name.Options().Packed().IsSet(), "rolled back")
+	assert.Nil(t, name.Options().Packed().Set(false))
+
+	assert.NotNil(t, name.Options().JSType().Set("JS_STRING"))
+	assert.NotNil(t, name.Options().Lazy().Set(true))
+	assert.NotNil(t, name.Options().CType().Set("ROPE"))
+	assert.Nil(t, name.Options().CType().Set("CORD"))
+	assert.Equal(t, []string{"STRING", "CORD", "STRING_PIECE"}, name.Options().CType().Values())
+
+	// options constrain later changes to the field
+	assert.NotNil(t, ids.Repeated().Set(false))
+	assert.NotNil(t, ids.Type().Set(String))
+	assert.Nil(t, ids.Type().Set(Sint32))
+	assert.Nil(t, ids.Options().Packed().Unset())
+	assert.Nil(t, ids.Repeated().Set(false))
+	assert.Nil(t, name.Options().CType().Unset())
+	assert.Nil(t, name.Type().Set(Int64))
+	assert.Nil(t, name.Options().JSType().Set("JS_NUMBER"))
+
+	assert.Nil(t, d.Options().GoPackage().Set("example.com/m"))
+	assert.Nil(t, m.Deprecated().Set(true))
+	assert.Contains(t, d.String(), `option go_package = "example.com/m";`)
+	assert.Contains(t, d.String(), "message M {\n  option deprecated = true;\n\n")
+
+	_, err = Parse(strings.NewReader(`syntax = "proto3";
+option deprecated = true;
+option deprecated = false;
+`))
+	var parseError *ParseError
+	require.True(t, errors.As(err, &parseError))
+	assert.Equal(t, 3, parseError.Line)
+}
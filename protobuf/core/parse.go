@@ -0,0 +1,822 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse a document in proto3 syntax.
+//
+// the document is constructed through the same validating setters and
+// insertion methods available to API consumers, so a parsed document is
+// subject to the exact same rules as one built by hand. errors are of type
+// `*ParseError` and carry the position of the offending item.
+//
+// standalone documents cannot resolve imports, so they can only reference
+// their own definitions. use `Workspace.Parse` for documents importing others.
+func Parse(r io.Reader) (*Document, error) {
+	d := NewDocument()
+	if err := parse(d, r); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func parse(d *Document, r io.Reader) error {
+	f, err := parseFile(r)
+	if err != nil {
+		return err
+	}
+	return f.build(d)
+}
+
+func parseFile(r io.Reader) (*syntaxFile, error) {
+	s, err := newScanner(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{scanner: s}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return p.file()
+}
+
+// syntax tree of a proto3 file, which has to be complete before we can
+// resolve type references.
+
+type syntaxFile struct {
+	comments        Comments
+	_package        *token
+	packageComments Comments
+	imports         []*syntaxImport
+	options         []*syntaxOption
+	definitions     []interface{}
+}
+
+type syntaxImport struct {
+	path     token
+	public   bool
+	comments Comments
+}
+
+type syntaxOption struct {
+	label token
+	value token
+}
+
+type syntaxMessage struct {
+	comments Comments
+	label    token
+	options  []*syntaxOption
+	children []interface{}
+	message  Message
+}
+
+type syntaxField struct {
+	comments Comments
+	repeated bool
+	_type    token
+	label    token
+	number   token
+	options  []*syntaxOption
+}
+
+type syntaxMap struct {
+	comments Comments
+	keyType  token
+	_type    token
+	label    token
+	number   token
+	options  []*syntaxOption
+}
+
+type syntaxOneOf struct {
+	comments Comments
+	label    token
+	options  []*syntaxOption
+	fields   []*syntaxField
+}
+
+type syntaxEnum struct {
+	comments Comments
+	label    token
+	options  []*syntaxOption
+	children []interface{}
+	enum     Enum
+}
+
+type syntaxVariant struct {
+	comments Comments
+	label    token
+	number   token
+	options  []*syntaxOption
+}
+
+type syntaxReserved struct {
+	ranges []syntaxRange
+	labels []token
+}
+
+type syntaxRange struct {
+	start token
+	// end is nil for single numbers
+	end *token
+}
+
+type syntaxService struct {
+	comments Comments
+	label    token
+	options  []*syntaxOption
+	rpcs     []*syntaxRPC
+	service  *Service
+}
+
+type syntaxRPC struct {
+	comments       Comments
+	label          token
+	request        token
+	requestStream  bool
+	response       token
+	responseStream bool
+	options        []*syntaxOption
+}
+
+// parser is a recursive descent parser with one token of lookahead
+type parser struct {
+	scanner *scanner
+	current token
+}
+
+func (p *parser) next() (err error) {
+	p.current, err = p.scanner.next()
+	return
+}
+
+func (p *parser) is(text string) bool {
+	switch p.current.kind {
+	case tokenIdentifier, tokenSymbol:
+		return p.current.text == text
+	default:
+		return false
+	}
+}
+
+// accept the current token if it matches and advance
+func (p *parser) accept(text string) (bool, error) {
+	if !p.is(text) {
+		return false, nil
+	}
+	return true, p.next()
+}
+
+func (p *parser) expect(text string) (token, error) {
+	t := p.current
+	if !p.is(text) {
+		return t, t.errorf("expected %q, found %s", text, t)
+	}
+	return t, p.next()
+}
+
+func (p *parser) expectKind(kind tokenKind) (token, error) {
+	t := p.current
+	if t.kind != kind {
+		return t, t.errorf("expected %s, found %s", kind, t)
+	}
+	return t, p.next()
+}
+
+// fullIdentifier reads dot-separated identifiers into a single token. a
+// leading dot is only accepted for type references.
+func (p *parser) fullIdentifier(leadingDot bool) (token, error) {
+	t := p.current
+	parts := []string{}
+	if leadingDot && p.is(".") {
+		parts = append(parts, "")
+		if err := p.next(); err != nil {
+			return t, err
+		}
+	}
+	for {
+		part, err := p.expectKind(tokenIdentifier)
+		if err != nil {
+			return t, err
+		}
+		parts = append(parts, part.text)
+		if ok, err := p.accept("."); err != nil || !ok {
+			t.kind = tokenIdentifier
+			t.text = strings.Join(parts, ".")
+			return t, err
+		}
+	}
+}
+
+func (p *parser) integer() (token, error) {
+	t := p.current
+	negative, err := p.accept("-")
+	if err != nil {
+		return t, err
+	}
+	n, err := p.expectKind(tokenInteger)
+	if err != nil {
+		return t, err
+	}
+	if negative {
+		t.text = "-" + n.text
+	} else {
+		t.text = n.text
+	}
+	t.kind = tokenInteger
+	return t, nil
+}
+
+func (p *parser) end() error {
+	_, err := p.expect(";")
+	return err
+}
+
+// comments preceding the current token, which starts a declaration
+func (p *parser) comments() Comments {
+	return Comments{leading: p.current.leading, detached: p.current.detached}
+}
+
+// trailing comment of the token just consumed, which is either the end of a
+// declaration or the opening brace of its block
+func (p *parser) trailing(c *Comments) {
+	c.trailing = p.current.trailing
+}
+
+func (p *parser) file() (*syntaxFile, error) {
+	f := &syntaxFile{}
+	if !p.is("syntax") {
+		return nil, p.current.errorf("missing syntax declaration, only proto3 is supported")
+	}
+	if err := p.syntax(f); err != nil {
+		return nil, err
+	}
+	for p.current.kind != tokenEOF {
+		var err error
+		switch {
+		case p.is(";"):
+			err = p.next()
+		case p.is("package"):
+			err = p._package(f)
+		case p.is("import"):
+			err = p._import(f)
+		case p.is("option"):
+			var o *syntaxOption
+			o, err = p.option()
+			f.options = append(f.options, o)
+		case p.is("message"):
+			var m *syntaxMessage
+			m, err = p.message()
+			f.definitions = append(f.definitions, m)
+		case p.is("enum"):
+			var e *syntaxEnum
+			e, err = p.enum()
+			f.definitions = append(f.definitions, e)
+		case p.is("service"):
+			var s *syntaxService
+			s, err = p.service()
+			f.definitions = append(f.definitions, s)
+		case p.is("extend"):
+			err = p.current.errorf("extensions are not supported")
+		default:
+			err = p.current.errorf("unexpected %s", p.current)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) syntax(f *syntaxFile) error {
+	f.comments = p.comments()
+	if _, err := p.expect("syntax"); err != nil {
+		return err
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	s, err := p.expectKind(tokenString)
+	if err != nil {
+		return err
+	}
+	if s.text != "proto3" {
+		return s.errorf("unsupported syntax %s, only proto3 is supported", s)
+	}
+	if err := p.end(); err != nil {
+		return err
+	}
+	p.trailing(&f.comments)
+	return nil
+}
+
+func (p *parser) _package(f *syntaxFile) error {
+	comments := p.comments()
+	start, err := p.expect("package")
+	if err != nil {
+		return err
+	}
+	if f._package != nil {
+		return start.errorf("multiple package declarations")
+	}
+	name, err := p.fullIdentifier(false)
+	if err != nil {
+		return err
+	}
+	f._package = &name
+	f.packageComments = comments
+	if err := p.end(); err != nil {
+		return err
+	}
+	p.trailing(&f.packageComments)
+	return nil
+}
+
+func (p *parser) _import(f *syntaxFile) error {
+	i := &syntaxImport{comments: p.comments()}
+	if _, err := p.expect("import"); err != nil {
+		return err
+	}
+	if p.is("weak") {
+		return p.current.errorf("weak imports are not supported")
+	}
+	public, err := p.accept("public")
+	if err != nil {
+		return err
+	}
+	i.public = public
+	if i.path, err = p.expectKind(tokenString); err != nil {
+		return err
+	}
+	f.imports = append(f.imports, i)
+	if err := p.end(); err != nil {
+		return err
+	}
+	p.trailing(&i.comments)
+	return nil
+}
+
+func (p *parser) option() (*syntaxOption, error) {
+	if _, err := p.expect("option"); err != nil {
+		return nil, err
+	}
+	o, err := p.optionAssignment()
+	if err != nil {
+		return nil, err
+	}
+	return o, p.end()
+}
+
+func (p *parser) optionAssignment() (*syntaxOption, error) {
+	o := &syntaxOption{}
+	var err error
+	if p.is("(") {
+		start := p.current
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.fullIdentifier(true)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		o.label = start
+		o.label.text = fmt.Sprintf("(%s)", name.text)
+		if p.is(".") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			rest, err := p.fullIdentifier(false)
+			if err != nil {
+				return nil, err
+			}
+			o.label.text = fmt.Sprint(o.label.text, ".", rest.text)
+		}
+	} else if o.label, err = p.fullIdentifier(false); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if o.value, err = p.constant(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (p *parser) constant() (token, error) {
+	t := p.current
+	switch {
+	case p.is("{"):
+		return t, t.errorf("aggregate option values are not supported")
+	case p.is("-") || p.is("+"):
+		sign := t.text
+		if err := p.next(); err != nil {
+			return t, err
+		}
+		switch p.current.kind {
+		case tokenInteger, tokenFloat:
+		case tokenIdentifier:
+			if p.current.text != "inf" && p.current.text != "nan" {
+				return t, p.current.errorf("expected number, found %s", p.current)
+			}
+		default:
+			return t, p.current.errorf("expected number, found %s", p.current)
+		}
+		t.kind = p.current.kind
+		t.text = fmt.Sprint(sign, p.current.text)
+		return t, p.next()
+	case t.kind == tokenIdentifier:
+		return p.fullIdentifier(false)
+	case t.kind == tokenInteger, t.kind == tokenFloat, t.kind == tokenString:
+		if err := p.next(); err != nil {
+			return t, err
+		}
+		// adjacent string literals are concatenated
+		for t.kind == tokenString && p.current.kind == tokenString {
+			t.text += p.current.text
+			if err := p.next(); err != nil {
+				return t, err
+			}
+		}
+		return t, nil
+	default:
+		return t, t.errorf("expected constant, found %s", t)
+	}
+}
+
+// fieldOptions in square brackets after a field declaration
+func (p *parser) fieldOptions() ([]*syntaxOption, error) {
+	var options []*syntaxOption
+	ok, err := p.accept("[")
+	if err != nil || !ok {
+		return nil, err
+	}
+	for {
+		o, err := p.optionAssignment()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if _, err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (p *parser) message() (*syntaxMessage, error) {
+	m := &syntaxMessage{comments: p.comments()}
+	if _, err := p.expect("message"); err != nil {
+		return nil, err
+	}
+	var err error
+	if m.label, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.trailing(&m.comments)
+	for !p.is("}") {
+		var child interface{}
+		switch {
+		case p.current.kind == tokenEOF:
+			return nil, p.current.errorf("unexpected %s", p.current)
+		case p.is(";"):
+			err = p.next()
+		case p.is("option"):
+			var o *syntaxOption
+			o, err = p.option()
+			m.options = append(m.options, o)
+		case p.is("message"):
+			child, err = p.message()
+		case p.is("enum"):
+			child, err = p.enum()
+		case p.is("oneof"):
+			child, err = p.oneOf()
+		case p.is("map"):
+			child, err = p._map()
+		case p.is("reserved"):
+			child, err = p.reserved()
+		case p.is("extensions"), p.is("extend"):
+			err = p.current.errorf("extensions are not supported")
+		case p.is("optional"), p.is("required"), p.is("group"):
+			err = p.current.errorf("%s is not supported in proto3", p.current)
+		default:
+			child, err = p.field()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			m.children = append(m.children, child)
+		}
+	}
+	return m, p.next()
+}
+
+func (p *parser) field() (*syntaxField, error) {
+	f := &syntaxField{comments: p.comments()}
+	var err error
+	if f.repeated, err = p.accept("repeated"); err != nil {
+		return nil, err
+	}
+	if f._type, err = p.fullIdentifier(true); err != nil {
+		return nil, err
+	}
+	if err := p.fieldRest(&f.label, &f.number, &f.options, &f.comments); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// fieldRest is the part of a field declaration after the type
+func (p *parser) fieldRest(label, number *token, options *[]*syntaxOption, comments *Comments) (err error) {
+	if *label, err = p.expectKind(tokenIdentifier); err != nil {
+		return
+	}
+	if _, err = p.expect("="); err != nil {
+		return
+	}
+	if *number, err = p.integer(); err != nil {
+		return
+	}
+	if *options, err = p.fieldOptions(); err != nil {
+		return
+	}
+	if err = p.end(); err != nil {
+		return
+	}
+	p.trailing(comments)
+	return
+}
+
+func (p *parser) _map() (*syntaxMap, error) {
+	m := &syntaxMap{comments: p.comments()}
+	if _, err := p.expect("map"); err != nil {
+		return nil, err
+	}
+	var err error
+	if _, err := p.expect("<"); err != nil {
+		return nil, err
+	}
+	if m.keyType, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if m._type, err = p.fullIdentifier(true); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(">"); err != nil {
+		return nil, err
+	}
+	if err := p.fieldRest(&m.label, &m.number, &m.options, &m.comments); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (p *parser) oneOf() (*syntaxOneOf, error) {
+	o := &syntaxOneOf{comments: p.comments()}
+	if _, err := p.expect("oneof"); err != nil {
+		return nil, err
+	}
+	var err error
+	if o.label, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.trailing(&o.comments)
+	for !p.is("}") {
+		switch {
+		case p.current.kind == tokenEOF:
+			return nil, p.current.errorf("unexpected %s", p.current)
+		case p.is(";"):
+			err = p.next()
+		case p.is("option"):
+			var opt *syntaxOption
+			opt, err = p.option()
+			o.options = append(o.options, opt)
+		case p.is("repeated"), p.is("map"):
+			err = p.current.errorf("%s fields are not allowed in oneof", p.current)
+		default:
+			var f *syntaxField
+			f, err = p.field()
+			o.fields = append(o.fields, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, p.next()
+}
+
+func (p *parser) reserved() (*syntaxReserved, error) {
+	if _, err := p.expect("reserved"); err != nil {
+		return nil, err
+	}
+	r := &syntaxReserved{}
+	for {
+		if p.current.kind == tokenString {
+			r.labels = append(r.labels, p.current)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		} else {
+			var rng syntaxRange
+			var err error
+			if rng.start, err = p.integer(); err != nil {
+				return nil, err
+			}
+			ok, err := p.accept("to")
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				var end token
+				if p.is("max") {
+					end = p.current
+					err = p.next()
+				} else {
+					end, err = p.integer()
+				}
+				if err != nil {
+					return nil, err
+				}
+				rng.end = &end
+			}
+			r.ranges = append(r.ranges, rng)
+		}
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	if len(r.ranges) > 0 && len(r.labels) > 0 {
+		return nil, p.current.errorf("cannot mix field numbers and names in one reserved statement")
+	}
+	return r, p.end()
+}
+
+func (p *parser) enum() (*syntaxEnum, error) {
+	e := &syntaxEnum{comments: p.comments()}
+	if _, err := p.expect("enum"); err != nil {
+		return nil, err
+	}
+	var err error
+	if e.label, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.trailing(&e.comments)
+	for !p.is("}") {
+		var child interface{}
+		switch {
+		case p.current.kind == tokenEOF:
+			return nil, p.current.errorf("unexpected %s", p.current)
+		case p.is(";"):
+			err = p.next()
+		case p.is("option"):
+			var o *syntaxOption
+			o, err = p.option()
+			e.options = append(e.options, o)
+		case p.is("reserved"):
+			child, err = p.reserved()
+		default:
+			v := &syntaxVariant{comments: p.comments()}
+			child = v
+			err = p.fieldRest(&v.label, &v.number, &v.options, &v.comments)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			e.children = append(e.children, child)
+		}
+	}
+	return e, p.next()
+}
+
+func (p *parser) service() (*syntaxService, error) {
+	s := &syntaxService{comments: p.comments()}
+	if _, err := p.expect("service"); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.label, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.trailing(&s.comments)
+	for !p.is("}") {
+		switch {
+		case p.current.kind == tokenEOF:
+			return nil, p.current.errorf("unexpected %s", p.current)
+		case p.is(";"):
+			err = p.next()
+		case p.is("option"):
+			var o *syntaxOption
+			o, err = p.option()
+			s.options = append(s.options, o)
+		default:
+			var r *syntaxRPC
+			r, err = p.rpc()
+			s.rpcs = append(s.rpcs, r)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, p.next()
+}
+
+func (p *parser) rpc() (*syntaxRPC, error) {
+	r := &syntaxRPC{comments: p.comments()}
+	if _, err := p.expect("rpc"); err != nil {
+		return nil, err
+	}
+	var err error
+	if r.label, err = p.expectKind(tokenIdentifier); err != nil {
+		return nil, err
+	}
+	if r.request, r.requestStream, err = p.messageType(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	if r.response, r.responseStream, err = p.messageType(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.accept(";"); err != nil || ok {
+		p.trailing(&r.comments)
+		return r, err
+	}
+	if _, err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.trailing(&r.comments)
+	for !p.is("}") {
+		switch {
+		case p.current.kind == tokenEOF:
+			return nil, p.current.errorf("unexpected %s", p.current)
+		case p.is(";"):
+			err = p.next()
+		default:
+			var o *syntaxOption
+			o, err = p.option()
+			r.options = append(r.options, o)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, p.next()
+}
+
+func (p *parser) messageType() (t token, stream bool, err error) {
+	if _, err = p.expect("("); err != nil {
+		return
+	}
+	// `stream` may also be the name of a message type
+	if p.is("stream") {
+		t = p.current
+		if err = p.next(); err != nil {
+			return
+		}
+		if p.is(")") {
+			return t, false, p.next()
+		}
+		stream = true
+	}
+	if t, err = p.fullIdentifier(true); err != nil {
+		return
+	}
+	_, err = p.expect(")")
+	return
+}
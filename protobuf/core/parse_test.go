@@ -0,0 +1,207 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	input := `
+// leading comment
+syntax = "proto3";
+
+package foo.bar;
+
+import public "other/file.proto";
+
+/* forward references are allowed */
+service Search {
+  rpc Find (Request) returns (stream Outer.Inner);
+}
+
+message Request {
+  string query = 1 [deprecated = true];
+  repeated Outer.Inner results = 2;
+  map<int64, .foo.bar.Kind> kinds = 3;
+  oneof choice {
+    Kind kind = 4;
+    bytes raw = 5;
+  }
+  reserved 6, 8 to 10, 20 to max;
+  reserved "old";
+}
+
+message Outer {
+  message Inner {
+    Outer parent = 1;
+  }
+}
+
+enum Kind {
+  option allow_alias = true;
+  UNKNOWN = 0;
+  DEFAULT = 0;
+  OTHER = 0x2;
+}
+`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, "foo.bar", d.Package().Get())
+
+	require.Len(t, d.Imports(), 1)
+	assert.Equal(t, "other/file.proto", d.Imports()[0].Path().Get())
+	assert.True(t, d.Imports()[0].Public().Get())
+
+	request := FindMessage(d, "Request")
+	outer := FindMessage(d, "Outer")
+	require.NotNil(t, request)
+	require.NotNil(t, outer)
+	inner := FindMessage(outer, "Inner")
+	require.NotNil(t, inner)
+	kind := FindEnum(d, "Kind")
+	require.NotNil(t, kind)
+
+	assert.Len(t, request.Fields(), 8)
+	for _, f := range request.Fields() {
+		switch v := f.(type) {
+		case *Field:
+			switch v.Label().Get() {
+			case "query":
+				assert.Equal(t, String, v.Type().Get())
+				assert.True(t, v.Deprecated().Get())
+			case "results":
+				assert.Equal(t, inner, v.Type().Get())
+				assert.True(t, v.Repeated().Get())
+			default:
+				t.Errorf("unexpected field %s", v.Label().Get())
+			}
+		case *Map:
+			assert.Equal(t, Int64, v.KeyType().Get())
+			assert.Equal(t, kind, v.Type().Get())
+		case *OneOf:
+			assert.Len(t, v.Fields(), 2)
+		case *ReservedNumber:
+			assert.EqualValues(t, 6, *v.Get())
+		case *ReservedRange:
+			assert.Contains(t, []int32{8, 20}, *v.Start().Get())
+			assert.Contains(t, []int32{10, 1<<29 - 1}, *v.End().Get())
+		case *ReservedLabel:
+			assert.Equal(t, "old", v.Get())
+		default:
+			t.Errorf("unexpected field %v", v)
+		}
+	}
+	assert.Equal(t, outer, inner.Fields()[0].(*Field).Type().Get())
+	assert.Len(t, kind.Aliases()[0], 2)
+	assert.True(t, kind.AllowAlias().Get())
+
+	require.Len(t, d.Services(), 1)
+	rpc := d.Services()[0].RPCs()[0]
+	assert.Equal(t, "Find", rpc.Label().Get())
+	assert.Equal(t, request, rpc.Request().Get())
+	assert.False(t, rpc.Request().Stream().Get())
+	assert.Equal(t, inner, rpc.Response().Get())
+	assert.True(t, rpc.Response().Stream().Get())
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	input := `syntax = "proto3";
+
+package pkg;
+
+import "a.proto";
+
+message Foo {
+  repeated string bar = 1 [deprecated=true];
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, input, d.String())
+}
+
+func TestNegativeEnumNumbers(t *testing.T) {
+	input := `syntax = "proto3";
+
+enum E {
+  ZERO = 0;
+  SENTINEL = -1;
+  LOWEST = -2147483648;
+  reserved -5 to -3;
+  reserved -10;
+}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, input, d.String())
+
+	e := FindEnum(d, "E")
+	assert.Equal(t, int32(-1), *e.Fields()[1].(*Variant).Number().Get())
+	v := e.NewVariant()
+	assert.NotNil(t, v.Number().Set(-4), "reserved")
+	assert.Nil(t, v.Number().Set(-6))
+
+	m := d.NewMessage()
+	require.Nil(t, m.Label().Set("M"))
+	require.Nil(t, m.InsertIntoParent())
+	f := FindMessage(d, "M").NewField()
+	var outOfRange *NumberOutOfRangeError
+	assert.True(t, errors.As(f.Number().Set(-1), &outOfRange))
+	r := FindMessage(d, "M").NewReservedRange()
+	assert.True(t, errors.As(r.Start().Set(-3), &outOfRange))
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"message Foo {}", 1, 1},
+		{`syntax = "proto2";`, 1, 10},
+		{"syntax = \"proto3\";\nmessage Foo {\n  Bar bar = 1;\n}", 3, 3},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 1;\n  int32 bar = 2;\n}", 4, 9},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 0;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = -1;\n}", 3, 15},
+		{"syntax = \"proto3\";\nenum Foo {\n  BAR = 2147483648;\n}", 3, 9},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 1\n}", 4, 1},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 1_000;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 0b11;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 0o7;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 0x_1;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {\n  int32 bar = 08;\n}", 3, 15},
+		{"syntax = \"proto3\";\nmessage Foo {}\nenum Foo {}", 3, 6},
+		{"syntax = \"proto3\";\nmessage Foo {\n  map<float, string> bar = 1;\n}", 3, 7},
+		{"syntax = \"proto3\";\nmessage Foo {\n  optional string bar = 1;\n}", 3, 3},
+		{"syntax = \"proto3\";\noption java_packages = \"foo\";", 2, 8},
+		{"syntax = \"proto3\";\nmessage Foo {\n  string bar = 1 [packed = true];\n}", 3, 19},
+		{"syntax = \"proto3\";\nimport \"../foo.proto\";", 2, 8},
+		{"syntax = \"proto3\";\nmessage Foo {}\nservice Bar {\n  rpc Baz (Foo) returns (Qux);\n}", 4, 26},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.input))
+		var parseError *ParseError
+		if assert.True(t, errors.As(err, &parseError), "%q: %v", c.input, err) {
+			assert.Equal(t, c.line, parseError.Line, c.input)
+			assert.Equal(t, c.column, parseError.Column, c.input)
+		}
+	}
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message Foo {
+  int32 a = 10;
+  int32 b = 010;
+  int32 c = 0x10;
+  int32 d = 0X1f;
+}`))
+	require.Nil(t, err)
+	var numbers []int32
+	for _, f := range FindMessage(d, "Foo").Fields() {
+		numbers = append(numbers, *f.(*Field).Number().Get())
+	}
+	assert.Equal(t, []int32{10, 8, 16, 31}, numbers)
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Position of an item among its siblings of the same kind, such as the fields
+// of a message or the messages in a document. the order of items does not
+// change the meaning of a document, except that the first variant of an enum
+// must have number 0, so only moving variants can fail validation.
+// items which are not inserted into their parent yet have no position.
+type Position struct {
+	item interface{}
+	// siblings returns the slice holding the item and its siblings
+	siblings func() interface{}
+}
+
+// Get the index of the item among its siblings, or -1 if it is not inserted.
+func (p *Position) Get() int {
+	return indexOf(p.siblings(), p.item)
+}
+
+// Set the index of the item among its siblings, shifting the items in
+// between.
+func (p *Position) Set(index int) error {
+	from := p.Get()
+	if from < 0 {
+		return errors.New("not inserted")
+	}
+	siblings := p.siblings()
+	if err := checkIndex(index, reflect.ValueOf(siblings).Len()-1); err != nil {
+		return err
+	}
+	move(siblings, from, index)
+	if err := validateOrder(p.item); err != nil {
+		move(siblings, index, from)
+		return err
+	}
+	record(&Moved{p.item, from, index}, func() error {
+		return p.Set(from)
+	}, func() error {
+		return p.Set(index)
+	})
+	return nil
+}
+
+// validateOrder of an item and its siblings after moving it
+func validateOrder(item interface{}) error {
+	switch v := item.(type) {
+	case *Variant:
+		return v.parent.validateFirst()
+	default:
+		return nil
+	}
+}
+
+// MoveUp swaps the item with its predecessor.
+func (p *Position) MoveUp() error {
+	return p.Set(p.Get() - 1)
+}
+
+// MoveDown swaps the item with its successor.
+func (p *Position) MoveDown() error {
+	if p.Get() < 0 {
+		return errors.New("not inserted")
+	}
+	return p.Set(p.Get() + 1)
+}
+
+// MoveBefore places the item immediately before a sibling.
+func (p *Position) MoveBefore(sibling *Position) error {
+	from, to, err := p.sibling(sibling)
+	if err != nil {
+		return err
+	}
+	if from < to {
+		to--
+	}
+	return p.Set(to)
+}
+
+// MoveAfter places the item immediately after a sibling.
+func (p *Position) MoveAfter(sibling *Position) error {
+	from, to, err := p.sibling(sibling)
+	if err != nil {
+		return err
+	}
+	if from > to {
+		to++
+	}
+	return p.Set(to)
+}
+
+func (p *Position) sibling(other *Position) (from, to int, err error) {
+	from = p.Get()
+	if from < 0 {
+		return 0, 0, errors.New("not inserted")
+	}
+	to = indexOf(p.siblings(), other.item)
+	if to < 0 {
+		return 0, 0, errors.New("not a sibling")
+	}
+	return from, to, nil
+}
+
+// indexOf an item in a slice, or -1 if it is not contained
+func indexOf(slice interface{}, item interface{}) int {
+	s := reflect.ValueOf(slice)
+	for i := 0; i < s.Len(); i++ {
+		if s.Index(i).Interface() == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkIndex is within 0 and max, inclusive
+func checkIndex(index, max int) error {
+	if index < 0 || index > max {
+		return fmt.Errorf("index %d out of range [0, %d]", index, max)
+	}
+	return nil
+}
+
+// move an element of a slice from one index to another, shifting the elements
+// in between
+func move(slice interface{}, from, to int) {
+	swap := reflect.Swapper(slice)
+	for ; from < to; from++ {
+		swap(from, from+1)
+	}
+	for ; from > to; from-- {
+		swap(from, from-1)
+	}
+}
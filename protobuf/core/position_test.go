@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func labels(m Message) (out []string) {
+	for _, f := range m.Fields() {
+		out = append(out, f.(*Field).Label().Get())
+	}
+	return
+}
+
+func TestPosition(t *testing.T) {
+	d := NewDocument()
+	nm := d.NewMessage()
+	require.Nil(t, nm.Label().Set("M"))
+	require.Nil(t, nm.InsertIntoParent())
+	m := d.Messages()[0]
+	fields := make([]*Field, 0, 4)
+	for i, l := range []string{"a", "b", "c", "d"} {
+		f := m.NewField()
+		require.Nil(t, f.Label().Set(l))
+		require.Nil(t, f.Number().Set(int32(i+1)))
+		require.Nil(t, f.Type().Set(String))
+		assert.Equal(t, -1, f.Position().Get())
+		require.Nil(t, f.InsertIntoParent())
+		fields = append(fields, f)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, labels(m))
+
+	a, b, c, dd := fields[0], fields[1], fields[2], fields[3]
+	assert.Nil(t, a.Position().MoveDown())
+	assert.Equal(t, []string{"b", "a", "c", "d"}, labels(m))
+	assert.Nil(t, a.Position().MoveUp())
+	assert.NotNil(t, a.Position().MoveUp())
+	assert.NotNil(t, dd.Position().MoveDown())
+	assert.Nil(t, a.Position().MoveAfter(c.Position()))
+	assert.Equal(t, []string{"b", "c", "a", "d"}, labels(m))
+	assert.Nil(t, dd.Position().MoveBefore(b.Position()))
+	assert.Equal(t, []string{"d", "b", "c", "a"}, labels(m))
+	assert.Nil(t, a.Position().Set(1))
+	assert.Equal(t, []string{"d", "a", "b", "c"}, labels(m))
+	assert.NotNil(t, a.Position().Set(4))
+
+	e := m.NewField()
+	require.Nil(t, e.Label().Set("e"))
+	require.Nil(t, e.Number().Set(5))
+	require.Nil(t, e.Type().Set(String))
+	assert.NotNil(t, e.Position().MoveBefore(a.Position()), "not inserted")
+	assert.NotNil(t, e.InsertIntoParentAt(6))
+	require.Nil(t, e.InsertIntoParentAt(0))
+	assert.Equal(t, []string{"e", "d", "a", "b", "c"}, labels(m))
+	assert.Equal(t, "message M {\n  string e = 5;\n  string d = 4;\n  string a = 1;\n  string b = 2;\n  string c = 3;\n}", m.String())
+
+	other := d.NewMessage()
+	require.Nil(t, other.Label().Set("N"))
+	require.Nil(t, other.InsertIntoParentAt(0))
+	assert.Equal(t, "N", d.Messages()[0].Label().Get())
+	assert.NotNil(t, a.Position().MoveBefore(d.Messages()[0].Position()), "not a sibling")
+}
@@ -32,6 +32,10 @@ type Printer interface {
 type Print struct {
 	Indent string
 	Blank  string
+
+	// symbols type references are resolved with, if they were built once for
+	// all items printed
+	symbols symbols
 }
 
 var DefaultPrinter = Print{
@@ -39,41 +43,42 @@ var DefaultPrinter = Print{
 	Blank:  "█",
 }
 
-func (p Print) Document(d *Document) string {
-	// TODO: test against `protoc`
-
-	numItems := 1 + len(d.imports) + len(d.services) + len(d.messages) + len(d.enums)
-	items := make([]string, 0, numItems)
-	if d._package.label.value != "" {
-		items = append(items, d._package.String())
+// Resolving type references with the symbols of a document as it is now.
+// printing a whole document, service or message does that anyway, but
+// back-ends wrapping `Print` to print items one by one can use it to resolve
+// symbols only once.
+func (p Print) Resolving(d *Document) Print {
+	if p.symbols == nil {
+		p.symbols = d.symbols()
 	}
+	return p
+}
 
-	if len(d.imports) > 0 {
-		imports := make([]string, 0, len(d.imports))
-		for i := range d.imports {
-			imports = append(imports, i.String())
-		}
-		items = append(items, strings.Join(imports, "\n"))
-	}
+func (p Print) Document(d *Document) string {
+	// TODO: test against `protoc`
 
-	for s := range d.services {
-		items = append(items, s.String())
-	}
-	for e := range d.enums {
-		items = append(items, e.String())
+	p = p.Resolving(d)
+	c := printed(p.printer(d), func(w *walker) { w.document(d) })
+	items := make([]string, 0, 3+len(c.services)+len(c.enums)+len(c.messages))
+	items = append(items, c.packages...)
+	if len(c.imports) > 0 {
+		items = append(items, strings.Join(c.imports, "\n"))
 	}
-	for m := range d.messages {
-		items = append(items, m.String())
+	if options := p.statements(p.assignments(d.deprecated, d.options.List())); len(options) > 0 {
+		items = append(items, strings.Join(options, "\n"))
 	}
+	items = append(items, c.services...)
+	items = append(items, c.enums...)
+	items = append(items, c.messages...)
 
 	for i, item := range items {
 		items[i] = fmt.Sprint("\n\n", item)
 	}
-	return fmt.Sprint("syntax = \"proto3\";", strings.Join(items, ""))
+	return fmt.Sprint(p.commented(&d.comments, "syntax = \"proto3\";"), strings.Join(items, ""))
 }
 
 func (p Print) Package(pkg *Package) string {
-	return fmt.Sprintf("package %s;", pkg.label)
+	return p.commented(&pkg.comments, fmt.Sprintf("package %s;", pkg.label))
 }
 
 func (p Print) Import(i *Import) string {
@@ -81,65 +86,57 @@ func (p Print) Import(i *Import) string {
 	if i.public.value {
 		public = "public "
 	}
-	return fmt.Sprintf("import %s%s;", public, i.path)
+	return p.commented(&i.comments, fmt.Sprintf("import %s\"%s\";", public, i.path))
 }
 
 func (p Print) Service(s *Service) string {
-	rpcs := make([]string, 0, len(s.rpcs))
-	for r := range s.rpcs {
-		rpcs = append(rpcs, r.String())
-	}
+	p = p.Resolving(s.Document())
+	items := p.statements(p.assignments(s.deprecated, nil))
+	items = append(items, printed(p.printer(s.Document()), func(w *walker) { w.service(s) }).rpcs...)
 	block := "{}"
-	if len(rpcs) > 0 {
-		block = fmt.Sprintf("{\n%s\n}", p.indent(strings.Join(rpcs, "\n")))
+	if len(items) > 0 {
+		block = fmt.Sprintf("{\n%s\n}", p.indent(strings.Join(items, "\n")))
 	}
-	return fmt.Sprintf("service %s %s", s.label, block)
+	return p.commented(&s.comments, fmt.Sprintf("service %s %s", s.label, block))
 }
 
 func (p Print) RPC(r *RPC) string {
-	return fmt.Sprintf("rpc %s (%s) returns (%s);", r.label, r.request.value.Label(), r.response.value.Label())
+	signature := fmt.Sprintf("rpc %s (%s) returns (%s)", r.label, p.messageType(&r.request), p.messageType(&r.response))
+	options := p.statements(p.assignments(r.deprecated, r.options.List()))
+	if len(options) == 0 {
+		return p.commented(&r.comments, fmt.Sprint(signature, ";"))
+	}
+	return p.commented(&r.comments, fmt.Sprintf("%s {\n%s\n}", signature, p.indent(strings.Join(options, "\n"))))
 }
 
-func (p Print) Message(m Message) string {
-	items := make([]string, 0, 3)
-	if len(m.Fields()) > 0 {
-		items = append(items, p.messageFields(m))
+func (p Print) messageType(m *MessageType) string {
+	var stream string
+	if m.stream.value {
+		stream = "stream "
 	}
-	if len(m.Enums()) > 0 {
-		items = append(items, p.messageEnums(m))
+	if m.value == nil {
+		return fmt.Sprint(stream, p.Blank)
 	}
-	if len(m.Messages()) > 0 {
-		items = append(items, p.messageMessages(m))
+	return fmt.Sprint(stream, p.symbolsOf(m.parent.Document()).referenceName(rpcScope(m.parent), m.value))
+}
+
+func (p Print) Message(m Message) string {
+	p = p.Resolving(m.Document())
+	c := printed(p.printer(m.Document()), func(w *walker) { w.message(m) })
+	items := make([]string, 0, 4)
+	for _, group := range [][]string{
+		p.statements(p.assignments(*m.Deprecated(), m.Options().List())),
+		c.fields, c.enums, c.messages,
+	} {
+		if len(group) > 0 {
+			items = append(items, strings.Join(group, "\n"))
+		}
 	}
 	block := "{}"
 	if len(items) > 0 {
 		block = fmt.Sprintf("{\n%s\n}", p.indent(strings.Join(items, "\n\n")))
 	}
-	return fmt.Sprintf("message %s %s", m.Label(), block)
-}
-
-func (p Print) messageFields(m Message) string {
-	fields := make([]string, 0, len(m.Fields()))
-	for _, f := range m.Fields() {
-		fields = append(fields, f.String())
-	}
-	return strings.Join(fields, "\n")
-}
-
-func (p Print) messageEnums(m Message) string {
-	enums := make([]string, 0, len(m.Enums()))
-	for _, e := range m.Enums() {
-		enums = append(enums, e.String())
-	}
-	return strings.Join(enums, "\n")
-}
-
-func (p Print) messageMessages(m Message) string {
-	messages := make([]string, 0, len(m.Messages()))
-	for _, n := range m.Messages() {
-		messages = append(messages, n.String())
-	}
-	return strings.Join(messages, "\n")
+	return p.commented(m.Comments(), fmt.Sprintf("message %s %s", m.Label(), block))
 }
 
 func (p Print) Field(f *Field) string {
@@ -147,31 +144,24 @@ func (p Print) Field(f *Field) string {
 	if f.repeated.value {
 		repeated = "repeated "
 	}
-	return fmt.Sprintf("%s%s %s = %s%s;", repeated, f._type, f.label, f.number, deprecated(f.deprecated))
+	return p.commented(&f.comments, fmt.Sprintf("%s%s %s = %s%s;", repeated, p.printer(f.Document()).Type(&f._type), f.label, f.number, p.brackets(p.assignments(f.deprecated, f.options.List()))))
 }
 
 func (p Print) Map(m *Map) string {
-	return fmt.Sprintf("map <%s,%s> %s = %s%s;", m.keyType, m._type, m.label, m.number, deprecated(m.deprecated))
+	return p.commented(&m.comments, fmt.Sprintf("map <%s,%s> %s = %s%s;", m.keyType, p.printer(m.Document()).Type(&m._type), m.label, m.number, p.brackets(p.assignments(m.deprecated, m.options.List()))))
 }
 
 func (p Print) OneOf(o *OneOf) string {
-	items := make([]string, 0, len(o.fields))
-	for f := range o.fields {
-		items = append(items, f.String())
-	}
+	items := printed(p.printer(o.Document()), func(w *walker) { w.oneOf(o) }).fields
 	block := "{}"
 	if len(items) > 0 {
 		block = fmt.Sprintf("{\n%s\n}", p.indent(strings.Join(items, "\n")))
 	}
-	return fmt.Sprintf("oneof %s %s", o.Label(), block)
+	return p.commented(&o.comments, fmt.Sprintf("oneof %s %s", o.Label(), block))
 }
 
 func (p Print) OneOfField(f *OneOfField) string {
-	var deprecated string
-	if f.deprecated.value {
-		deprecated = " [deprecated=true]"
-	}
-	return fmt.Sprintf("%s %s = %s%s;", f._type, f.label, f.number, deprecated)
+	return p.commented(&f.comments, fmt.Sprintf("%s %s = %s%s;", p.printer(f.Document()).Type(&f._type), f.label, f.number, p.brackets(p.assignments(f.deprecated, f.options.List()))))
 }
 
 func (p Print) Enum(e Enum) string {
@@ -181,14 +171,13 @@ func (p Print) Enum(e Enum) string {
 	if e.AllowAlias().value && aliased(e) {
 		items = append(items, "option allow_alias = true;")
 	}
-	for _, f := range e.Fields() {
-		items = append(items, f.String())
-	}
+	items = append(items, p.statements(p.assignments(*e.Deprecated(), nil))...)
+	items = append(items, printed(p.printer(e.Document()), func(w *walker) { w.enum(e) }).fields...)
 	block := "{}"
 	if len(items) > 0 {
 		block = fmt.Sprintf("{\n%s\n}", p.indent(strings.Join(items, "\n")))
 	}
-	return fmt.Sprintf("enum %s %s", e.Label(), block)
+	return p.commented(e.Comments(), fmt.Sprintf("enum %s %s", e.Label(), block))
 }
 
 func aliased(e Enum) bool {
@@ -201,7 +190,7 @@ func aliased(e Enum) bool {
 }
 
 func (p Print) Variant(v *Variant) string {
-	return fmt.Sprintf("%s = %s%s;", v.label, v.number, deprecated(v.deprecated))
+	return p.commented(&v.comments, fmt.Sprintf("%s = %s%s;", v.label, v.number, p.brackets(p.assignments(v.deprecated, nil))))
 }
 
 func (p Print) ReservedNumber(n *ReservedNumber) string {
@@ -213,7 +202,7 @@ func (p Print) ReservedRange(r *ReservedRange) string {
 }
 
 func (p Print) ReservedLabel(l *ReservedLabel) string {
-	return fmt.Sprintf("reserved %s;", l.label)
+	return fmt.Sprintf("reserved \"%s\";", l.label)
 }
 
 func (p Print) Label(l *Label) string {
@@ -235,10 +224,8 @@ func (p Print) Type(t *Type) string {
 		return p.Blank
 	}
 	switch v := t.value.(type) {
-	case Message:
-		return v.Label().String()
-	case Enum:
-		return v.Label().String()
+	case Message, Enum:
+		return p.symbolsOf(t.parent.Document()).referenceName(typeScope(t), v)
 	default:
 		return fmt.Sprint(v)
 	}
@@ -263,9 +250,239 @@ func (p Print) indent(in string) string {
 	return strings.Join(lines, "\n")
 }
 
-func deprecated(f Flag) string {
-	if f.value {
-		return " [deprecated=true]"
+// commented item, with detached and leading comments on the lines before it,
+// and the trailing comment at the end of its first line. empty blocks are
+// opened up to make room for the trailing comment.
+func (p Print) commented(c *Comments, item string) string {
+	var b strings.Builder
+	for _, d := range c.detached {
+		fmt.Fprint(&b, p.comment(d), "\n\n")
+	}
+	if c.leading != "" {
+		fmt.Fprint(&b, p.comment(c.leading), "\n")
+	}
+	lines := strings.SplitN(item, "\n", 2)
+	if c.trailing != "" {
+		trailing := fmt.Sprint(" ", p.comment(c.trailing))
+		if strings.HasSuffix(lines[0], "{}") {
+			lines[0] = fmt.Sprint(strings.TrimSuffix(lines[0], "}"), trailing, "\n}")
+		} else {
+			lines[0] = fmt.Sprint(lines[0], trailing)
+		}
+	}
+	b.WriteString(strings.Join(lines, "\n"))
+	return b.String()
+}
+
+// comment text as line comments
+func (p Print) comment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = "//"
+		} else {
+			lines[i] = fmt.Sprint("// ", l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type assignment struct {
+	name  string
+	value string
+}
+
+// assignments of all options which are set, starting with `deprecated`
+func (p Print) assignments(deprecated Flag, options []Option) []assignment {
+	out := make([]assignment, 0, 1+len(options))
+	if deprecated.value {
+		out = append(out, assignment{"deprecated", "true"})
+	}
+	for _, o := range options {
+		if !o.IsSet() {
+			continue
+		}
+		var value string
+		switch v := o.(type) {
+		case *BoolOption:
+			value = fmt.Sprint(*v.value)
+		case *StringOption:
+			value = quote(*v.value)
+		case *EnumOption:
+			value = *v.value
+		default:
+			panic(fmt.Sprintf("unhandled option type %T", v))
+		}
+		out = append(out, assignment{o.Name(), value})
+	}
+	return out
+}
+
+// statements declare options in the body of an item
+func (p Print) statements(assignments []assignment) []string {
+	out := make([]string, len(assignments))
+	for i, a := range assignments {
+		out[i] = fmt.Sprintf("option %s = %s;", a.name, a.value)
+	}
+	return out
+}
+
+// brackets declare options after a field
+func (p Print) brackets(assignments []assignment) string {
+	if len(assignments) == 0 {
+		return ""
+	}
+	items := make([]string, len(assignments))
+	for i, a := range assignments {
+		items[i] = fmt.Sprintf("%s=%s", a.name, a.value)
+	}
+	return fmt.Sprintf(" [%s]", strings.Join(items, ", "))
+}
+
+// quote a string literal, escaping only what the scanner requires
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// children of an item as printed. each child is printed on its own through
+// the printer of its document, such that a back-end overriding how one kind of
+// item is printed applies throughout. their subtrees are therefore skipped.
+type children struct {
+	EmptyVisitor
+	printer  Printer
+	packages []string
+	imports  []string
+	services []string
+	rpcs     []string
+	fields   []string
+	enums    []string
+	messages []string
+}
+
+// printed children of the item walked
+func printed(printer Printer, walk func(*walker)) *children {
+	c := &children{printer: printer}
+	walk(&walker{visitor: c})
+	return c
+}
+
+// root of the walk, whose children are printed
+func root(path Path) bool {
+	return len(path) == 0
+}
+
+func (c *children) EnterPackage(p *Package, _ Path) bool {
+	c.packages = append(c.packages, c.printer.Package(p))
+	return false
+}
+
+func (c *children) EnterImport(i *Import, _ Path) bool {
+	c.imports = append(c.imports, c.printer.Import(i))
+	return false
+}
+
+func (c *children) EnterService(s *Service, path Path) bool {
+	if root(path) {
+		return true
+	}
+	c.services = append(c.services, c.printer.Service(s))
+	return false
+}
+
+func (c *children) EnterRPC(r *RPC, _ Path) bool {
+	c.rpcs = append(c.rpcs, c.printer.RPC(r))
+	return false
+}
+
+func (c *children) EnterMessage(m Message, path Path) bool {
+	if root(path) {
+		return true
+	}
+	c.messages = append(c.messages, c.printer.Message(m))
+	return false
+}
+
+func (c *children) EnterField(f *Field, _ Path) bool {
+	c.fields = append(c.fields, c.printer.Field(f))
+	return false
+}
+
+func (c *children) EnterMap(m *Map, _ Path) bool {
+	c.fields = append(c.fields, c.printer.Map(m))
+	return false
+}
+
+func (c *children) EnterOneOf(o *OneOf, path Path) bool {
+	if root(path) {
+		return true
+	}
+	c.fields = append(c.fields, c.printer.OneOf(o))
+	return false
+}
+
+func (c *children) EnterOneOfField(f *OneOfField, _ Path) bool {
+	c.fields = append(c.fields, c.printer.OneOfField(f))
+	return false
+}
+
+func (c *children) EnterEnum(e Enum, path Path) bool {
+	if root(path) {
+		return true
+	}
+	c.enums = append(c.enums, c.printer.Enum(e))
+	return false
+}
+
+func (c *children) EnterVariant(v *Variant, _ Path) bool {
+	c.fields = append(c.fields, c.printer.Variant(v))
+	return false
+}
+
+func (c *children) EnterReservedNumber(r *ReservedNumber, _ Path) bool {
+	c.fields = append(c.fields, c.printer.ReservedNumber(r))
+	return false
+}
+
+func (c *children) EnterReservedRange(r *ReservedRange, _ Path) bool {
+	c.fields = append(c.fields, c.printer.ReservedRange(r))
+	return false
+}
+
+func (c *children) EnterReservedLabel(r *ReservedLabel, _ Path) bool {
+	c.fields = append(c.fields, c.printer.ReservedLabel(r))
+	return false
+}
+
+// printer of the items of a document. children are printed through the
+// printer of their document, such that a back-end overriding how one kind of
+// item is printed applies throughout. if that is a `Print`, it resolves type
+// references with the symbols already built.
+func (p Print) printer(d *Document) Printer {
+	if q, ok := d.Printer.(Print); ok {
+		q.symbols = p.symbols
+		return q
+	}
+	return d.Printer
+}
+
+// symbolsOf a document, unless they were already built
+func (p Print) symbolsOf(d *Document) symbols {
+	if p.symbols != nil {
+		return p.symbols
 	}
-	return ""
+	return d.symbols()
 }
@@ -1,9 +1,11 @@
 package core
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPackage(t *testing.T) {
@@ -53,14 +55,9 @@ func TestEnumAlias(t *testing.T) {
 	v.Label().Set("bar")
 	v.Number().Set(1)
 	v.InsertIntoParent()
-	// output order is non-deterministic, won't implement sorting
-	assert.Contains(t, e.String(), "enum frooble")
-	assert.Contains(t, e.String(), "foo = 0;")
-	assert.Contains(t, e.String(), "bar = 1;")
-	assert.NotContains(t, e.String(), "allow_alias")
+	assert.Equal(t, "enum frooble {\n  foo = 0;\n  bar = 1;\n}", e.String())
 	v.Number().Set(0)
-	assert.Contains(t, e.String(), "bar = 0;")
-	assert.Contains(t, e.String(), "option allow_alias = true;")
+	assert.Equal(t, "enum frooble {\n  option allow_alias = true;\n  foo = 0;\n  bar = 0;\n}", e.String())
 }
 
 func TestMessage(t *testing.T) {
@@ -159,3 +156,97 @@ func TestDocument(t *testing.T) {
 	v2.Number().Set(0)
 	v2.InsertIntoParent()
 }
+
+func TestReferenceNames(t *testing.T) {
+	w := NewWorkspace()
+	_, err := w.Parse("other.proto", strings.NewReader(`syntax = "proto3";
+package other;
+message Msg {}
+`))
+	require.Nil(t, err)
+	d, err := w.Parse("test.proto", strings.NewReader(`syntax = "proto3";
+package foo.bar;
+import "other.proto";
+service Search {
+  rpc Find (Outer.Inner) returns (.other.Msg);
+}
+message Outer {
+  message Inner {}
+  Inner inner = 1;
+  Msg msg = 2;
+}
+message Msg {
+  Outer.Inner inner = 1;
+  other.Msg ref = 2;
+}
+`))
+	require.Nil(t, err)
+	outer := FindMessage(d, "Outer")
+	msg := FindMessage(d, "Msg")
+	s := d.Services()[0]
+	assert.Equal(t, "rpc Find (Outer.Inner) returns (other.Msg);", s.RPCs()[0].String())
+	for _, f := range outer.Fields() {
+		switch f := f.(*Field); f.Label().Get() {
+		case "inner":
+			assert.Equal(t, "Inner", f.Type().String())
+		case "msg":
+			assert.Equal(t, "Msg", f.Type().String())
+		}
+	}
+	for _, f := range msg.Fields() {
+		switch f := f.(*Field); f.Label().Get() {
+		case "inner":
+			assert.Equal(t, "Outer.Inner", f.Type().String())
+		case "ref":
+			assert.Equal(t, "other.Msg", f.Type().String())
+		}
+	}
+
+	// printing leaves the document as it is, so it can be printed concurrently
+	printed := make(chan string)
+	for i := 0; i < 2; i++ {
+		go func() { printed <- d.String() }()
+	}
+	assert.Equal(t, <-printed, <-printed)
+
+	// a nested message shadowing the first component of a name forces a
+	// fully qualified reference
+	n := msg.NewMessage()
+	require.Nil(t, n.Label().Set("other"))
+	require.Nil(t, n.InsertIntoParent())
+	for _, f := range msg.Fields() {
+		if f := f.(*Field); f.Label().Get() == "ref" {
+			assert.Equal(t, ".other.Msg", f.Type().String())
+		}
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	input := `syntax = "proto3";
+
+service S {
+  rpc Unary (M) returns (M);
+  rpc Upload (stream M) returns (M);
+  rpc Download (M) returns (stream M);
+  rpc Chat (stream M) returns (stream M);
+  rpc Named (stream) returns (stream stream);
+}
+
+message M {}
+
+message stream {}`
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, input, d.String())
+
+	modes := []StreamingMode{Unary, ClientStreaming, ServerStreaming, BidiStreaming, ServerStreaming}
+	for i, r := range d.Services()[0].RPCs() {
+		assert.Equal(t, modes[i], r.Streaming(), r.Label().Get())
+	}
+
+	r := d.Services()[0].RPCs()[0]
+	require.Nil(t, r.Request().Stream().Set(true))
+	assert.Equal(t, ClientStreaming, r.Streaming())
+	assert.Equal(t, "rpc Unary (stream M) returns (M);", r.String())
+	assert.Equal(t, "client streaming", r.Streaming().String())
+}
@@ -0,0 +1,466 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// removed items become tentative again: they keep their parent, and items
+// other than messages and enums can be inserted again.
+//
+// references are recorded for tentative items as well, so only references
+// from items actually inserted into a document are considered when removing a
+// message or an enum. references from tentative items fail validation upon
+// insertion instead.
+
+var errNotInserted = errors.New("not inserted")
+
+// ReferencedError is returned when removing a message or enum which is still
+// referenced by field types or RPC message types.
+type ReferencedError struct {
+	Definition ValueType
+	References []MessageReference
+}
+
+func (e *ReferencedError) Error() string {
+	referrers := make([]string, len(e.References))
+	for i, r := range e.References {
+		referrers[i] = describeReference(r)
+	}
+	return fmt.Sprintf("%s is referenced by %s", definitionName(e.Definition), strings.Join(referrers, ", "))
+}
+
+func describeReference(r MessageReference) string {
+	switch v := r.(type) {
+	case *Type:
+		switch p := v.parent.(type) {
+		case *Field:
+			return fmt.Sprintf("field %s", qualify(fullName(p.parent), p.label.value))
+		case *Map:
+			return fmt.Sprintf("field %s", qualify(fullName(p.parent), p.label.value))
+		case *OneOfField:
+			return fmt.Sprintf("field %s", qualify(fullName(p.parent.parent), p.label.value))
+		default:
+			panic(fmt.Sprintf("unhandled typed item type %T", p))
+		}
+	case *MessageType:
+		kind := "response"
+		if v == &v.parent.request {
+			kind = "request"
+		}
+		return fmt.Sprintf("%s of rpc %s", kind, qualify(rpcScope(v.parent), v.parent.label.value))
+	default:
+		panic(fmt.Sprintf("unhandled reference type %T", v))
+	}
+}
+
+// isInserted tells if an item is reachable from its document
+func isInserted(item interface{}) bool {
+	switch v := item.(type) {
+	case *Service:
+		return indexOf(v.parent.services, v) >= 0
+	case *RPC:
+		return indexOf(v.parent.rpcs, v) >= 0 && isInserted(v.parent)
+	case *message:
+		switch p := v.parent.(type) {
+		case *Document:
+			return indexOf(p.messages, v) >= 0
+		case *message:
+			return indexOf(p.messages, v) >= 0 && isInserted(p)
+		default:
+			panic(fmt.Sprintf("unhandled definition container type %T", p))
+		}
+	case *enum:
+		switch p := v.parent.(type) {
+		case *Document:
+			return indexOf(p.enums, v) >= 0
+		case *message:
+			return indexOf(p.enums, v) >= 0 && isInserted(p)
+		default:
+			panic(fmt.Sprintf("unhandled definition container type %T", p))
+		}
+	case *Field:
+		return indexOf(v.parent.fields, v) >= 0 && isInserted(v.parent)
+	case *Map:
+		return indexOf(v.parent.fields, v) >= 0 && isInserted(v.parent)
+	case *OneOf:
+		return indexOf(v.parent.fields, v) >= 0 && isInserted(v.parent)
+	case *OneOfField:
+		return indexOf(v.parent.fields, v) >= 0 && isInserted(v.parent)
+	case *Variant:
+		return indexOf(v.parent.fields, v) >= 0 && isInserted(v.parent)
+	case *ReservedNumber:
+		return indexOf(siblingFields(v.parent), v) >= 0 && isInserted(v.parent)
+	case *ReservedRange:
+		return indexOf(siblingFields(v.parent), v) >= 0 && isInserted(v.parent)
+	case *ReservedLabel:
+		return indexOf(siblingFields(v.parent), v) >= 0 && isInserted(v.parent)
+	case *Import:
+		return indexOf(v.parent.imports, v) >= 0
+	case *MessageType:
+		return isInserted(v.parent)
+	case *KeyType:
+		return isInserted(v.parent)
+	case *Document, *Package:
+		return true
+	case *NewMessage, *NewEnum:
+		return false
+	default:
+		panic(fmt.Sprintf("unhandled item type %T", v))
+	}
+}
+
+// referrer is the item holding a reference
+func referrer(r MessageReference) interface{} {
+	switch v := r.(type) {
+	case *Type:
+		return v.parent
+	case *MessageType:
+		return v.parent
+	default:
+		panic(fmt.Sprintf("unhandled reference type %T", v))
+	}
+}
+
+// within tells if an item is declared inside a message, at any depth
+func within(item interface{}, m *message) bool {
+	var parent *message
+	switch v := item.(type) {
+	case *Field:
+		parent = v.parent
+	case *Map:
+		parent = v.parent
+	case *OneOfField:
+		parent = v.parent.parent
+	case *RPC:
+		return false
+	default:
+		panic(fmt.Sprintf("unhandled item type %T", v))
+	}
+	for {
+		if parent == m {
+			return true
+		}
+		p, ok := parent.parent.(*message)
+		if !ok {
+			return false
+		}
+		parent = p
+	}
+}
+
+// checkReferences to a definition from inserted items outside of the removed
+// message, if any
+func checkReferences(definition ValueType, references []MessageReference, removed *message) error {
+	var out []MessageReference
+	for _, r := range references {
+		item := referrer(r)
+		if !isInserted(item) || (removed != nil && within(item, removed)) {
+			continue
+		}
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return describeReference(out[i]) < describeReference(out[j])
+	})
+	return &ReferencedError{Definition: definition, References: out}
+}
+
+// checkRemoval of a message, including everything declared inside it
+func (m *message) checkRemoval(removed *message) error {
+	references := make([]MessageReference, 0, len(m.references))
+	for r := range m.references {
+		references = append(references, r)
+	}
+	if err := checkReferences(m, references, removed); err != nil {
+		return err
+	}
+	for _, e := range m.enums {
+		if err := e.checkRemoval(removed); err != nil {
+			return err
+		}
+	}
+	for _, n := range m.messages {
+		if err := n.checkRemoval(removed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *enum) checkRemoval(removed *message) error {
+	references := make([]MessageReference, 0, len(e.references))
+	for t := range e.references {
+		references = append(references, t)
+	}
+	return checkReferences(e, references, removed)
+}
+
+// reserve the number and label of a removed field at an index, if the
+// document asks for it, and return the index following the reserved items.
+// numbers still used by aliased enum variants are not reserved, and neither is
+// number 0 of an enum.
+func reserve(d Definition, f *field, index int) (int, error) {
+	// when undoing or redoing, reservations are restored as separate edits
+	if !d.Document().reserveRemoved.value || d.Document().history.replaying {
+		return index, nil
+	}
+	inUse := false
+	switch p := d.(type) {
+	case *enum:
+		inUse = *f.number.value == 0
+		for _, other := range p.fields {
+			if other.findNumber(&f.number) != nil {
+				inUse = true
+			}
+		}
+	}
+	if !inUse {
+		n := d.NewReservedNumber()
+		if err := n.Set(*f.number.value); err != nil {
+			return index, err
+		}
+		if err := n.InsertIntoParentAt(index); err != nil {
+			return index, err
+		}
+		index++
+	}
+	l := d.NewReservedLabel()
+	if err := l.Set(f.label.value); err != nil {
+		return index, err
+	}
+	return index + 1, l.InsertIntoParentAt(index)
+}
+
+func (d *Document) removeImport(i *Import) error {
+	index := indexOf(d.imports, i)
+	if index < 0 {
+		return errNotInserted
+	}
+	old := d.imports
+	d.imports = make([]*Import, 0, len(old)-1)
+	d.imports = append(append(d.imports, old[:index]...), old[index+1:]...)
+	if d.workspace != nil {
+		if err := d.validateImports(); err != nil {
+			d.imports = old
+			return err
+		}
+	}
+	recordRemove(d, i, index, func() error {
+		return d.insertImport(i, index)
+	})
+	return nil
+}
+
+func (d *Document) removeService(s *Service) error {
+	index := indexOf(d.services, s)
+	if index < 0 {
+		return errNotInserted
+	}
+	safety := d.safety()
+	move(d.services, index, len(d.services)-1)
+	d.services = d.services[:len(d.services)-1]
+	if err := safety.check(); err != nil {
+		d.services = append(d.services, s)
+		move(d.services, len(d.services)-1, index)
+		return err
+	}
+	recordRemove(d, s, index, func() error {
+		return d.insertService(s, index)
+	})
+	return nil
+}
+
+func (s *Service) removeRPC(r *RPC) error {
+	index := indexOf(s.rpcs, r)
+	if index < 0 {
+		return errNotInserted
+	}
+	safety := s.Document().safety()
+	move(s.rpcs, index, len(s.rpcs)-1)
+	s.rpcs = s.rpcs[:len(s.rpcs)-1]
+	if err := safety.check(); err != nil {
+		s.rpcs = append(s.rpcs, r)
+		move(s.rpcs, len(s.rpcs)-1, index)
+		return err
+	}
+	recordRemove(s, r, index, func() error {
+		return s.insertRPC(r, index)
+	})
+	return nil
+}
+
+func (m *message) Remove() error {
+	if !isInserted(m) {
+		return errNotInserted
+	}
+	if err := m.checkRemoval(m); err != nil {
+		return err
+	}
+	var index int
+	switch p := m.parent.(type) {
+	case *Document:
+		index = indexOf(p.messages, m)
+		move(p.messages, index, len(p.messages)-1)
+		p.messages = p.messages[:len(p.messages)-1]
+	case *message:
+		index = indexOf(p.messages, m)
+		move(p.messages, index, len(p.messages)-1)
+		p.messages = p.messages[:len(p.messages)-1]
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", p))
+	}
+	recordRemove(m.parent, m, index, func() error {
+		return m.parent.insertMessage(m, index)
+	})
+	return nil
+}
+
+func (e *enum) Remove() error {
+	if !isInserted(e) {
+		return errNotInserted
+	}
+	if err := e.checkRemoval(nil); err != nil {
+		return err
+	}
+	var index int
+	switch p := e.parent.(type) {
+	case *Document:
+		index = indexOf(p.enums, e)
+		move(p.enums, index, len(p.enums)-1)
+		p.enums = p.enums[:len(p.enums)-1]
+	case *message:
+		index = indexOf(p.enums, e)
+		move(p.enums, index, len(p.enums)-1)
+		p.enums = p.enums[:len(p.enums)-1]
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", p))
+	}
+	recordRemove(e.parent, e, index, func() error {
+		return e.parent.insertEnum(e, index)
+	})
+	return nil
+}
+
+// removing a field or variant is recorded as one edit together with the
+// reservations made in its place
+
+func (m *message) removeField(f MessageField) error {
+	index := indexOf(m.fields, f)
+	if index < 0 {
+		return errNotInserted
+	}
+	return m.Document().compound(func() error {
+		safety := m.Document().safety()
+		old := make([]MessageField, len(m.fields))
+		copy(old, m.fields)
+		move(m.fields, index, len(m.fields)-1)
+		m.fields = m.fields[:len(m.fields)-1]
+		recordRemove(m, f.(removable), index, func() error {
+			return m.insertField(f, index)
+		})
+		var err error
+		switch v := f.(type) {
+		case *Field:
+			_, err = reserve(m, &v.field, index)
+		case *Map:
+			_, err = reserve(m, &v.field, index)
+		case *OneOf:
+			at := index
+			for _, o := range v.fields {
+				if at, err = reserve(m, &o.field, at); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			err = safety.check()
+		}
+		if err != nil {
+			m.fields = old
+			return err
+		}
+		return nil
+	})
+}
+
+func (o *OneOf) removeField(f *OneOfField) error {
+	index := indexOf(o.fields, f)
+	if index < 0 {
+		return errNotInserted
+	}
+	return o.Document().compound(func() error {
+		safety := o.Document().safety()
+		old := make([]*OneOfField, len(o.fields))
+		copy(old, o.fields)
+		move(o.fields, index, len(o.fields)-1)
+		o.fields = o.fields[:len(o.fields)-1]
+		recordRemove(o, f, index, func() error {
+			return o.insertField(f, index)
+		})
+		m := o.parent
+		oldFields := make([]MessageField, len(m.fields))
+		copy(oldFields, m.fields)
+		var err error
+		if i := indexOf(m.fields, o); i >= 0 {
+			_, err = reserve(m, &f.field, i+1)
+		}
+		if err == nil {
+			err = safety.check()
+		}
+		if err != nil {
+			o.fields = old
+			m.fields = oldFields
+			return err
+		}
+		return nil
+	})
+}
+
+func (e *enum) removeField(f EnumField) error {
+	index := indexOf(e.fields, f)
+	if index < 0 {
+		return errNotInserted
+	}
+	return e.Document().compound(func() error {
+		safety := e.Document().safety()
+		old := make([]EnumField, len(e.fields))
+		copy(old, e.fields)
+		move(e.fields, index, len(e.fields)-1)
+		e.fields = e.fields[:len(e.fields)-1]
+		recordRemove(e, f.(removable), index, func() error {
+			return e.insertField(f, index)
+		})
+		var err error
+		if v, ok := f.(*Variant); ok {
+			_, err = reserve(e, &v.field, index)
+		}
+		if err == nil {
+			err = e.validateFirst()
+		}
+		if err == nil {
+			err = safety.check()
+		}
+		if err != nil {
+			e.fields = old
+			return err
+		}
+		return nil
+	})
+}
+
+func removeReserved(d Definition, f interface{}) error {
+	switch p := d.(type) {
+	case *enum:
+		return p.removeField(f.(EnumField))
+	case *message:
+		return p.removeField(f.(MessageField))
+	default:
+		panic(fmt.Sprintf("unhandled parent type %T", p))
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemove(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+service S {
+  rpc Get (A) returns (A);
+}
+message A {
+  B b = 1;
+  A self = 2;
+  message Nested {}
+}
+message B {
+  A.Nested nested = 1;
+  oneof choice {
+    Kind kind = 2;
+  }
+}
+enum Kind {
+  NONE = 0;
+}
+`))
+	require.Nil(t, err)
+	a := FindMessage(d, "A")
+	b := FindMessage(d, "B")
+	kind := FindEnum(d, "Kind")
+
+	err = a.Remove()
+	var referenced *ReferencedError
+	require.True(t, errors.As(err, &referenced))
+	assert.Equal(t, Message(a), referenced.Definition)
+	assert.Len(t, referenced.References, 2, "self references do not count")
+	assert.Equal(t, "A is referenced by request of rpc S.Get, response of rpc S.Get", err.Error())
+
+	require.Nil(t, d.Services()[0].RPCs()[0].Remove())
+	err = a.Remove()
+	require.True(t, errors.As(err, &referenced))
+	assert.Equal(t, "A.Nested is referenced by field B.nested", err.Error())
+
+	assert.NotNil(t, kind.Remove())
+	o := b.Fields()[1].(*OneOf)
+	require.Nil(t, o.Fields()[0].Remove())
+	require.Nil(t, kind.Remove())
+	assert.Empty(t, d.Enums())
+	assert.NotNil(t, kind.Remove(), "already removed")
+
+	assert.NotNil(t, b.Remove())
+	require.Nil(t, a.Fields()[0].(*Field).Remove())
+	require.Nil(t, b.Remove())
+	require.Nil(t, a.Remove())
+	assert.Empty(t, d.Messages())
+
+	// removed items can be inserted again, but not if what they reference is
+	// gone
+	f := b.Fields()[0].(*Field)
+	assert.NotNil(t, f.InsertIntoParent())
+}
+
+func TestRemoveReserve(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message A {
+  string a = 1;
+  oneof choice {
+    string b = 2;
+    string c = 3;
+  }
+  string d = 4;
+}
+enum E {
+  option allow_alias = true;
+  X = 0;
+  Y = 0;
+  Z = 1;
+}
+`))
+	require.Nil(t, err)
+	require.Nil(t, d.ReserveRemoved().Set(true))
+	a := FindMessage(d, "A")
+	require.Nil(t, a.Fields()[0].(*Field).Remove())
+	require.Nil(t, a.Fields()[2].(*OneOf).Fields()[0].Remove())
+	assert.Equal(t, `message A {
+  reserved 1;
+  reserved "a";
+  oneof choice {
+    string c = 3;
+  }
+  reserved 2;
+  reserved "b";
+  string d = 4;
+}`, a.String())
+	require.Nil(t, a.Fields()[2].(*OneOf).Remove())
+	assert.Equal(t, `message A {
+  reserved 1;
+  reserved "a";
+  reserved 3;
+  reserved "c";
+  reserved 2;
+  reserved "b";
+  string d = 4;
+}`, a.String())
+	f := a.NewField()
+	require.Nil(t, f.Type().Set(String))
+	assert.NotNil(t, f.Number().Set(1))
+	assert.NotNil(t, f.Label().Set("a"))
+
+	e := FindEnum(d, "E")
+	require.Nil(t, e.Fields()[0].(*Variant).Remove())
+	assert.Equal(t, "enum E {\n  reserved \"X\";\n  Y = 0;\n  Z = 1;\n}", e.String(), "number is still in use")
+	require.Nil(t, e.Fields()[2].(*Variant).Remove())
+	require.Nil(t, e.Fields()[2].(*ReservedNumber).Remove())
+	assert.Equal(t, "enum E {\n  reserved \"X\";\n  Y = 0;\n  reserved \"Z\";\n}", e.String())
+}
@@ -0,0 +1,257 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// renaming an item through its label changes names derived from it elsewhere:
+// references to definitions are stored as such and therefore follow renames,
+// but the names they are printed with change, in the document and in those
+// importing it. code generators derive JSON names and Go identifiers from
+// labels, so renaming a message changes the identifiers of everything nested
+// in it.
+
+// NameKind tells what a derived name is used for
+type NameKind int
+
+const (
+	// ReferenceName is the name a definition is referred to by in a document
+	ReferenceName NameKind = iota
+	// JSONName is the key of a field, or the string of a variant, in the
+	// canonical JSON mapping
+	JSONName
+	// GoName is the identifier `protoc-gen-go` generates for an item
+	GoName
+)
+
+func (k NameKind) String() string {
+	switch k {
+	case ReferenceName:
+		return "reference"
+	case JSONName:
+		return "JSON name"
+	case GoName:
+		return "Go identifier"
+	default:
+		panic(fmt.Sprintf("unhandled name kind %d", int(k)))
+	}
+}
+
+// NameChange of a name derived from an item. references are reported on the
+// item holding them, which is a field or the `*MessageType` of an RPC.
+type NameChange struct {
+	Item interface{}
+	Kind NameKind
+	Old  string
+	New  string
+}
+
+func (c NameChange) String() string {
+	return fmt.Sprintf("%s of %s changed from %s to %s", c.Kind, describe(c.Item), c.Old, c.New)
+}
+
+// Rename the item a label belongs to, and report the derived names which
+// change as a result, in the document and all documents depending on it. with
+// `reserve` set, the old label of a field or variant is reserved in its
+// message or enum, such that it cannot be reused inadvertently. renaming and
+// reserving are undone together.
+func (l *Label) Rename(label string, reserve bool) ([]NameChange, error) {
+	old := l.value
+	var reserveIn Definition
+	if reserve {
+		reserveIn = reservation(l.parent)
+		if reserveIn == nil {
+			return nil, errors.New("only labels of fields and variants can be reserved")
+		}
+	}
+	if !isInserted(l.parent) {
+		// tentative items have nothing derived from them
+		if reserveIn != nil {
+			return nil, errNotInserted
+		}
+		return nil, l.Set(label)
+	}
+	d := l.parent.Document()
+	documents := append([]*Document{d}, d.dependents()...)
+	before := derivedNames(documents)
+	err := d.compound(func() error {
+		if err := l.Set(label); err != nil {
+			return err
+		}
+		if reserveIn == nil {
+			return nil
+		}
+		r := reserveIn.NewReservedLabel()
+		err := r.Set(old)
+		if err == nil {
+			err = r.InsertIntoParent()
+		}
+		if err != nil {
+			l.value = old
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	after := derivedNames(documents)
+	var out []NameChange
+	for _, n := range before.order {
+		if o, m := before.names[n], after.names[n]; o != m {
+			out = append(out, NameChange{n.item, n.kind, o, m})
+		}
+	}
+	return out, nil
+}
+
+// reservation is the definition the label of a field or variant is reserved
+// in, or nil for other items
+func reservation(item Labelled) Definition {
+	switch v := item.(type) {
+	case *Field:
+		return v.parent
+	case *Map:
+		return v.parent
+	case *OneOfField:
+		return v.parent.parent
+	case *Variant:
+		return v.parent
+	default:
+		return nil
+	}
+}
+
+type derivedName struct {
+	item interface{}
+	kind NameKind
+}
+
+// names derived from the items of documents, and the order they occur in
+type names struct {
+	EmptyVisitor
+	symbols symbols
+	names   map[derivedName]string
+	order   []derivedName
+}
+
+func derivedNames(documents []*Document) *names {
+	n := &names{names: make(map[derivedName]string)}
+	for _, d := range documents {
+		Walk(d, n)
+	}
+	return n
+}
+
+func (n *names) add(item interface{}, kind NameKind, name string) {
+	key := derivedName{item, kind}
+	n.names[key] = name
+	n.order = append(n.order, key)
+}
+
+func (n *names) reference(item interface{}, scope string, v ValueType) {
+	switch v.(type) {
+	case Message, Enum:
+		n.add(item, ReferenceName, n.symbols.referenceName(scope, v))
+	}
+}
+
+func (n *names) EnterDocument(d *Document, _ Path) bool {
+	n.symbols = d.symbols()
+	return true
+}
+
+func (n *names) EnterService(s *Service, _ Path) bool {
+	n.add(s, GoName, goCamelCase(s.label.value))
+	return true
+}
+
+func (n *names) EnterRPC(r *RPC, _ Path) bool {
+	n.add(r, GoName, goCamelCase(r.label.value))
+	for _, m := range []*MessageType{&r.request, &r.response} {
+		if m.value != nil {
+			n.reference(m, rpcScope(r), m.value)
+		}
+	}
+	return true
+}
+
+func (n *names) EnterMessage(m Message, _ Path) bool {
+	n.add(m, GoName, goCamelCase(relativeName(m)))
+	return true
+}
+
+func (n *names) EnterField(f *Field, _ Path) bool {
+	n.reference(f, typeScope(&f._type), f._type.value)
+	n.add(f, JSONName, jsonName(f.label.value, &f.options))
+	n.add(f, GoName, goCamelCase(f.label.value))
+	return true
+}
+
+func (n *names) EnterMap(m *Map, _ Path) bool {
+	n.reference(m, typeScope(&m._type), m._type.value)
+	n.add(m, JSONName, jsonName(m.label.value, &m.options))
+	n.add(m, GoName, goCamelCase(m.label.value))
+	return true
+}
+
+func (n *names) EnterOneOf(o *OneOf, _ Path) bool {
+	n.add(o, GoName, goCamelCase(o.label.value))
+	return true
+}
+
+func (n *names) EnterOneOfField(f *OneOfField, _ Path) bool {
+	n.reference(f, typeScope(&f._type), f._type.value)
+	n.add(f, JSONName, jsonName(f.label.value, &f.options))
+	n.add(f, GoName, goCamelCase(f.label.value))
+	return true
+}
+
+func (n *names) EnterEnum(e Enum, _ Path) bool {
+	n.add(e, GoName, goCamelCase(qualify(relativeName(e.Parent()), e.Label().Get())))
+	return true
+}
+
+// the JSON name of a variant is its label. in Go, variants are prefixed with
+// the name of the enum, or with that of the message for nested enums,
+// following the scoping rules of C++.
+func (n *names) EnterVariant(v *Variant, _ Path) bool {
+	n.add(v, JSONName, v.label.value)
+	prefix := goCamelCase(relativeName(v.parent.parent))
+	if prefix == "" {
+		prefix = goCamelCase(v.parent.label.value)
+	}
+	n.add(v, GoName, fmt.Sprint(prefix, "_", v.label.value))
+	return true
+}
+
+// goCamelCase converts a name relative to the package into a Go identifier
+// the way `protoc-gen-go` does: dots and underscores before lower case letters
+// are dropped, other dots become underscores, leading underscores become `X`,
+// and words start upper case.
+func goCamelCase(s string) string {
+	lower := func(c byte) bool { return 'a' <= c && c <= 'z' }
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && lower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && lower(s[i+1]):
+		case '0' <= c && c <= '9':
+			b = append(b, c)
+		default:
+			if lower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && lower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
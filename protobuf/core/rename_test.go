@@ -0,0 +1,125 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func changes(c []NameChange) []string {
+	out := make([]string, len(c))
+	for i, n := range c {
+		out[i] = n.String()
+	}
+	return out
+}
+
+func TestRename(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3";
+package p;
+message A {
+  string foo_bar = 1;
+  enum E {
+    NONE = 0;
+  }
+  E e = 2;
+}
+service S {
+  rpc Get (A) returns (A);
+}`)
+	b := parseInto(t, w, "b.proto", `syntax = "proto3";
+package q;
+import "a.proto";
+message B {
+  p.A a = 1;
+}`)
+
+	m := a.Messages()[0]
+	renamed, err := m.Label().Rename("Msg", false)
+	require.Nil(t, err)
+	assert.Equal(t, []string{
+		"reference of request of rpc S.Get changed from A to Msg",
+		"reference of response of rpc S.Get changed from A to Msg",
+		"Go identifier of message Msg changed from A to Msg",
+		"Go identifier of enum Msg.E changed from A_E to Msg_E",
+		"Go identifier of variant Msg.NONE changed from A_NONE to Msg_NONE",
+		"reference of field B.a changed from p.A to p.Msg",
+	}, changes(renamed))
+	assert.Contains(t, b.String(), "p.Msg a = 1;")
+
+	renamed, err = m.Label().Rename("B", false)
+	require.Nil(t, err)
+	assert.Len(t, renamed, 6, "names in other packages do not conflict")
+
+	f := m.Fields()[0].(*Field)
+	renamed, err = f.Label().Rename("baz", true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{
+		"JSON name of field B.baz changed from fooBar to baz",
+		"Go identifier of field B.baz changed from FooBar to Baz",
+	}, changes(renamed))
+	assert.Contains(t, a.String(), `reserved "foo_bar";`)
+	_, err = f.Label().Rename("foo_bar", false)
+	assert.NotNil(t, err, "old label is reserved")
+
+	require.Nil(t, a.Undo())
+	assert.Equal(t, "foo_bar", f.Label().Get())
+	assert.NotContains(t, a.String(), "reserved", "renaming and reserving are undone together")
+}
+
+func TestRenameInvalid(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3";
+message A {
+  string foo = 1;
+  reserved "bar";
+}
+enum E {
+  NONE = 0;
+}`)
+	m := a.Messages()[0]
+	_, err := m.Label().Rename("B", true)
+	assert.NotNil(t, err, "only fields and variants can be reserved")
+	assert.Equal(t, "A", m.Label().Get())
+
+	f := m.Fields()[0].(*Field)
+	_, err = f.Label().Rename("1foo", true)
+	assert.NotNil(t, err, "invalid identifier")
+	_, err = f.Label().Rename("bar", true)
+	assert.NotNil(t, err, "reserved label")
+	assert.Equal(t, "foo", f.Label().Get())
+	done, _ := a.History()
+	assert.Empty(t, done, "failed renames are not recorded")
+
+	v := a.Enums()[0].Fields()[0].(*Variant)
+	renamed, err := v.Label().Rename("UNKNOWN", true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{
+		"JSON name of variant UNKNOWN changed from NONE to UNKNOWN",
+		"Go identifier of variant UNKNOWN changed from E_NONE to E_UNKNOWN",
+	}, changes(renamed))
+	assert.Contains(t, a.String(), `reserved "NONE";`)
+
+	n := m.NewField()
+	_, err = n.Label().Rename("baz", true)
+	assert.NotNil(t, err, "tentative items cannot reserve")
+	renamed, err = n.Label().Rename("baz", false)
+	require.Nil(t, err)
+	assert.Empty(t, renamed)
+}
+
+func TestGoCamelCase(t *testing.T) {
+	for in, out := range map[string]string{
+		"foo_bar":     "FooBar",
+		"foo_Bar":     "Foo_Bar",
+		"_foo":        "XFoo",
+		"Outer.Inner": "Outer_Inner",
+		"Outer.inner": "OuterInner",
+		"foo2bar":     "Foo2Bar",
+		"FOO":         "FOO",
+	} {
+		assert.Equal(t, out, goCamelCase(in), in)
+	}
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"errors"
 	"fmt"
 )
 
@@ -16,7 +15,7 @@ type Definition interface {
 	Document() *Document
 
 	validate() error
-	hasLabel(*Label) bool
+	findLabel(*Label) *Label
 	validateLabel(*Label) error
 	validateNumber(FieldNumber) error
 }
@@ -31,8 +30,31 @@ type DefinitionContainer interface {
 	Document() *Document
 
 	validateLabel(*Label) error
-	insertMessage(*message) error
-	insertEnum(*enum) error
+	insertMessage(*message, int) error
+	insertEnum(*enum, int) error
+}
+
+// siblingFields of reserved items, which are the fields of their parent
+func siblingFields(d Definition) interface{} {
+	switch p := d.(type) {
+	case *enum:
+		return p.fields
+	case *message:
+		return p.fields
+	default:
+		panic(fmt.Sprintf("unhandled parent type %T", p))
+	}
+}
+
+func countFields(d Definition) int {
+	switch p := d.(type) {
+	case *enum:
+		return len(p.fields)
+	case *message:
+		return len(p.fields)
+	default:
+		panic(fmt.Sprintf("unhandled parent type %T", p))
+	}
 }
 
 type ReservedNumber struct {
@@ -41,21 +63,33 @@ type ReservedNumber struct {
 }
 
 func (r *ReservedNumber) InsertIntoParent() error {
+	return r.InsertIntoParentAt(countFields(r.parent))
+}
+
+func (r *ReservedNumber) InsertIntoParentAt(index int) error {
 	switch p := r.parent.(type) {
 	case *enum:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	case *message:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	default:
 		panic(fmt.Sprintf("unhandled parent type %T", p))
 	}
 }
 
-func (r ReservedNumber) Get() *uint {
+func (r *ReservedNumber) Remove() error {
+	return removeReserved(r.parent, r)
+}
+
+func (r *ReservedNumber) Position() *Position {
+	return &Position{r, func() interface{} { return siblingFields(r.parent) }}
+}
+
+func (r ReservedNumber) Get() *int32 {
 	return r.number.Get()
 }
 
-func (r *ReservedNumber) Set(value uint) error {
+func (r *ReservedNumber) Set(value int32) error {
 	return r.number.Set(value)
 }
 
@@ -83,12 +117,12 @@ func (r ReservedNumber) validateAsMessageField() error {
 	return r.validateAsEnumField()
 }
 
-func (r ReservedNumber) hasLabel(l *Label) bool {
-	return false
+func (r ReservedNumber) findLabel(l *Label) *Label {
+	return nil
 }
 
-func (r *ReservedNumber) hasNumber(n FieldNumber) bool {
-	return r.number.hasNumber(n)
+func (r *ReservedNumber) findNumber(n FieldNumber) FieldNumber {
+	return r.number.findNumber(n)
 }
 
 func (r ReservedNumber) intersects(other FieldNumber) bool {
@@ -110,16 +144,28 @@ func (r *ReservedRange) End() *Number {
 }
 
 func (r *ReservedRange) InsertIntoParent() error {
+	return r.InsertIntoParentAt(countFields(r.parent))
+}
+
+func (r *ReservedRange) InsertIntoParentAt(index int) error {
 	switch p := r.parent.(type) {
 	case *enum:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	case *message:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	default:
 		panic(fmt.Sprintf("unhandled parent type %T", p))
 	}
 }
 
+func (r *ReservedRange) Remove() error {
+	return removeReserved(r.parent, r)
+}
+
+func (r *ReservedRange) Position() *Position {
+	return &Position{r, func() interface{} { return siblingFields(r.parent) }}
+}
+
 func (r *ReservedRange) Parent() Definition {
 	return r.parent
 }
@@ -132,8 +178,11 @@ func (r *ReservedRange) String() string {
 	return r.Document().Printer.ReservedRange(r)
 }
 
-func (r *ReservedRange) hasNumber(other FieldNumber) bool {
-	return r != other && r.intersects(other)
+func (r *ReservedRange) findNumber(other FieldNumber) FieldNumber {
+	if r != other && r.intersects(other) {
+		return r
+	}
+	return nil
 }
 
 func (r *ReservedRange) intersects(other FieldNumber) bool {
@@ -147,8 +196,8 @@ func (r *ReservedRange) intersects(other FieldNumber) bool {
 	}
 }
 
-func (r ReservedRange) hasLabel(l *Label) bool {
-	return false
+func (r ReservedRange) findLabel(l *Label) *Label {
+	return nil
 }
 
 func (r *ReservedRange) validateNumber(n FieldNumber) error {
@@ -171,7 +220,7 @@ func (r *ReservedRange) validateNumber(n FieldNumber) error {
 	}
 	switch {
 	case *r.start.value >= *r.end.value:
-		return errors.New("end of number range must be greater than start")
+		return &InvalidRangeError{r, *r.start.value, *r.end.value}
 	default:
 		return r.parent.validateNumber(r)
 	}
@@ -202,16 +251,28 @@ func (r *ReservedLabel) Set(value string) error {
 }
 
 func (r *ReservedLabel) InsertIntoParent() error {
+	return r.InsertIntoParentAt(countFields(r.parent))
+}
+
+func (r *ReservedLabel) InsertIntoParentAt(index int) error {
 	switch p := r.parent.(type) {
 	case *enum:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	case *message:
-		return p.insertField(r)
+		return p.insertField(r, index)
 	default:
 		panic(fmt.Sprintf("unhandled parent type %T", p))
 	}
 }
 
+func (r *ReservedLabel) Remove() error {
+	return removeReserved(r.parent, r)
+}
+
+func (r *ReservedLabel) Position() *Position {
+	return &Position{r, func() interface{} { return siblingFields(r.parent) }}
+}
+
 func (r ReservedLabel) validateLabel(l *Label) error {
 	return r.parent.validateLabel(l)
 }
@@ -236,10 +297,10 @@ func (r ReservedLabel) validateAsMessageField() error {
 	return r.validateAsEnumField()
 }
 
-func (r *ReservedLabel) hasLabel(l *Label) bool {
-	return r.label.hasLabel(l)
+func (r *ReservedLabel) findLabel(l *Label) *Label {
+	return r.label.findLabel(l)
 }
 
-func (r ReservedLabel) hasNumber(n FieldNumber) bool {
-	return false
+func (r ReservedLabel) findNumber(n FieldNumber) FieldNumber {
+	return nil
 }
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+)
+
+// in safe mode, edits are checked against a baseline version of the document,
+// such as the last released one. edits which introduce a wire incompatibility
+// with the baseline are rolled back. incompatibilities already present before
+// an edit do not prevent it.
+//
+// definitions are matched with the baseline by name, so renaming or moving a
+// definition of the baseline is refused as well: its fields could not be
+// compared any more, and subsequent edits to them would go unchecked.
+//
+// checked are setting labels, flags, numbers, types, key types and RPC
+// message types, changing the package, inserting and removing fields,
+// variants, reserved items and RPCs, removing services, and moving
+// definitions.
+
+// SetBaseline enables safe mode for the document. the baseline is typically
+// parsed from the released version, and must not be edited while it is in
+// use. it is not copied, but has to be wire compatible with the document.
+// passing `nil` disables safe mode.
+func (d *Document) SetBaseline(baseline *Document) error {
+	if baseline == d {
+		return errors.New("document cannot be its own baseline")
+	}
+	if baseline != nil {
+		for _, i := range CompareCompatibility(baseline, d) {
+			if i.Breaks&BreaksWire != 0 {
+				return &IncompatibleChangeError{i}
+			}
+		}
+	}
+	d.baseline = baseline
+	return nil
+}
+
+func (d *Document) Baseline() *Document {
+	return d.baseline
+}
+
+// safety of an edit is determined by comparing the incompatibilities with the
+// baseline after the edit to those before. they are told apart by the items
+// compared, since their descriptions contain labels, which may change.
+type safety struct {
+	document *Document
+	before   map[compared]bool
+}
+
+type compared struct {
+	old, new interface{}
+}
+
+// safety before an edit, which is `nil` if not in safe mode
+func (d *Document) safety() *safety {
+	if d.baseline == nil {
+		return nil
+	}
+	s := &safety{d, make(map[compared]bool)}
+	for _, i := range CompareCompatibility(d.baseline, d) {
+		if checked(i) {
+			s.before[compared{i.Old, i.New}] = true
+		}
+	}
+	return s
+}
+
+// check the edit made since obtaining the safety
+func (s *safety) check() error {
+	if s == nil {
+		return nil
+	}
+	for _, i := range CompareCompatibility(s.document.baseline, s.document) {
+		if checked(i) && !s.before[compared{i.Old, i.New}] {
+			return &IncompatibleChangeError{i}
+		}
+	}
+	return nil
+}
+
+// checked incompatibilities break the wire format, or leave a definition of
+// the baseline without a match
+func checked(i Incompatibility) bool {
+	if i.Breaks&BreaksWire != 0 {
+		return true
+	}
+	switch i.Old.(type) {
+	case Message, Enum:
+		return true
+	default:
+		return false
+	}
+}
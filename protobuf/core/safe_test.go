@@ -0,0 +1,159 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMode(t *testing.T) {
+	input := `syntax = "proto3";
+service S {
+  rpc Get (M) returns (M);
+}
+enum E {
+  ZERO = 0;
+  ONE = 1;
+}
+message M {
+  int32 id = 1;
+  repeated string names = 2;
+  map <string,int32> counts = 3;
+  E kind = 4;
+}
+`
+	baseline, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	require.Nil(t, d.SetBaseline(baseline))
+	assert.Equal(t, baseline, d.Baseline())
+	assert.NotNil(t, d.SetBaseline(d))
+
+	m := FindMessage(d, "M")
+	id := m.Fields()[0].(*Field)
+	names := m.Fields()[1].(*Field)
+	counts := m.Fields()[2].(*Map)
+
+	var incompatible *IncompatibleChangeError
+	err = names.Repeated().Set(false)
+	require.True(t, errors.As(err, &incompatible))
+	assert.Equal(t, names, incompatible.Incompatibility.New)
+	assert.True(t, names.Repeated().Get(), "rolled back")
+
+	assert.NotNil(t, id.Type().Set(String))
+	assert.Equal(t, Int32, id.Type().Get())
+	assert.Nil(t, id.Type().Set(Uint64), "same wire type")
+	assert.NotNil(t, id.Number().Set(5))
+	assert.Equal(t, int32(1), *id.Number().Get())
+	assert.NotNil(t, counts.KeyType().Set(Int32))
+	assert.Equal(t, String, counts.KeyType().Get())
+	assert.NotNil(t, d.Services()[0].RPCs()[0].Response().Stream().Set(true))
+	assert.NotNil(t, d.Services()[0].RPCs()[0].Remove())
+	assert.NotNil(t, id.Remove())
+	assert.Equal(t, 4, len(m.Fields()))
+
+	// removal is allowed with the number reserved
+	require.Nil(t, d.ReserveRemoved().Set(true))
+	assert.Nil(t, id.Remove())
+	reserved := m.Fields()[0].(*ReservedNumber)
+	assert.NotNil(t, reserved.Remove())
+	assert.NotNil(t, reserved.Set(7))
+
+	// new items can be edited freely
+	n := m.NewField()
+	require.Nil(t, n.Label().Set("other"))
+	require.Nil(t, n.Number().Set(6))
+	require.Nil(t, n.Type().Set(String))
+	require.Nil(t, n.InsertIntoParent())
+	assert.Nil(t, n.Type().Set(Bytes))
+	assert.Nil(t, n.Repeated().Set(true))
+
+	v := FindEnum(d, "E").Fields()[1].(*Variant)
+	assert.Nil(t, v.Remove())
+	assert.Nil(t, d.SetBaseline(nil))
+	assert.Nil(t, names.Repeated().Set(false))
+}
+
+func TestSafeModeBaseline(t *testing.T) {
+	baseline, err := Parse(strings.NewReader(`syntax = "proto3";
+message M {
+  int32 id = 1;
+}
+`))
+	require.Nil(t, err)
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+message M {
+  string id = 1;
+}
+`))
+	require.Nil(t, err)
+	var incompatible *IncompatibleChangeError
+	require.True(t, errors.As(d.SetBaseline(baseline), &incompatible))
+	assert.Nil(t, d.Baseline())
+}
+
+func TestSafeModeRename(t *testing.T) {
+	input := `syntax = "proto3";
+package p;
+message M {
+  int32 id = 1;
+}
+`
+	baseline, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	d, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	require.Nil(t, d.SetBaseline(baseline))
+
+	m := FindMessage(d, "M")
+	id := m.Fields()[0].(*Field)
+	var incompatible *IncompatibleChangeError
+	require.True(t, errors.As(m.Label().Set("Renamed"), &incompatible), "message would not be compared any more")
+	assert.Equal(t, "M", m.Label().Get())
+	assert.NotNil(t, id.Number().Set(2), "fields of the message are still checked")
+	assert.EqualValues(t, 1, *id.Number().Get())
+	require.True(t, errors.As(d.Package().Set("q"), &incompatible))
+	assert.Equal(t, "p", d.Package().Get())
+	assert.NotNil(t, d.Package().Unset())
+
+	assert.Nil(t, id.Label().Set("key"), "fields are matched by number")
+	n := d.NewMessage()
+	require.Nil(t, n.Label().Set("N"))
+	require.Nil(t, n.InsertIntoParent())
+	assert.Nil(t, FindMessage(d, "N").Label().Set("Other"), "new definitions can be renamed")
+}
+
+func TestSafeModeMove(t *testing.T) {
+	input := `syntax = "proto3";
+message A {
+  B b = 1;
+}
+message B {}
+message C {}
+`
+	baseline, err := Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", input)
+	require.Nil(t, a.SetBaseline(baseline))
+	b := parseInto(t, w, "b.proto", `syntax = "proto3";
+import "a.proto";
+message D {}
+`)
+
+	var incompatible *IncompatibleChangeError
+	m := FindMessage(a, "B")
+	require.True(t, errors.As(m.MoveInto(FindMessage(a, "A")), &incompatible), "type of field A.b changes")
+	assert.Equal(t, a, m.Parent())
+	require.True(t, errors.As(FindMessage(a, "C").MoveInto(b), &incompatible), "C would not be compared any more")
+	assert.Equal(t, a, FindMessage(a, "C").Parent())
+
+	require.Nil(t, FindMessage(b, "D").MoveInto(a), "new definitions can be moved")
+	require.Nil(t, FindMessage(a, "D").MoveInto(FindMessage(a, "C")))
+	done, _ := a.History()
+	assert.Len(t, done, 2, "failed moves are not recorded")
+}
@@ -0,0 +1,409 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseError reports the position in the source text where parsing failed.
+type ParseError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenInteger
+	tokenFloat
+	tokenString
+	tokenSymbol
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of input"
+	case tokenIdentifier:
+		return "identifier"
+	case tokenInteger:
+		return "integer"
+	case tokenFloat:
+		return "floating point number"
+	case tokenString:
+		return "string"
+	case tokenSymbol:
+		return "symbol"
+	default:
+		panic(fmt.Sprintf("unhandled token kind %d", k))
+	}
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+func (p position) errorf(format string, args ...interface{}) error {
+	return p.wrap(fmt.Errorf(format, args...))
+}
+
+func (p position) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ParseError{Line: p.line, Column: p.column, Err: err}
+}
+
+type token struct {
+	kind tokenKind
+	// text is the literal source text, except for strings, where it holds the
+	// unquoted value
+	text string
+	position
+	// comments preceding the token
+	leading  string
+	detached []string
+	// trailing comment of the previous token
+	trailing string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return t.kind.String()
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// scanner splits proto3 source text into tokens, skipping whitespace and
+// attaching comments to the tokens around them.
+type scanner struct {
+	input  []rune
+	offset int
+	// position of the next rune to read
+	position
+	// line of the previous token
+	previous int
+}
+
+func newScanner(r io.Reader) (*scanner, error) {
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanner{
+		input:    []rune(string(input)),
+		position: position{line: 1, column: 1},
+	}
+	return s, nil
+}
+
+// peek at the rune `ahead` positions after the next one without consuming it
+func (s *scanner) peek(ahead int) (rune, bool) {
+	if s.offset+ahead >= len(s.input) {
+		return 0, false
+	}
+	return s.input[s.offset+ahead], true
+}
+
+func (s *scanner) read() (rune, bool) {
+	r, ok := s.peek(0)
+	if !ok {
+		return 0, false
+	}
+	s.offset++
+	if r == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return r, true
+}
+
+func (s *scanner) next() (t token, err error) {
+	comments, err := s.skip()
+	if err != nil {
+		return token{}, err
+	}
+	defer func() {
+		t.leading, t.detached, t.trailing = attach(comments, s.previous, t.line)
+		s.previous = s.line
+	}()
+	return s.token()
+}
+
+func (s *scanner) token() (token, error) {
+	start := s.position
+	r, ok := s.peek(0)
+	switch {
+	case !ok:
+		return token{kind: tokenEOF, position: start}, nil
+	case r == '_' || unicode.IsLetter(r):
+		text := s.readWhile(func(r rune) bool {
+			return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+		})
+		return token{kind: tokenIdentifier, text: text, position: start}, nil
+	case unicode.IsDigit(r):
+		return s.number()
+	case r == '"' || r == '\'':
+		text, err := s.quoted()
+		return token{kind: tokenString, text: text, position: start}, err
+	default:
+		s.read()
+		return token{kind: tokenSymbol, text: string(r), position: start}, nil
+	}
+}
+
+type comment struct {
+	text  string
+	block bool
+	start int
+	end   int
+}
+
+// skip whitespace and collect comments
+func (s *scanner) skip() (comments []comment, err error) {
+	for {
+		r, ok := s.peek(0)
+		if !ok {
+			return comments, nil
+		}
+		n, _ := s.peek(1)
+		switch {
+		case unicode.IsSpace(r):
+			s.read()
+		case r == '/' && n == '/':
+			line := s.line
+			text := s.readWhile(func(r rune) bool { return r != '\n' })
+			comments = append(comments, comment{lineComment(text), false, line, line})
+		case r == '/' && n == '*':
+			line := s.line
+			text, err := s.blockComment()
+			if err != nil {
+				return nil, err
+			}
+			comments = append(comments, comment{text, true, line, s.line})
+		default:
+			return comments, nil
+		}
+	}
+}
+
+func (s *scanner) blockComment() (string, error) {
+	start := s.position
+	// consume the opening `/*`
+	s.read()
+	s.read()
+	var b strings.Builder
+	for {
+		r, ok := s.read()
+		if !ok {
+			return "", start.errorf("unterminated comment")
+		}
+		if n, _ := s.peek(0); r == '*' && n == '/' {
+			s.read()
+			return blockComment(b.String()), nil
+		}
+		b.WriteRune(r)
+	}
+}
+
+// lineComment text without the leading `//` and the space following it
+func lineComment(text string) string {
+	text = strings.TrimPrefix(text, "//")
+	text = strings.TrimPrefix(text, " ")
+	return strings.TrimRightFunc(text, unicode.IsSpace)
+}
+
+// blockComment text without the decoration commonly found on each line
+func blockComment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		l = strings.TrimLeftFunc(l, unicode.IsSpace)
+		if i > 0 {
+			l = strings.TrimPrefix(l, "*")
+		}
+		l = strings.TrimPrefix(l, " ")
+		lines[i] = strings.TrimRightFunc(l, unicode.IsSpace)
+	}
+	for len(lines) > 1 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attach comments between two tokens to them, following the rules of
+// `protoc`: a single comment on the same line as the previous token trails
+// it. consecutive line comments form a block, and blocks are separated by
+// blank lines. the last block leads the next token unless a blank line
+// separates them, and all other blocks are detached.
+func attach(comments []comment, previous, next int) (leading string, detached []string, trailing string) {
+	var blocks []comment
+	for i, c := range comments {
+		if i == 0 && c.start == previous && c.end == previous {
+			trailing = c.text
+			continue
+		}
+		if n := len(blocks) - 1; n >= 0 && !c.block && !blocks[n].block && c.start == blocks[n].end+1 {
+			blocks[n].text = fmt.Sprint(blocks[n].text, "\n", c.text)
+			blocks[n].end = c.end
+			continue
+		}
+		blocks = append(blocks, c)
+	}
+	if n := len(blocks) - 1; n >= 0 && blocks[n].end >= next-1 {
+		leading = blocks[n].text
+		blocks = blocks[:n]
+	}
+	for _, b := range blocks {
+		detached = append(detached, b.text)
+	}
+	return
+}
+
+func (s *scanner) readWhile(accept func(rune) bool) string {
+	var b strings.Builder
+	for {
+		r, ok := s.peek(0)
+		if !ok || !accept(r) {
+			return b.String()
+		}
+		s.read()
+		b.WriteRune(r)
+	}
+}
+
+func (s *scanner) number() (token, error) {
+	start := s.position
+	text := s.readWhile(func(r rune) bool {
+		return r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	})
+	// exponents may be signed
+	lower := strings.ToLower(text)
+	if !strings.HasPrefix(lower, "0x") && strings.HasSuffix(lower, "e") {
+		if r, _ := s.peek(0); r == '+' || r == '-' {
+			s.read()
+			text = fmt.Sprint(text, string(r), s.readWhile(unicode.IsDigit))
+			lower = strings.ToLower(text)
+		}
+	}
+	if _, err := parseInteger(text, 64); err == nil {
+		return token{kind: tokenInteger, text: text, position: start}, nil
+	}
+	// `strconv` also accepts integers and digits separated by underscores as
+	// floats, which the language does not
+	if !strings.HasPrefix(lower, "0x") && strings.ContainsAny(lower, ".e") && !strings.ContainsRune(text, '_') {
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return token{kind: tokenFloat, text: text, position: start}, nil
+		}
+	}
+	return token{}, start.errorf("invalid number %q", text)
+}
+
+// parseInteger literal without sign, which is decimal, octal with a leading
+// `0`, or hexadecimal with a leading `0x`. unlike Go literals, it must not
+// contain underscores or other base prefixes.
+func parseInteger(text string, bits int) (uint64, error) {
+	base, digits := 10, text
+	switch {
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		base, digits = 16, text[2:]
+	case strings.HasPrefix(text, "0") && len(text) > 1:
+		base, digits = 8, text[1:]
+	}
+	// explicit bases reject underscores
+	return strconv.ParseUint(digits, base, bits)
+}
+
+func (s *scanner) quoted() (string, error) {
+	start := s.position
+	quote, _ := s.read()
+	var b strings.Builder
+	for {
+		r, ok := s.read()
+		if !ok || r == '\n' {
+			return "", start.errorf("unterminated string")
+		}
+		switch r {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if err := s.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+var simpleEscapes = map[rune]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+func (s *scanner) escape(b *strings.Builder) error {
+	start := s.position
+	r, ok := s.read()
+	if !ok {
+		return start.errorf("unterminated escape sequence")
+	}
+	if c, ok := simpleEscapes[r]; ok {
+		b.WriteByte(c)
+		return nil
+	}
+	var digits string
+	var base int
+	switch {
+	case r == 'x' || r == 'X':
+		digits = s.readDigits(2, func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) })
+		base = 16
+	case r >= '0' && r <= '7':
+		digits = fmt.Sprint(string(r), s.readDigits(2, func(r rune) bool { return r >= '0' && r <= '7' }))
+		base = 8
+	default:
+		return start.errorf("invalid escape sequence %q", fmt.Sprint("\\", string(r)))
+	}
+	value, err := strconv.ParseUint(digits, base, 8)
+	if err != nil {
+		return start.errorf("invalid escape sequence %q", fmt.Sprint("\\", string(r), digits))
+	}
+	b.WriteByte(byte(value))
+	return nil
+}
+
+func (s *scanner) readDigits(max int, accept func(rune) bool) string {
+	var b strings.Builder
+	for i := 0; i < max; i++ {
+		r, ok := s.peek(0)
+		if !ok || !accept(r) {
+			break
+		}
+		s.read()
+		b.WriteRune(r)
+	}
+	return b.String()
+}
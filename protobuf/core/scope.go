@@ -0,0 +1,176 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// symbols maps fully qualified names to the items declaring them. only items
+// relevant for name resolution are recorded: packages, messages, enums and
+// services.
+type symbols map[string]interface{}
+
+// packageName marks the components of a package name as symbols
+type packageName string
+
+// symbols of a document and all its dependencies. like `protoc`, we resolve
+// names against everything loaded, and only then check that the definition
+// found is actually visible.
+func (d *Document) symbols() symbols {
+	s := make(symbols)
+	for _, dep := range d.dependencies() {
+		s.addDocument(dep)
+	}
+	return s
+}
+
+func (s symbols) addDocument(d *Document) {
+	pkg := d._package.label.value
+	if pkg != "" {
+		parts := strings.Split(pkg, ".")
+		for i := range parts {
+			name := strings.Join(parts[:i+1], ".")
+			if _, ok := s[name]; !ok {
+				s[name] = packageName(name)
+			}
+		}
+	}
+	for _, sv := range d.services {
+		s[qualify(pkg, sv.label.value)] = sv
+	}
+	s.addDefinitions(pkg, d)
+}
+
+func (s symbols) addDefinitions(scope string, c DefinitionContainer) {
+	for _, m := range c.Messages() {
+		name := qualify(scope, m.Label().Get())
+		s[name] = m
+		s.addDefinitions(name, m)
+	}
+	for _, e := range c.Enums() {
+		s[qualify(scope, e.Label().Get())] = e
+	}
+}
+
+// lookup a type name relative to a scope, following the rules of `protoc`:
+// the first component of the name is searched for from the innermost scope
+// outwards. if it is found, the rest of the name must resolve within the
+// definition found, otherwise lookup fails. names with a leading dot are fully
+// qualified.
+func (s symbols) lookup(scope, name string) ValueType {
+	if strings.HasPrefix(name, ".") {
+		return asType(s[name[1:]])
+	}
+	first := name
+	if i := strings.Index(name, "."); i >= 0 {
+		first = name[:i]
+	}
+	for {
+		if found, ok := s[qualify(scope, first)]; ok {
+			if first != name {
+				if isAggregate(found) {
+					return asType(s[qualify(scope, name)])
+				}
+			} else if asType(found) != nil {
+				return asType(found)
+			}
+		}
+		if scope == "" {
+			return nil
+		}
+		scope = outerScope(scope)
+	}
+}
+
+func isAggregate(item interface{}) bool {
+	switch item.(type) {
+	case packageName, Message, Enum, *Service:
+		return true
+	default:
+		return false
+	}
+}
+
+func asType(item interface{}) ValueType {
+	switch v := item.(type) {
+	case Message:
+		return v
+	case Enum:
+		return v
+	default:
+		return nil
+	}
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func outerScope(scope string) string {
+	i := strings.LastIndex(scope, ".")
+	if i < 0 {
+		return ""
+	}
+	return scope[:i]
+}
+
+// fullName of a definition container, which is the scope of its children
+func fullName(c DefinitionContainer) string {
+	switch v := c.(type) {
+	case *Document:
+		return v._package.label.value
+	case Message:
+		return qualify(fullName(v.Parent()), v.Label().Get())
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", v))
+	}
+}
+
+// definitionName is the fully qualified name of a message or enum
+func definitionName(v ValueType) string {
+	switch t := v.(type) {
+	case Message:
+		return fullName(t)
+	case Enum:
+		return qualify(fullName(t.Parent()), t.Label().Get())
+	default:
+		panic(fmt.Sprintf("unhandled value type %T", t))
+	}
+}
+
+// referenceName of a definition, as written in a reference from within a
+// scope: the shortest trailing part of its full name which `protoc` resolves
+// to that definition, or the fully qualified name with a leading dot.
+func (s symbols) referenceName(scope string, v ValueType) string {
+	full := definitionName(v)
+	parts := strings.Split(full, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		name := strings.Join(parts[i:], ".")
+		if s.lookup(scope, name) == v {
+			return name
+		}
+	}
+	return "." + full
+}
+
+// typeScope is the scope type references of a field are resolved in
+func typeScope(t *Type) string {
+	switch v := t.parent.(type) {
+	case *Field:
+		return fullName(v.parent)
+	case *Map:
+		return fullName(v.parent)
+	case *OneOfField:
+		return fullName(v.parent.parent)
+	default:
+		panic(fmt.Sprintf("unhandled typed item type %T", v))
+	}
+}
+
+// rpcScope is the scope message references of an RPC are resolved in
+func rpcScope(r *RPC) string {
+	return qualify(r.Document()._package.label.value, r.parent.label.value)
+}
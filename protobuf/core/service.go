@@ -3,20 +3,28 @@ package core
 import "fmt"
 
 type Service struct {
-	label  Label
-	rpcs   map[*RPC]struct{}
-	parent *Document
+	label      Label
+	deprecated Flag
+	comments   Comments
+	rpcs       []*RPC
+	parent     *Document
 }
 
 func (s *Service) Label() *Label {
 	return &s.label
 }
 
+func (s *Service) Deprecated() *Flag {
+	return &s.deprecated
+}
+
+func (s *Service) Comments() *Comments {
+	return &s.comments
+}
+
 func (s *Service) RPCs() (out []*RPC) {
 	out = make([]*RPC, len(s.rpcs))
-	for r := range s.rpcs {
-		out = append(out, r)
-	}
+	copy(out, s.rpcs)
 	return
 }
 
@@ -25,6 +33,8 @@ func (s *Service) NewRPC() *RPC {
 		parent: s,
 	}
 	r.label.parent = r
+	r.deprecated.parent = r
+	r.options.init(r)
 	r.request.parent = r
 	r.request.stream.parent = &r.request
 	r.response.parent = r
@@ -33,7 +43,19 @@ func (s *Service) NewRPC() *RPC {
 }
 
 func (s *Service) InsertIntoParent() error {
-	return s.parent.insertService(s)
+	return s.parent.insertService(s, len(s.parent.services))
+}
+
+func (s *Service) InsertIntoParentAt(index int) error {
+	return s.parent.insertService(s, index)
+}
+
+func (s *Service) Remove() error {
+	return s.parent.removeService(s)
+}
+
+func (s *Service) Position() *Position {
+	return &Position{s, func() interface{} { return s.parent.services }}
 }
 
 func (s *Service) Parent() *Document {
@@ -48,32 +70,46 @@ func (s *Service) String() string {
 	return s.parent.Printer.Service(s)
 }
 
-func (s *Service) hasLabel(l *Label) bool {
-	return s.label.hasLabel(l)
+func (s *Service) findLabel(l *Label) *Label {
+	return s.label.findLabel(l)
 }
 
-func (s *Service) insertRPC(r *RPC) error {
-	if s.rpcs == nil {
-		s.rpcs = make(map[*RPC]struct{})
-	}
-	if _, ok := s.rpcs[r]; ok {
+func (s *Service) insertRPC(r *RPC, index int) error {
+	if indexOf(s.rpcs, r) >= 0 {
 		return fmt.Errorf("already inserted")
 	}
+	if err := checkIndex(index, len(s.rpcs)); err != nil {
+		return err
+	}
 	if err := r.validate(); err != nil {
 		return err
 	}
-	s.rpcs[r] = struct{}{}
+	safety := s.Document().safety()
+	s.rpcs = append(s.rpcs, r)
+	move(s.rpcs, len(s.rpcs)-1, index)
+	if err := safety.check(); err != nil {
+		move(s.rpcs, index, len(s.rpcs)-1)
+		s.rpcs = s.rpcs[:len(s.rpcs)-1]
+		return err
+	}
+	recordInsert(s, r, index, func() error {
+		return s.insertRPC(r, index)
+	})
+	return nil
+}
+
+func (s *Service) validateFlag(*Flag) error {
 	return nil
 }
 
 func (s *Service) validate() error {
 	if s.label.value == "" {
-		return fmt.Errorf("label not set")
+		return &NotSetError{&s.label}
 	}
 	if err := s.parent.validateLabel(&s.label); err != nil {
 		return err
 	}
-	for r := range s.rpcs {
+	for _, r := range s.rpcs {
 		if err := r.validate(); err != nil {
 			return err
 		}
@@ -91,10 +127,9 @@ func (s *Service) validateLabel(l *Label) error {
 	case &s.label:
 		return s.parent.validateLabel(l)
 	default:
-		for r := range s.rpcs {
-			// TODO: return error type with reference to other declaration
-			if r.label.hasLabel(l) {
-				return fmt.Errorf("label %q already declared", l.value)
+		for _, r := range s.rpcs {
+			if existing := r.findLabel(l); existing != nil {
+				return &DuplicateLabelError{existing, l, l.value}
 			}
 		}
 	}
@@ -102,16 +137,31 @@ func (s *Service) validateLabel(l *Label) error {
 }
 
 type RPC struct {
-	label    Label
-	request  MessageType
-	response MessageType
-	parent   *Service
+	label      Label
+	deprecated Flag
+	options    MethodOptions
+	comments   Comments
+	request    MessageType
+	response   MessageType
+	parent     *Service
 }
 
 func (r *RPC) Label() *Label {
 	return &r.label
 }
 
+func (r *RPC) Deprecated() *Flag {
+	return &r.deprecated
+}
+
+func (r *RPC) Options() *MethodOptions {
+	return &r.options
+}
+
+func (r *RPC) Comments() *Comments {
+	return &r.comments
+}
+
 func (r *RPC) Request() *MessageType {
 	return &r.request
 }
@@ -120,8 +170,61 @@ func (r *RPC) Response() *MessageType {
 	return &r.response
 }
 
+// StreamingMode of an RPC, determined by which of its message types are
+// streamed.
+type StreamingMode int
+
+const (
+	Unary StreamingMode = iota
+	ClientStreaming
+	ServerStreaming
+	BidiStreaming
+)
+
+func (s StreamingMode) String() string {
+	switch s {
+	case Unary:
+		return "unary"
+	case ClientStreaming:
+		return "client streaming"
+	case ServerStreaming:
+		return "server streaming"
+	case BidiStreaming:
+		return "bidirectional streaming"
+	default:
+		panic(fmt.Sprintf("unhandled streaming mode %d", s))
+	}
+}
+
+// Streaming mode of the RPC, as declared by `stream` on its request and
+// response types
+func (r *RPC) Streaming() StreamingMode {
+	switch {
+	case r.request.stream.value && r.response.stream.value:
+		return BidiStreaming
+	case r.request.stream.value:
+		return ClientStreaming
+	case r.response.stream.value:
+		return ServerStreaming
+	default:
+		return Unary
+	}
+}
+
 func (r *RPC) InsertIntoParent() error {
-	return r.parent.insertRPC(r)
+	return r.parent.insertRPC(r, len(r.parent.rpcs))
+}
+
+func (r *RPC) InsertIntoParentAt(index int) error {
+	return r.parent.insertRPC(r, index)
+}
+
+func (r *RPC) Remove() error {
+	return r.parent.removeRPC(r)
+}
+
+func (r *RPC) Position() *Position {
+	return &Position{r, func() interface{} { return r.parent.rpcs }}
 }
 
 func (r *RPC) Parent() *Service {
@@ -132,18 +235,26 @@ func (r *RPC) Document() *Document {
 	return r.parent.Document()
 }
 
-func (r RPC) String() string {
-	return r.Document().Printer.RPC(&r)
+func (r *RPC) String() string {
+	return r.Document().Printer.RPC(r)
 }
 
-func (r *RPC) hasLabel(l *Label) bool {
-	return r.label.hasLabel(l)
+func (r *RPC) findLabel(l *Label) *Label {
+	return r.label.findLabel(l)
 }
 
 func (r *RPC) validateLabel(l *Label) error {
 	return r.parent.validateLabel(l)
 }
 
+func (r *RPC) validateFlag(*Flag) error {
+	return nil
+}
+
+func (r *RPC) validateOption(Option) error {
+	return nil
+}
+
 func (r *RPC) validate() error {
 	if err := r.label.validate(); err != nil {
 		return err
@@ -170,14 +281,24 @@ func (m MessageType) Get() Message {
 
 }
 func (m *MessageType) Set(value Message) error {
+	safety := m.parent.Document().safety()
 	old := m.value
 	m.value = value
-	if err := m.validate(); err != nil {
+	err := m.validate()
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
 		m.value = old
 		return err
 	}
 	if old != nil {
 		old.removeReference(m)
+		record(&MessageTypeChanged{m, old, value}, func() error {
+			return m.Set(old)
+		}, func() error {
+			return m.Set(value)
+		})
 	}
 	value.addReference(m)
 	return nil
@@ -186,9 +307,9 @@ func (m *MessageType) Set(value Message) error {
 
 func (m *MessageType) validate() error {
 	if m.value == nil {
-		return fmt.Errorf("message must not be nil")
+		return &NotSetError{m}
 	}
-	return nil
+	return m.parent.Document().validateReference(m.value)
 }
 
 func (m *MessageType) Stream() *Flag {
@@ -199,7 +320,10 @@ func (m *MessageType) Parent() *RPC {
 	return m.parent
 }
 
+func (m *MessageType) Document() *Document {
+	return m.parent.Document()
+}
+
 func (m *MessageType) validateFlag(f *Flag) error {
-	// TODO: handle "safe mode"
 	return nil
 }
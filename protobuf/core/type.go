@@ -1,7 +1,5 @@
 package core
 
-import "fmt"
-
 // ValueType identifier. Valid values are all built-in types and `Message`s.
 // Note that API consumers cannot create types which implement interface
 // `Message`, and the package will only emit properly constructed `Message`s.
@@ -28,6 +26,7 @@ type Type struct {
 type Typed interface {
 	Type() *Type
 	Document() *Document
+	validateType(*Type) error
 }
 
 func (t *Type) Get() ValueType {
@@ -35,13 +34,18 @@ func (t *Type) Get() ValueType {
 }
 
 func (t *Type) Set(value ValueType) error {
+	safety := t.parent.Document().safety()
 	old := t.value
 	t.value = value
-	if err := t.validate(); err != nil {
+	err := t.validate()
+	if err == nil {
+		err = safety.check()
+	}
+	if err != nil {
 		t.value = old
 		return err
 	}
-	switch old := t.value.(type) {
+	switch old := old.(type) {
 	case Message:
 		old.removeReference(t)
 	case Enum:
@@ -53,6 +57,13 @@ func (t *Type) Set(value ValueType) error {
 	case Enum:
 		v.addReference(t)
 	}
+	if old != nil {
+		record(&TypeChanged{t, old, value}, func() error {
+			return t.Set(old)
+		}, func() error {
+			return t.Set(value)
+		})
+	}
 	return nil
 }
 
@@ -60,15 +71,44 @@ func (t *Type) Parent() Typed {
 	return t.parent
 }
 
+// Definitions a type can refer to: the messages and enums visible from its
+// document, in the order of its dependencies, named as they are printed in the
+// scope of the typed item.
+func (t *Type) Definitions() (names []string, values []ValueType) {
+	d := t.parent.Document()
+	s, scope, visible := d.symbols(), typeScope(t), d.visibleDocuments()
+	var definitions func(c DefinitionContainer)
+	definitions = func(c DefinitionContainer) {
+		for _, m := range c.Messages() {
+			names = append(names, s.referenceName(scope, m))
+			values = append(values, m)
+			definitions(m)
+		}
+		for _, e := range c.Enums() {
+			names = append(names, s.referenceName(scope, e))
+			values = append(values, e)
+		}
+	}
+	for _, dep := range d.dependencies() {
+		if visible[dep] {
+			definitions(dep)
+		}
+	}
+	return names, values
+}
+
 func (t Type) String() string {
 	return t.parent.Document().Printer.Type(&t)
 }
 
 func (t *Type) validate() error {
 	if t.value == nil {
-		return fmt.Errorf("type must not be nil")
+		return &NotSetError{t}
 	}
-	return nil
+	if err := t.parent.Document().validateReference(t.value); err != nil {
+		return err
+	}
+	return t.parent.validateType(t)
 }
 
 type keyType string
@@ -0,0 +1,233 @@
+package core
+
+import "fmt"
+
+// Visitor of the items in a document, with hooks for entering and leaving
+// each kind of item. entering returns whether to visit the children of the
+// item, and leaving is called either way. the path holds the ancestors of the
+// item, and is only valid during the call.
+type Visitor interface {
+	EnterDocument(*Document, Path) bool
+	LeaveDocument(*Document, Path)
+	EnterPackage(*Package, Path) bool
+	LeavePackage(*Package, Path)
+	EnterImport(*Import, Path) bool
+	LeaveImport(*Import, Path)
+	EnterService(*Service, Path) bool
+	LeaveService(*Service, Path)
+	EnterRPC(*RPC, Path) bool
+	LeaveRPC(*RPC, Path)
+	EnterMessage(Message, Path) bool
+	LeaveMessage(Message, Path)
+	EnterField(*Field, Path) bool
+	LeaveField(*Field, Path)
+	EnterMap(*Map, Path) bool
+	LeaveMap(*Map, Path)
+	EnterOneOf(*OneOf, Path) bool
+	LeaveOneOf(*OneOf, Path)
+	EnterOneOfField(*OneOfField, Path) bool
+	LeaveOneOfField(*OneOfField, Path)
+	EnterEnum(Enum, Path) bool
+	LeaveEnum(Enum, Path)
+	EnterVariant(*Variant, Path) bool
+	LeaveVariant(*Variant, Path)
+	EnterReservedNumber(*ReservedNumber, Path) bool
+	LeaveReservedNumber(*ReservedNumber, Path)
+	EnterReservedRange(*ReservedRange, Path) bool
+	LeaveReservedRange(*ReservedRange, Path)
+	EnterReservedLabel(*ReservedLabel, Path) bool
+	LeaveReservedLabel(*ReservedLabel, Path)
+}
+
+// EmptyVisitor enters all items and does nothing else. embed it to implement
+// only the hooks needed.
+type EmptyVisitor struct{}
+
+func (EmptyVisitor) EnterDocument(*Document, Path) bool { return true }
+func (EmptyVisitor) LeaveDocument(*Document, Path)      {}
+
+func (EmptyVisitor) EnterPackage(*Package, Path) bool { return true }
+func (EmptyVisitor) LeavePackage(*Package, Path)      {}
+
+func (EmptyVisitor) EnterImport(*Import, Path) bool { return true }
+func (EmptyVisitor) LeaveImport(*Import, Path)      {}
+
+func (EmptyVisitor) EnterService(*Service, Path) bool { return true }
+func (EmptyVisitor) LeaveService(*Service, Path)      {}
+
+func (EmptyVisitor) EnterRPC(*RPC, Path) bool { return true }
+func (EmptyVisitor) LeaveRPC(*RPC, Path)      {}
+
+func (EmptyVisitor) EnterMessage(Message, Path) bool { return true }
+func (EmptyVisitor) LeaveMessage(Message, Path)      {}
+
+func (EmptyVisitor) EnterField(*Field, Path) bool { return true }
+func (EmptyVisitor) LeaveField(*Field, Path)      {}
+
+func (EmptyVisitor) EnterMap(*Map, Path) bool { return true }
+func (EmptyVisitor) LeaveMap(*Map, Path)      {}
+
+func (EmptyVisitor) EnterOneOf(*OneOf, Path) bool { return true }
+func (EmptyVisitor) LeaveOneOf(*OneOf, Path)      {}
+
+func (EmptyVisitor) EnterOneOfField(*OneOfField, Path) bool { return true }
+func (EmptyVisitor) LeaveOneOfField(*OneOfField, Path)      {}
+
+func (EmptyVisitor) EnterEnum(Enum, Path) bool { return true }
+func (EmptyVisitor) LeaveEnum(Enum, Path)      {}
+
+func (EmptyVisitor) EnterVariant(*Variant, Path) bool { return true }
+func (EmptyVisitor) LeaveVariant(*Variant, Path)      {}
+
+func (EmptyVisitor) EnterReservedNumber(*ReservedNumber, Path) bool { return true }
+func (EmptyVisitor) LeaveReservedNumber(*ReservedNumber, Path)      {}
+
+func (EmptyVisitor) EnterReservedRange(*ReservedRange, Path) bool { return true }
+func (EmptyVisitor) LeaveReservedRange(*ReservedRange, Path)      {}
+
+func (EmptyVisitor) EnterReservedLabel(*ReservedLabel, Path) bool { return true }
+func (EmptyVisitor) LeaveReservedLabel(*ReservedLabel, Path)      {}
+
+// Path of ancestors of an item, from the document down to the parent
+type Path []interface{}
+
+// Parent of the item, or nil for the document
+func (p Path) Parent() interface{} {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[len(p)-1]
+}
+
+// Walk the items of a document depth first, in the order they are printed in
+func Walk(d *Document, v Visitor) {
+	w := walker{visitor: v}
+	w.document(d)
+}
+
+type walker struct {
+	visitor Visitor
+	path    Path
+}
+
+func (w *walker) push(item interface{}) {
+	w.path = append(w.path, item)
+}
+
+func (w *walker) pop() {
+	w.path = w.path[:len(w.path)-1]
+}
+
+func (w *walker) document(d *Document) {
+	if w.visitor.EnterDocument(d, w.path) {
+		w.push(d)
+		if d._package.label.value != "" {
+			w.visitor.EnterPackage(&d._package, w.path)
+			w.visitor.LeavePackage(&d._package, w.path)
+		}
+		for _, i := range d.imports {
+			w.visitor.EnterImport(i, w.path)
+			w.visitor.LeaveImport(i, w.path)
+		}
+		for _, s := range d.services {
+			w.service(s)
+		}
+		for _, e := range d.enums {
+			w.enum(e)
+		}
+		for _, m := range d.messages {
+			w.message(m)
+		}
+		w.pop()
+	}
+	w.visitor.LeaveDocument(d, w.path)
+}
+
+func (w *walker) service(s *Service) {
+	if w.visitor.EnterService(s, w.path) {
+		w.push(s)
+		for _, r := range s.rpcs {
+			w.visitor.EnterRPC(r, w.path)
+			w.visitor.LeaveRPC(r, w.path)
+		}
+		w.pop()
+	}
+	w.visitor.LeaveService(s, w.path)
+}
+
+func (w *walker) message(m Message) {
+	if w.visitor.EnterMessage(m, w.path) {
+		w.push(m)
+		for _, f := range m.Fields() {
+			w.messageField(f)
+		}
+		for _, e := range m.Enums() {
+			w.enum(e)
+		}
+		for _, n := range m.Messages() {
+			w.message(n)
+		}
+		w.pop()
+	}
+	w.visitor.LeaveMessage(m, w.path)
+}
+
+func (w *walker) messageField(f MessageField) {
+	switch f := f.(type) {
+	case *Field:
+		w.visitor.EnterField(f, w.path)
+		w.visitor.LeaveField(f, w.path)
+	case *Map:
+		w.visitor.EnterMap(f, w.path)
+		w.visitor.LeaveMap(f, w.path)
+	case *OneOf:
+		w.oneOf(f)
+	default:
+		w.reserved(f)
+	}
+}
+
+func (w *walker) oneOf(o *OneOf) {
+	if w.visitor.EnterOneOf(o, w.path) {
+		w.push(o)
+		for _, f := range o.fields {
+			w.visitor.EnterOneOfField(f, w.path)
+			w.visitor.LeaveOneOfField(f, w.path)
+		}
+		w.pop()
+	}
+	w.visitor.LeaveOneOf(o, w.path)
+}
+
+func (w *walker) enum(e Enum) {
+	if w.visitor.EnterEnum(e, w.path) {
+		w.push(e)
+		for _, f := range e.Fields() {
+			if v, ok := f.(*Variant); ok {
+				w.visitor.EnterVariant(v, w.path)
+				w.visitor.LeaveVariant(v, w.path)
+				continue
+			}
+			w.reserved(f)
+		}
+		w.pop()
+	}
+	w.visitor.LeaveEnum(e, w.path)
+}
+
+// reserved field of a message or an enum
+func (w *walker) reserved(r interface{}) {
+	switch r := r.(type) {
+	case *ReservedNumber:
+		w.visitor.EnterReservedNumber(r, w.path)
+		w.visitor.LeaveReservedNumber(r, w.path)
+	case *ReservedRange:
+		w.visitor.EnterReservedRange(r, w.path)
+		w.visitor.LeaveReservedRange(r, w.path)
+	case *ReservedLabel:
+		w.visitor.EnterReservedLabel(r, w.path)
+		w.visitor.LeaveReservedLabel(r, w.path)
+	default:
+		panic(fmt.Sprintf("unhandled field type %T", r))
+	}
+}
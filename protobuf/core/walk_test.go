@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outline of messages and their fields, skipping messages labeled `Skip`
+type outline struct {
+	EmptyVisitor
+	lines []string
+}
+
+func (o *outline) EnterMessage(m Message, path Path) bool {
+	o.lines = append(o.lines, fmt.Sprintf("%d enter %s", len(path), m.Label()))
+	return m.Label().Get() != "Skip"
+}
+
+func (o *outline) LeaveMessage(m Message, path Path) {
+	o.lines = append(o.lines, fmt.Sprintf("%d leave %s", len(path), m.Label()))
+}
+
+func (o *outline) EnterField(f *Field, path Path) bool {
+	parent := path.Parent().(Message)
+	o.lines = append(o.lines, fmt.Sprintf("%d field %s.%s", len(path), parent.Label(), f.Label()))
+	return true
+}
+
+func (o *outline) EnterOneOfField(f *OneOfField, path Path) bool {
+	o.lines = append(o.lines, fmt.Sprintf("%d oneof field %s", len(path), f.Label()))
+	return true
+}
+
+func (o *outline) EnterVariant(v *Variant, path Path) bool {
+	o.lines = append(o.lines, fmt.Sprintf("%d variant %s", len(path), v.Label()))
+	return true
+}
+
+func (o *outline) EnterReservedNumber(r *ReservedNumber, path Path) bool {
+	o.lines = append(o.lines, fmt.Sprintf("%d reserved %s", len(path), r))
+	return true
+}
+
+func TestWalk(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+message A {
+  string a = 1;
+  oneof o {
+    int32 b = 2;
+  }
+  reserved 3;
+
+  enum E {
+    NONE = 0;
+  }
+
+  message B {
+    bool c = 1;
+  }
+}
+
+message Skip {
+  bool d = 1;
+}`))
+	require.Nil(t, err)
+	o := &outline{}
+	Walk(d, o)
+	assert.Equal(t, []string{
+		"1 enter A",
+		"2 field A.a",
+		"3 oneof field b",
+		"2 reserved reserved 3;",
+		"3 variant NONE",
+		"2 enter B",
+		"3 field B.c",
+		"2 leave B",
+		"1 leave A",
+		"1 enter Skip",
+		"1 leave Skip",
+	}, o.lines)
+}
+
+// ancestors checks that the path of each item leads to its parent
+type ancestors struct {
+	EmptyVisitor
+	t     *testing.T
+	items int
+}
+
+func (a *ancestors) EnterDocument(d *Document, path Path) bool {
+	assert.Nil(a.t, path.Parent())
+	return true
+}
+
+func (a *ancestors) EnterRPC(r *RPC, path Path) bool {
+	a.items++
+	assert.Equal(a.t, r.Parent(), path.Parent())
+	assert.Equal(a.t, r.Document(), path[0])
+	return true
+}
+
+func (a *ancestors) EnterOneOfField(f *OneOfField, path Path) bool {
+	a.items++
+	assert.Equal(a.t, f.Parent(), path.Parent())
+	assert.Equal(a.t, f.Parent().Parent(), path[len(path)-2])
+	return true
+}
+
+func (a *ancestors) EnterVariant(v *Variant, path Path) bool {
+	a.items++
+	assert.Equal(a.t, v.Parent(), path.Parent())
+	return true
+}
+
+func TestWalkPath(t *testing.T) {
+	d, err := Parse(strings.NewReader(`syntax = "proto3";
+
+service S {
+  rpc Get (A) returns (A);
+}
+
+message A {
+  message B {
+    oneof o {
+      int32 b = 1;
+    }
+
+    enum E {
+      NONE = 0;
+    }
+  }
+}`))
+	require.Nil(t, err)
+	a := &ancestors{t: t}
+	Walk(d, a)
+	assert.Equal(t, 3, a.items)
+}
@@ -0,0 +1,368 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Workspace holds a collection of documents keyed by path, which can import
+// each other. definitions from other documents are only visible if their
+// document is imported, either directly or through a chain of public imports.
+type Workspace struct {
+	documents map[string]*Document
+}
+
+func NewWorkspace() *Workspace {
+	return &Workspace{documents: make(map[string]*Document)}
+}
+
+// NewDocument creates an empty document in the workspace.
+func (w *Workspace) NewDocument(path string) (*Document, error) {
+	d, err := w.newDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	w.documents[path] = d
+	return d, nil
+}
+
+func (w *Workspace) newDocument(path string) (*Document, error) {
+	if reason := checkPath(path); reason != "" {
+		return nil, &InvalidPathError{Value: path, Reason: reason}
+	}
+	if _, ok := w.documents[path]; ok {
+		return nil, fmt.Errorf("document %s already exists", path)
+	}
+	d := NewDocument()
+	d.workspace = w
+	d.path = path
+	return d, nil
+}
+
+// Parse a document in proto3 syntax into the workspace. all files it imports
+// must already be present.
+func (w *Workspace) Parse(path string, r io.Reader) (*Document, error) {
+	d, err := w.newDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	// only add the document if parsing succeeds, such that it cannot be
+	// referenced in an incomplete state
+	if err := parse(d, r); err != nil {
+		return nil, err
+	}
+	w.documents[path] = d
+	return d, nil
+}
+
+// Load a document in proto3 syntax into the workspace, together with all
+// documents it imports which are not present yet. `open` is called with the
+// path of each document to be read. errors are prefixed with the path of the
+// document they occur in.
+func (w *Workspace) Load(path string, open func(path string) (io.ReadCloser, error)) (*Document, error) {
+	return w.load(path, open, make(map[string]bool))
+}
+
+func (w *Workspace) load(path string, open func(string) (io.ReadCloser, error), loading map[string]bool) (*Document, error) {
+	if d, ok := w.documents[path]; ok {
+		return d, nil
+	}
+	if loading[path] {
+		return nil, fmt.Errorf("%s: import cycle", path)
+	}
+	loading[path] = true
+	d, err := w.newDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := parseFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	for _, i := range f.imports {
+		if _, err := w.load(i.path.text, open, loading); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.build(d); err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	w.documents[path] = d
+	return d, nil
+}
+
+// RemoveDocument from the workspace. documents imported by others cannot be
+// removed.
+func (w *Workspace) RemoveDocument(path string) error {
+	d, ok := w.documents[path]
+	if !ok {
+		return fmt.Errorf("document %s not found", path)
+	}
+	if dependents := d.dependents(); len(dependents) > 0 {
+		return fmt.Errorf("document %s is imported by %s", path, dependents[0].path)
+	}
+	delete(w.documents, path)
+	d.workspace = nil
+	return nil
+}
+
+// Document at a path, or nil if there is none.
+func (w *Workspace) Document(path string) *Document {
+	return w.documents[path]
+}
+
+// Documents in the workspace, ordered by path.
+func (w *Workspace) Documents() []*Document {
+	paths := make([]string, 0, len(w.documents))
+	for p := range w.documents {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out := make([]*Document, len(paths))
+	for i, p := range paths {
+		out[i] = w.documents[p]
+	}
+	return out
+}
+
+// importedDocument an import refers to, or nil if the document is not part of
+// a workspace or the import path is not set.
+func (i *Import) importedDocument() *Document {
+	if i.parent.workspace == nil {
+		return nil
+	}
+	return i.parent.workspace.documents[i.path.value]
+}
+
+// dependencies of a document: itself and all documents it imports, directly
+// or indirectly.
+func (d *Document) dependencies() []*Document {
+	seen := make(map[*Document]bool)
+	var out []*Document
+	var visit func(*Document)
+	visit = func(d *Document) {
+		if seen[d] {
+			return
+		}
+		seen[d] = true
+		out = append(out, d)
+		for _, i := range d.imports {
+			if imported := i.importedDocument(); imported != nil {
+				visit(imported)
+			}
+		}
+	}
+	visit(d)
+	return out
+}
+
+// dependents of a document: all documents in the workspace which import it,
+// directly or indirectly.
+func (d *Document) dependents() []*Document {
+	if d.workspace == nil {
+		return nil
+	}
+	var out []*Document
+	for _, other := range d.workspace.Documents() {
+		if other == d {
+			continue
+		}
+		for _, dep := range other.dependencies() {
+			if dep == d {
+				out = append(out, other)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// visibleDocuments whose definitions can be referenced from a document:
+// itself, documents it imports, and whatever those import publicly.
+func (d *Document) visibleDocuments() map[*Document]bool {
+	out := map[*Document]bool{d: true}
+	var export func(*Document)
+	export = func(d *Document) {
+		if out[d] {
+			return
+		}
+		out[d] = true
+		for _, i := range d.imports {
+			if i.public.value {
+				if imported := i.importedDocument(); imported != nil {
+					export(imported)
+				}
+			}
+		}
+	}
+	for _, i := range d.imports {
+		if imported := i.importedDocument(); imported != nil {
+			export(imported)
+		}
+	}
+	return out
+}
+
+// validateReference to a definition, which must be visible from the document
+func (d *Document) validateReference(value ValueType) error {
+	var label string
+	var other *Document
+	switch v := value.(type) {
+	case Message:
+		label = v.Label().Get()
+		other = v.Document()
+	case Enum:
+		label = v.Label().Get()
+		other = v.Document()
+	default:
+		return nil
+	}
+	if !isInserted(value) {
+		return fmt.Errorf("%s is not part of a document", label)
+	}
+	if d.visibleDocuments()[other] {
+		return nil
+	}
+	if other.workspace == nil || other.workspace != d.workspace {
+		return fmt.Errorf("%s is defined in another workspace", label)
+	}
+	return fmt.Errorf("%s is defined in %s, which is not imported", label, other.path)
+}
+
+// validateReferences of all fields and RPCs in a document
+func (d *Document) validateReferences() error {
+	for _, s := range d.services {
+		for _, r := range s.rpcs {
+			if err := r.request.validate(); err != nil {
+				return err
+			}
+			if err := r.response.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range d.messages {
+		if err := m.validateReferences(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *message) validateReferences() error {
+	for _, f := range m.fields {
+		var err error
+		switch v := f.(type) {
+		case *Field:
+			err = v._type.validate()
+		case *Map:
+			err = v._type.validate()
+		case *OneOf:
+			for _, o := range v.fields {
+				if err = o._type.validate(); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, n := range m.messages {
+		if err := n.validateReferences(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSet of a document: all documents `protoc` would have to load together
+// with it, which are its dependencies and those of every document depending
+// on it.
+func (d *Document) loadSet() map[*Document]bool {
+	out := make(map[*Document]bool)
+	for _, dep := range d.dependencies() {
+		out[dep] = true
+	}
+	for _, other := range d.dependents() {
+		for _, dep := range other.dependencies() {
+			out[dep] = true
+		}
+	}
+	return out
+}
+
+// definedIn returns the label of a fully qualified top-level name declared in
+// a set of documents, other than the given one. only top-level names are
+// considered, since nested names are qualified by them.
+func (d *Document) definedIn(documents map[*Document]bool, name string) *Label {
+	for other := range documents {
+		if other == d {
+			continue
+		}
+		if l, ok := other.topLevelNames()[name]; ok {
+			return l
+		}
+	}
+	return nil
+}
+
+// validateDefinitions checks that no top-level definition of a document is
+// declared again in another document loaded together with it.
+func (d *Document) validateDefinitions() error {
+	loaded := d.loadSet()
+	for name, l := range d.topLevelNames() {
+		if existing := d.definedIn(loaded, name); existing != nil {
+			return &DuplicateLabelError{existing, l, l.value}
+		}
+	}
+	return nil
+}
+
+// validateImports checks that after changing the imports of a document,
+// neither it nor any document depending on it loads conflicting definitions
+// or references definitions which are not visible any more.
+func (d *Document) validateImports() error {
+	roots := append([]*Document{d}, d.dependents()...)
+	for _, r := range roots {
+		declared := make(map[string]*Label)
+		for _, dep := range r.dependencies() {
+			for name, l := range dep.topLevelNames() {
+				if existing, ok := declared[name]; ok {
+					return &DuplicateLabelError{existing, l, l.value}
+				}
+				declared[name] = l
+			}
+		}
+		if err := r.validateReferences(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Document) topLevelNames() map[string]*Label {
+	pkg := d._package.label.value
+	out := make(map[string]*Label)
+	for _, s := range d.services {
+		out[qualify(pkg, s.label.value)] = &s.label
+	}
+	for _, m := range d.messages {
+		out[qualify(pkg, m.label.value)] = &m.label
+	}
+	for _, e := range d.enums {
+		out[qualify(pkg, e.label.value)] = &e.label
+		for _, f := range e.fields {
+			if v, ok := f.(*Variant); ok {
+				out[qualify(pkg, v.label.value)] = &v.label
+			}
+		}
+	}
+	return out
+}
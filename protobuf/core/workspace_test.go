@@ -0,0 +1,120 @@
+package core
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseInto(t *testing.T, w *Workspace, path, input string) *Document {
+	d, err := w.Parse(path, strings.NewReader(input))
+	require.Nil(t, err)
+	return d
+}
+
+func TestWorkspaceImports(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3"; package a; message A {}`)
+	b := parseInto(t, w, "b.proto", `syntax = "proto3"; package b; import public "a.proto"; message B { a.A a = 1; }`)
+	c := parseInto(t, w, "c.proto", `syntax = "proto3"; import "b.proto"; message C { a.A a = 1; b.B b = 2; }`)
+	assert.Equal(t, []*Document{a, b, c}, w.Documents())
+	assert.Equal(t, "c.proto", c.Path())
+	assert.Equal(t, w, c.Workspace())
+
+	_, err := w.Parse("d.proto", strings.NewReader(`syntax = "proto3"; import "missing.proto";`))
+	assert.NotNil(t, err)
+	assert.Nil(t, w.Document("d.proto"), "failed documents are not added")
+
+	i := c.NewImport()
+	assert.NotNil(t, i.Path().Set("c.proto"), "documents cannot import themselves")
+	assert.NotNil(t, i.Path().Set("b.proto"), "duplicate import")
+	assert.Nil(t, i.Path().Set("a.proto"))
+	require.Nil(t, i.InsertIntoParent())
+
+	i = a.NewImport()
+	assert.NotNil(t, i.Path().Set("c.proto"), "import cycle")
+}
+
+func TestWorkspaceVisibility(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3"; package a; message A {}`)
+	parseInto(t, w, "b.proto", `syntax = "proto3"; import "a.proto";`)
+	_, err := w.Parse("c.proto", strings.NewReader(`syntax = "proto3"; import "b.proto"; message C { a.A a = 1; }`))
+	assert.NotNil(t, err, "only public imports are re-exported")
+
+	c := parseInto(t, w, "c.proto", `syntax = "proto3"; import "b.proto"; message C {}`)
+	f := c.Messages()[0].NewField()
+	assert.NotNil(t, f.Type().Set(a.Messages()[0]))
+
+	other := NewDocument()
+	m := other.NewMessage()
+	require.Nil(t, m.Label().Set("Other"))
+	require.Nil(t, m.InsertIntoParent())
+	assert.NotNil(t, f.Type().Set(other.Messages()[0]), "standalone documents are not visible")
+}
+
+func TestWorkspaceImportsKeepReferences(t *testing.T) {
+	w := NewWorkspace()
+	parseInto(t, w, "a.proto", `syntax = "proto3"; package a; message A {}`)
+	parseInto(t, w, "x.proto", `syntax = "proto3"; package x;`)
+	b := parseInto(t, w, "b.proto", `syntax = "proto3"; import public "a.proto";`)
+	parseInto(t, w, "c.proto", `syntax = "proto3"; import "b.proto"; message C { a.A a = 1; }`)
+
+	i := b.Imports()[0]
+	assert.NotNil(t, i.Public().Set(false), "dependents reference definitions exported by the import")
+	assert.NotNil(t, i.Path().Set("x.proto"), "dependents reference definitions exported by the import")
+	assert.Equal(t, "a.proto", i.Path().Get())
+	assert.NotNil(t, i.Remove(), "dependents reference definitions exported by the import")
+
+	c := w.Document("c.proto")
+	require.Nil(t, c.Messages()[0].Fields()[0].(*Field).Remove())
+	assert.Nil(t, i.Remove())
+	assert.Empty(t, b.Imports())
+}
+
+func TestWorkspaceDefinitions(t *testing.T) {
+	w := NewWorkspace()
+	a := parseInto(t, w, "a.proto", `syntax = "proto3"; package p; message A {}`)
+	_, err := w.Parse("b.proto", strings.NewReader(`syntax = "proto3"; package p; import "a.proto"; message A {}`))
+	assert.NotNil(t, err)
+
+	b := parseInto(t, w, "b.proto", `syntax = "proto3"; package q; message A {}`)
+	i := b.NewImport()
+	require.Nil(t, i.Path().Set("a.proto"))
+	require.Nil(t, i.InsertIntoParent())
+	assert.NotNil(t, b.Package().Set("p"))
+	assert.NotNil(t, a.Package().Set("q"))
+
+	assert.NotNil(t, w.RemoveDocument("a.proto"), "a.proto is imported")
+	assert.Nil(t, w.RemoveDocument("b.proto"))
+	assert.Nil(t, w.Document("b.proto"))
+}
+
+func TestWorkspaceLoad(t *testing.T) {
+	files := map[string]string{
+		"a.proto": `syntax = "proto3"; package a; message A {}`,
+		"b.proto": `syntax = "proto3"; import "a.proto"; message B { a.A a = 1; }`,
+		"c.proto": `syntax = "proto3"; import "d.proto";`,
+		"d.proto": `syntax = "proto3"; import "c.proto";`,
+	}
+	open := func(path string) (io.ReadCloser, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(strings.NewReader(content)), nil
+	}
+	w := NewWorkspace()
+	_, err := w.Load("b.proto", open)
+	require.Nil(t, err)
+	assert.NotNil(t, w.Document("a.proto"))
+
+	_, err = w.Load("c.proto", open)
+	assert.NotNil(t, err, "import cycle")
+	assert.Nil(t, w.Document("c.proto"))
+}
@@ -142,7 +142,7 @@ func TestEnumAddField(t *testing.T) {
 	f := e.NewVariant()
 	err = f.Label().Set("enumValue")
 	require.Nil(t, err)
-	err = f.Number().Set(1)
+	err = f.Number().Set(0)
 	require.Nil(t, err)
 
 	err = f.InsertIntoParent()
@@ -176,10 +176,10 @@ func TestVariantSetInvalidProperties(t *testing.T) {
 	f1 := e.NewVariant()
 	err = f1.Label().Set("enumValue1")
 	require.Nil(t, err)
-	err = f1.Number().Set(1)
+	err = f1.Number().Set(0)
 	require.Nil(t, err)
 	require.NotNil(t, f1.Number().Get())
-	require.EqualValues(t, 1, *f1.Number().Get())
+	require.EqualValues(t, 0, *f1.Number().Get())
 
 	// do not forget to add the first field to the enum!
 	err = f1.InsertIntoParent()
@@ -193,7 +193,7 @@ func TestVariantSetInvalidProperties(t *testing.T) {
 	require.Nil(t, err)
 
 	//duplicate field number
-	err = f2.Number().Set(1)
+	err = f2.Number().Set(0)
 	require.NotNil(t, err)
 	err = f2.Number().Set(2)
 	require.Nil(t, err)
@@ -203,7 +203,7 @@ func TestVariantSetInvalidProperties(t *testing.T) {
 	require.Nil(t, err)
 
 	// try to disable aliasing with duplicate field numbers
-	err = f2.Number().Set(1)
+	err = f2.Number().Set(0)
 	require.Nil(t, err)
 	err = f2.InsertIntoParent()
 	require.Nil(t, err)
@@ -384,10 +384,12 @@ func TestEnumValidateReservedNumber(t *testing.T) {
 
 	err = r.InsertIntoParent()
 	require.NotNil(t, err)
-	// enum may have 0 as field number
+	// enum must not reserve 0, but may reserve negative numbers
 	err = r.Set(0)
+	require.NotNil(t, err)
+	err = r.Set(-1)
 	require.Nil(t, err)
-	require.EqualValues(t, 0, *r.Get())
+	require.EqualValues(t, -1, *r.Get())
 	err = r.InsertIntoParent()
 	require.Nil(t, err)
 
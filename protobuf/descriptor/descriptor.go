@@ -0,0 +1,88 @@
+// Package descriptor converts between documents and the `descriptor.proto`
+// representation used by `protoc` and its plugins.
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+const syntax = "proto3"
+
+var scalarTypes = map[core.ValueType]descriptorpb.FieldDescriptorProto_Type{
+	core.Double:   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	core.Float:    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	core.Int32:    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	core.Int64:    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	core.Uint32:   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	core.Uint64:   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	core.Sint32:   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	core.Sint64:   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	core.Fixed32:  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	core.Fixed64:  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	core.Sfixed32: descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	core.Sfixed64: descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	core.Bool:     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	core.String:   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	core.Bytes:    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// fullName of a message or enum, without leading dot
+func fullName(v core.ValueType) string {
+	var label string
+	var parent core.DefinitionContainer
+	switch t := v.(type) {
+	case core.Message:
+		label = t.Label().Get()
+		parent = t.Parent()
+	case core.Enum:
+		label = t.Label().Get()
+		parent = t.Parent()
+	default:
+		panic(fmt.Sprintf("unhandled value type %T", t))
+	}
+	return qualify(scope(parent), label)
+}
+
+// scope of the children of a definition container
+func scope(c core.DefinitionContainer) string {
+	switch v := c.(type) {
+	case *core.Document:
+		return v.Package().Get()
+	case core.Message:
+		return fullName(v)
+	default:
+		panic(fmt.Sprintf("unhandled definition container type %T", v))
+	}
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// mapEntryName is the name of the message `protoc` synthesizes for a map field
+func mapEntryName(label string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range label {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("Entry")
+	return b.String()
+}
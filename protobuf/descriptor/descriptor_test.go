@@ -0,0 +1,271 @@
+package descriptor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+const input = `syntax = "proto3";
+
+package foo.bar;
+
+import public "other.proto";
+
+service Search {
+  rpc Find (Request) returns (stream Outer.Inner);
+}
+
+message Request {
+  repeated Outer.Inner results = 1 [deprecated = true];
+  map<string, Kind> some_kinds = 2;
+  oneof choice {
+    Kind kind = 3;
+  }
+  reserved 5, 8 to 10;
+  reserved "old";
+}
+
+message Outer {
+  message Inner {}
+}
+
+enum Kind {
+  option allow_alias = true;
+  UNKNOWN = 0;
+  DEFAULT = 0;
+  SENTINEL = -1;
+  reserved 4 to 6, -5 to -3;
+}
+`
+
+func field(m *descriptorpb.DescriptorProto, name string) *descriptorpb.FieldDescriptorProto {
+	for _, f := range m.Field {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func message(messages []*descriptorpb.DescriptorProto, name string) *descriptorpb.DescriptorProto {
+	for _, m := range messages {
+		if m.GetName() == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestToFileDescriptorProto(t *testing.T) {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	f, err := ToFileDescriptorProto(d)
+	require.Nil(t, err)
+
+	assert.Equal(t, "foo.bar", f.GetPackage())
+	assert.Equal(t, "proto3", f.GetSyntax())
+	assert.Equal(t, []string{"other.proto"}, f.Dependency)
+	assert.Equal(t, []int32{0}, f.PublicDependency)
+
+	request := message(f.MessageType, "Request")
+	require.NotNil(t, request)
+	results := field(request, "results")
+	require.NotNil(t, results)
+	assert.Equal(t, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, results.GetLabel())
+	assert.Equal(t, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, results.GetType())
+	assert.Equal(t, ".foo.bar.Outer.Inner", results.GetTypeName())
+	assert.True(t, results.GetOptions().GetDeprecated())
+
+	kinds := field(request, "some_kinds")
+	require.NotNil(t, kinds)
+	assert.Equal(t, "someKinds", kinds.GetJsonName())
+	assert.Equal(t, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, kinds.GetLabel())
+	assert.Equal(t, ".foo.bar.Request.SomeKindsEntry", kinds.GetTypeName())
+	entry := message(request.NestedType, "SomeKindsEntry")
+	require.NotNil(t, entry)
+	assert.True(t, entry.GetOptions().GetMapEntry())
+	assert.Equal(t, descriptorpb.FieldDescriptorProto_TYPE_STRING, field(entry, "key").GetType())
+	assert.Equal(t, ".foo.bar.Kind", field(entry, "value").GetTypeName())
+
+	require.Len(t, request.OneofDecl, 1)
+	assert.Equal(t, "choice", request.OneofDecl[0].GetName())
+	assert.EqualValues(t, 0, field(request, "kind").GetOneofIndex())
+
+	assert.Len(t, request.ReservedRange, 2)
+	for _, r := range request.ReservedRange {
+		switch r.GetStart() {
+		case 5:
+			assert.EqualValues(t, 6, r.GetEnd())
+		case 8:
+			assert.EqualValues(t, 11, r.GetEnd())
+		default:
+			t.Errorf("unexpected reserved range %v", r)
+		}
+	}
+	assert.Equal(t, []string{"old"}, request.ReservedName)
+
+	require.Len(t, f.EnumType, 1)
+	kind := f.EnumType[0]
+	assert.True(t, kind.GetOptions().GetAllowAlias())
+	assert.Len(t, kind.Value, 3)
+	assert.EqualValues(t, -1, kind.Value[2].GetNumber())
+	require.Len(t, kind.ReservedRange, 2)
+	assert.EqualValues(t, 4, kind.ReservedRange[0].GetStart())
+	assert.EqualValues(t, 6, kind.ReservedRange[0].GetEnd())
+	assert.EqualValues(t, -5, kind.ReservedRange[1].GetStart())
+	assert.EqualValues(t, -3, kind.ReservedRange[1].GetEnd())
+
+	require.Len(t, f.Service, 1)
+	require.Len(t, f.Service[0].Method, 1)
+	method := f.Service[0].Method[0]
+	assert.Equal(t, ".foo.bar.Request", method.GetInputType())
+	assert.Equal(t, ".foo.bar.Outer.Inner", method.GetOutputType())
+	assert.False(t, method.GetClientStreaming())
+	assert.True(t, method.GetServerStreaming())
+}
+
+func TestFromFileDescriptorProto(t *testing.T) {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	f, err := ToFileDescriptorProto(d)
+	require.Nil(t, err)
+
+	converted, err := FromFileDescriptorProto(f)
+	require.Nil(t, err)
+	g, err := ToFileDescriptorProto(converted)
+	require.Nil(t, err)
+
+	assert.Equal(t, f.GetPackage(), g.GetPackage())
+	assert.Equal(t, f.Dependency, g.Dependency)
+	request := message(g.MessageType, "Request")
+	require.NotNil(t, request)
+	for _, expected := range message(f.MessageType, "Request").Field {
+		assert.True(t, proto.Equal(expected, field(request, expected.GetName())), expected.GetName())
+	}
+	assert.True(t, proto.Equal(message(f.MessageType, "Outer"), message(g.MessageType, "Outer")))
+	assert.True(t, proto.Equal(f.Service[0], g.Service[0]))
+	assert.ElementsMatch(t, f.EnumType[0].Value, g.EnumType[0].Value)
+	assert.ElementsMatch(t, f.EnumType[0].ReservedRange, g.EnumType[0].ReservedRange)
+}
+
+func TestFromFileDescriptorProtoUnsupported(t *testing.T) {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+
+	f, err := ToFileDescriptorProto(d)
+	require.Nil(t, err)
+	f.Name = proto.String("foo.proto")
+	f.Syntax = proto.String("proto2")
+	_, err = FromFileDescriptorProto(f)
+	assert.NotNil(t, err)
+
+	f, err = ToFileDescriptorProto(d)
+	require.Nil(t, err)
+	f.Options = &descriptorpb.FileOptions{JavaGenerateEqualsAndHash: proto.Bool(true)}
+	_, err = FromFileDescriptorProto(f)
+	assert.NotNil(t, err)
+
+	f, err = ToFileDescriptorProto(d)
+	require.Nil(t, err)
+	field(message(f.MessageType, "Request"), "results").TypeName = proto.String(".foo.bar.Missing")
+	_, err = FromFileDescriptorProto(f)
+	assert.NotNil(t, err)
+}
+
+func TestOptions(t *testing.T) {
+	d, err := core.Parse(strings.NewReader(`syntax = "proto3";
+option go_package = "example.com/foo";
+option optimize_for = CODE_SIZE;
+service S {
+  option deprecated = true;
+  rpc Get (M) returns (M) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+}
+message M {
+  option deprecated = true;
+  repeated int64 ids = 1 [packed=false, jstype=JS_STRING, json_name="IDs"];
+}
+enum E {
+  option deprecated = true;
+  A = 0 [deprecated=true];
+}
+`))
+	require.Nil(t, err)
+	f, err := ToFileDescriptorProto(d)
+	require.Nil(t, err)
+
+	assert.Equal(t, "example.com/foo", f.GetOptions().GetGoPackage())
+	assert.Equal(t, descriptorpb.FileOptions_CODE_SIZE, f.GetOptions().GetOptimizeFor())
+	assert.True(t, f.Service[0].GetOptions().GetDeprecated())
+	assert.Equal(t, descriptorpb.MethodOptions_NO_SIDE_EFFECTS, f.Service[0].Method[0].GetOptions().GetIdempotencyLevel())
+	assert.True(t, f.MessageType[0].GetOptions().GetDeprecated())
+	ids := f.MessageType[0].Field[0]
+	assert.Equal(t, "IDs", ids.GetJsonName())
+	require.NotNil(t, ids.GetOptions().Packed)
+	assert.False(t, ids.GetOptions().GetPacked())
+	assert.Equal(t, descriptorpb.FieldOptions_JS_STRING, ids.GetOptions().GetJstype())
+	assert.True(t, f.EnumType[0].GetOptions().GetDeprecated())
+	assert.Nil(t, f.EnumType[0].GetOptions().AllowAlias)
+	assert.True(t, f.EnumType[0].Value[0].GetOptions().GetDeprecated())
+
+	converted, err := FromFileDescriptorProto(f)
+	require.Nil(t, err)
+	assert.Equal(t, d.String(), converted.String())
+
+	f.MessageType[0].Field[0].Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	f.MessageType[0].Field[0].Options.Packed = proto.Bool(true)
+	_, err = FromFileDescriptorProto(f)
+	assert.NotNil(t, err, "packed requires a repeated field")
+}
+
+func TestReadFileDescriptorSet(t *testing.T) {
+	a, err := core.Parse(strings.NewReader(`syntax = "proto3"; package a; message A {}`))
+	require.Nil(t, err)
+	fa, err := ToFileDescriptorProto(a)
+	require.Nil(t, err)
+	fa.Name = proto.String("a.proto")
+
+	// reference a type from another file
+	b := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("b.proto"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"a.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("B"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("a"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".a.A"),
+			}},
+		}},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{fa, b},
+	})
+	require.Nil(t, err)
+
+	w, err := ReadFileDescriptorSet(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Len(t, w.Documents(), 2)
+	mb := w.Document("b.proto").Messages()[0]
+	ma := w.Document("a.proto").Messages()[0]
+	assert.Equal(t, ma, mb.Fields()[0].(*core.Field).Type().Get())
+
+	// referenced types must be imported
+	b.Dependency = nil
+	_, err = FromFileDescriptorSet(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{fa, b},
+	})
+	assert.NotNil(t, err)
+}
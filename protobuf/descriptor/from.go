@@ -0,0 +1,543 @@
+package descriptor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+// FromFileDescriptorProto converts a single file descriptor to a standalone
+// document. all type references must resolve within that file.
+func FromFileDescriptorProto(f *descriptorpb.FileDescriptorProto) (*core.Document, error) {
+	return NewConverter(nil).Convert(f)
+}
+
+// FromFileDescriptorSet converts all files in a set to documents in a new
+// workspace, keyed by file name. dependencies must precede the files
+// importing them, which is the order `protoc --include_imports
+// --descriptor_set_out` produces.
+func FromFileDescriptorSet(s *descriptorpb.FileDescriptorSet) (*core.Workspace, error) {
+	w := core.NewWorkspace()
+	c := NewConverter(w)
+	for _, f := range s.File {
+		if _, err := c.Convert(f); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// ReadFileDescriptorSet reads a serialized `FileDescriptorSet`, as written by
+// `protoc --descriptor_set_out`, and converts it to documents in a new
+// workspace.
+func ReadFileDescriptorSet(r io.Reader) (*core.Workspace, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return FromFileDescriptorSet(s)
+}
+
+// Converter turns file descriptors into documents one by one. it keeps track
+// of all definitions converted so far, such that type references across files
+// can be resolved.
+type Converter struct {
+	// workspace to add documents to, if any
+	workspace *core.Workspace
+	// types by fully qualified name without leading dot
+	types map[string]core.ValueType
+	// synthesized map entry messages by fully qualified name
+	mapEntries map[string]*descriptorpb.DescriptorProto
+}
+
+// NewConverter adds documents to a workspace, keyed by file name. without a
+// workspace, it produces standalone documents, which cannot reference each
+// other.
+func NewConverter(w *core.Workspace) *Converter {
+	return &Converter{
+		workspace:  w,
+		types:      make(map[string]core.ValueType),
+		mapEntries: make(map[string]*descriptorpb.DescriptorProto),
+	}
+}
+
+// Convert a file descriptor. its dependencies must have been converted before.
+func (c *Converter) Convert(f *descriptorpb.FileDescriptorProto) (*core.Document, error) {
+	d, err := c.newDocument(f.GetName())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.GetName(), err)
+	}
+	if err := c.convertFile(d, f); err != nil {
+		// do not leave incomplete documents around
+		if c.workspace != nil {
+			_ = c.workspace.RemoveDocument(f.GetName())
+		}
+		return nil, fmt.Errorf("%s: %w", f.GetName(), err)
+	}
+	d.ClearHistory()
+	return d, nil
+}
+
+func (c *Converter) newDocument(name string) (*core.Document, error) {
+	if c.workspace == nil {
+		return core.NewDocument(), nil
+	}
+	return c.workspace.NewDocument(name)
+}
+
+func (c *Converter) convertFile(d *core.Document, f *descriptorpb.FileDescriptorProto) error {
+	if f.GetSyntax() != syntax {
+		return fmt.Errorf("unsupported syntax %q, only %s is supported", f.GetSyntax(), syntax)
+	}
+	if err := fromOptions(f.Options, map[string]*core.Flag{"deprecated": d.Deprecated()}, d.Options().List()); err != nil {
+		return err
+	}
+	if len(f.Extension) > 0 {
+		return fmt.Errorf("extensions are not supported")
+	}
+	if len(f.WeakDependency) > 0 {
+		return fmt.Errorf("weak imports are not supported")
+	}
+	if f.Package != nil {
+		if err := d.Package().Set(f.GetPackage()); err != nil {
+			return fmt.Errorf("package %s: %w", f.GetPackage(), err)
+		}
+	}
+	public := make(map[int32]bool, len(f.PublicDependency))
+	for _, i := range f.PublicDependency {
+		public[i] = true
+	}
+	for i, path := range f.Dependency {
+		imp := d.NewImport()
+		if err := imp.Path().Set(path); err != nil {
+			return fmt.Errorf("import %q: %w", path, err)
+		}
+		if err := imp.Public().Set(public[int32(i)]); err != nil {
+			return fmt.Errorf("import %q: %w", path, err)
+		}
+		if err := imp.InsertIntoParent(); err != nil {
+			return fmt.Errorf("import %q: %w", path, err)
+		}
+	}
+
+	// declare all definitions first, such that type references can be resolved
+	// regardless of declaration order
+	scope := d.Package().Get()
+	if err := c.declare(d, scope, f.MessageType, f.EnumType); err != nil {
+		return err
+	}
+	for _, s := range f.Service {
+		if err := c.service(d, s); err != nil {
+			return fmt.Errorf("%s: %w", qualify(scope, s.GetName()), err)
+		}
+	}
+	for _, m := range f.MessageType {
+		if err := c.message(scope, m); err != nil {
+			return err
+		}
+	}
+	for _, e := range f.EnumType {
+		name := qualify(scope, e.GetName())
+		if err := c.enum(c.types[name].(core.Enum), e); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Converter) declare(parent core.DefinitionContainer, scope string, messages []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto) error {
+	for _, m := range messages {
+		name := qualify(scope, m.GetName())
+		if m.GetOptions().GetMapEntry() {
+			c.mapEntries[name] = m
+			continue
+		}
+		if _, ok := c.types[name]; ok {
+			return fmt.Errorf("%s: already declared", name)
+		}
+		n := parent.NewMessage()
+		if err := n.Label().Set(m.GetName()); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := n.InsertIntoParent(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		message := core.FindMessage(parent, m.GetName())
+		c.types[name] = message
+		if err := c.declare(message, name, m.NestedType, m.EnumType); err != nil {
+			return err
+		}
+	}
+	for _, e := range enums {
+		name := qualify(scope, e.GetName())
+		if _, ok := c.types[name]; ok {
+			return fmt.Errorf("%s: already declared", name)
+		}
+		n := parent.NewEnum()
+		if err := n.Label().Set(e.GetName()); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := n.InsertIntoParent(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.types[name] = core.FindEnum(parent, e.GetName())
+	}
+	return nil
+}
+
+func (c *Converter) message(scope string, m *descriptorpb.DescriptorProto) error {
+	name := qualify(scope, m.GetName())
+	if m.GetOptions().GetMapEntry() {
+		return nil
+	}
+	if err := c.messageFields(name, m); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, n := range m.NestedType {
+		if err := c.message(name, n); err != nil {
+			return err
+		}
+	}
+	for _, e := range m.EnumType {
+		nested := qualify(name, e.GetName())
+		if err := c.enum(c.types[nested].(core.Enum), e); err != nil {
+			return fmt.Errorf("%s: %w", nested, err)
+		}
+	}
+	return nil
+}
+
+func (c *Converter) messageFields(name string, m *descriptorpb.DescriptorProto) error {
+	message := c.types[name].(core.Message)
+	if err := fromOptions(m.Options, map[string]*core.Flag{"deprecated": message.Deprecated()}, message.Options().List()); err != nil {
+		return err
+	}
+	if len(m.Extension) > 0 || len(m.ExtensionRange) > 0 {
+		return fmt.Errorf("extensions are not supported")
+	}
+	oneOfs := make(map[int32]bool, len(m.OneofDecl))
+	for _, f := range m.Field {
+		if f.OneofIndex == nil {
+			if err := c.field(message, f); err != nil {
+				return fmt.Errorf("%s: %w", f.GetName(), err)
+			}
+			continue
+		}
+		index := f.GetOneofIndex()
+		if oneOfs[index] {
+			continue
+		}
+		oneOfs[index] = true
+		if int(index) >= len(m.OneofDecl) {
+			return fmt.Errorf("%s: invalid oneof index %d", f.GetName(), index)
+		}
+		o := m.OneofDecl[index]
+		if err := c.oneOf(message, index, o, m.Field); err != nil {
+			return fmt.Errorf("%s: %w", o.GetName(), err)
+		}
+	}
+	for _, r := range m.ReservedRange {
+		// message reserved ranges are exclusive
+		if err := reserve(message, r.GetStart(), r.GetEnd()-1); err != nil {
+			return err
+		}
+	}
+	for _, l := range m.ReservedName {
+		if err := reserveLabel(message, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Converter) field(m core.Message, f *descriptorpb.FieldDescriptorProto) error {
+	if err := checkField(f); err != nil {
+		return err
+	}
+	if entry, ok := c.mapEntries[strings.TrimPrefix(f.GetTypeName(), ".")]; ok {
+		return c._map(m, f, entry)
+	}
+	n := m.NewField()
+	if err := setField(n, f); err != nil {
+		return err
+	}
+	if err := c.setType(n.Type(), f); err != nil {
+		return err
+	}
+	if err := n.Repeated().Set(f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED); err != nil {
+		return err
+	}
+	if err := setFieldOptions(n, f); err != nil {
+		return err
+	}
+	return n.InsertIntoParent()
+}
+
+func (c *Converter) _map(m core.Message, f *descriptorpb.FieldDescriptorProto, entry *descriptorpb.DescriptorProto) error {
+	if len(entry.Field) != 2 {
+		return fmt.Errorf("invalid map entry %s", f.GetTypeName())
+	}
+	key, value := entry.Field[0], entry.Field[1]
+	if key.GetNumber() != 1 || value.GetNumber() != 2 {
+		key, value = value, key
+	}
+	n := m.NewMap()
+	if err := setField(n, f); err != nil {
+		return err
+	}
+	k, ok := scalarType(key.GetType()).(core.MapKeyType)
+	if !ok {
+		return fmt.Errorf("invalid map key type %s", key.GetType())
+	}
+	if err := n.KeyType().Set(k); err != nil {
+		return err
+	}
+	if err := c.setType(n.Type(), value); err != nil {
+		return err
+	}
+	if err := setFieldOptions(n, f); err != nil {
+		return err
+	}
+	return n.InsertIntoParent()
+}
+
+func (c *Converter) oneOf(m core.Message, index int32, o *descriptorpb.OneofDescriptorProto, fields []*descriptorpb.FieldDescriptorProto) error {
+	if err := fromOptions(o.Options, nil, nil); err != nil {
+		return err
+	}
+	n := m.NewOneOf()
+	if err := n.Label().Set(o.GetName()); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.OneofIndex == nil || f.GetOneofIndex() != index {
+			continue
+		}
+		if err := checkField(f); err != nil {
+			return fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+		if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			return fmt.Errorf("%s: repeated fields are not allowed in oneof", f.GetName())
+		}
+		v := n.NewField()
+		if err := setField(v, f); err != nil {
+			return fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+		if err := c.setType(v.Type(), f); err != nil {
+			return fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+		if err := setFieldOptions(v, f); err != nil {
+			return fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+		if err := v.InsertIntoParent(); err != nil {
+			return fmt.Errorf("%s: %w", f.GetName(), err)
+		}
+	}
+	return n.InsertIntoParent()
+}
+
+// checkField for features the core does not support
+func checkField(f *descriptorpb.FieldDescriptorProto) error {
+	switch {
+	case f.GetProto3Optional():
+		return fmt.Errorf("optional fields are not supported")
+	case f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED:
+		return fmt.Errorf("required fields are not supported in %s", syntax)
+	case f.Extendee != nil:
+		return fmt.Errorf("extensions are not supported")
+	case f.DefaultValue != nil:
+		return fmt.Errorf("default values are not supported in %s", syntax)
+	}
+	return nil
+}
+
+func setField(n numberedField, f *descriptorpb.FieldDescriptorProto) error {
+	if err := n.Label().Set(f.GetName()); err != nil {
+		return err
+	}
+	return n.Number().Set(f.GetNumber())
+}
+
+// setFieldOptions after type and label, which they are validated against
+func setFieldOptions(n numberedField, f *descriptorpb.FieldDescriptorProto) error {
+	// `protoc` always fills in the JSON name, so only keep custom ones
+	if f.JsonName != nil && f.GetJsonName() != core.DefaultJSONName(f.GetName()) {
+		if err := n.Options().JSONName().Set(f.GetJsonName()); err != nil {
+			return err
+		}
+	}
+	return fromOptions(f.Options, map[string]*core.Flag{"deprecated": n.Deprecated()}, n.Options().List())
+}
+
+func (c *Converter) setType(t *core.Type, f *descriptorpb.FieldDescriptorProto) error {
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		value, err := c.lookup(f.GetTypeName())
+		if err != nil {
+			return err
+		}
+		return t.Set(value)
+	case descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return fmt.Errorf("groups are not supported")
+	}
+	value := scalarType(f.GetType())
+	if value == nil {
+		return fmt.Errorf("unknown type %s", f.GetType())
+	}
+	return t.Set(value)
+}
+
+func (c *Converter) lookup(name string) (core.ValueType, error) {
+	if !strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("type name %q is not fully qualified", name)
+	}
+	value, ok := c.types[name[1:]]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	return value, nil
+}
+
+func scalarType(t descriptorpb.FieldDescriptorProto_Type) core.ValueType {
+	for k, v := range scalarTypes {
+		if v == t {
+			return k
+		}
+	}
+	return nil
+}
+
+func (c *Converter) enum(e core.Enum, d *descriptorpb.EnumDescriptorProto) error {
+	// aliasing must be allowed before inserting aliased variants
+	flags := map[string]*core.Flag{
+		"allow_alias": e.AllowAlias(),
+		"deprecated":  e.Deprecated(),
+	}
+	if err := fromOptions(d.Options, flags, nil); err != nil {
+		return err
+	}
+	for _, v := range d.Value {
+		n := e.NewVariant()
+		if err := n.Label().Set(v.GetName()); err != nil {
+			return fmt.Errorf("%s: %w", v.GetName(), err)
+		}
+		if err := n.Number().Set(v.GetNumber()); err != nil {
+			return fmt.Errorf("%s: %w", v.GetName(), err)
+		}
+		if err := fromOptions(v.Options, map[string]*core.Flag{"deprecated": n.Deprecated()}, nil); err != nil {
+			return fmt.Errorf("%s: %w", v.GetName(), err)
+		}
+		if err := n.InsertIntoParent(); err != nil {
+			return fmt.Errorf("%s: %w", v.GetName(), err)
+		}
+	}
+	for _, r := range d.ReservedRange {
+		// enum reserved ranges are inclusive
+		if err := reserve(e, r.GetStart(), r.GetEnd()); err != nil {
+			return err
+		}
+	}
+	for _, l := range d.ReservedName {
+		if err := reserveLabel(e, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserve an inclusive range of numbers
+func reserve(d core.Definition, start, end int32) error {
+	if start == end {
+		r := d.NewReservedNumber()
+		if err := r.Set(start); err != nil {
+			return fmt.Errorf("reserved %d: %w", start, err)
+		}
+		if err := r.InsertIntoParent(); err != nil {
+			return fmt.Errorf("reserved %d: %w", start, err)
+		}
+		return nil
+	}
+	r := d.NewReservedRange()
+	if err := r.Start().Set(start); err != nil {
+		return fmt.Errorf("reserved %d to %d: %w", start, end, err)
+	}
+	if err := r.End().Set(end); err != nil {
+		return fmt.Errorf("reserved %d to %d: %w", start, end, err)
+	}
+	if err := r.InsertIntoParent(); err != nil {
+		return fmt.Errorf("reserved %d to %d: %w", start, end, err)
+	}
+	return nil
+}
+
+func reserveLabel(d core.Definition, label string) error {
+	r := d.NewReservedLabel()
+	if err := r.Set(label); err != nil {
+		return fmt.Errorf("reserved %q: %w", label, err)
+	}
+	if err := r.InsertIntoParent(); err != nil {
+		return fmt.Errorf("reserved %q: %w", label, err)
+	}
+	return nil
+}
+
+func (c *Converter) service(d *core.Document, s *descriptorpb.ServiceDescriptorProto) error {
+	n := d.NewService()
+	if err := n.Label().Set(s.GetName()); err != nil {
+		return err
+	}
+	if err := fromOptions(s.Options, map[string]*core.Flag{"deprecated": n.Deprecated()}, nil); err != nil {
+		return err
+	}
+	if err := n.InsertIntoParent(); err != nil {
+		return err
+	}
+	for _, m := range s.Method {
+		if err := c.rpc(n, m); err != nil {
+			return fmt.Errorf("%s: %w", m.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (c *Converter) rpc(s *core.Service, m *descriptorpb.MethodDescriptorProto) error {
+	r := s.NewRPC()
+	if err := fromOptions(m.Options, map[string]*core.Flag{"deprecated": r.Deprecated()}, r.Options().List()); err != nil {
+		return err
+	}
+	if err := r.Label().Set(m.GetName()); err != nil {
+		return err
+	}
+	if err := c.setMessageType(r.Request(), m.GetInputType(), m.GetClientStreaming()); err != nil {
+		return err
+	}
+	if err := c.setMessageType(r.Response(), m.GetOutputType(), m.GetServerStreaming()); err != nil {
+		return err
+	}
+	return r.InsertIntoParent()
+}
+
+func (c *Converter) setMessageType(t *core.MessageType, name string, stream bool) error {
+	value, err := c.lookup(name)
+	if err != nil {
+		return err
+	}
+	m, ok := value.(core.Message)
+	if !ok {
+		return fmt.Errorf("%s is not a message", name)
+	}
+	if err := t.Set(m); err != nil {
+		return err
+	}
+	return t.Stream().Set(stream)
+}
@@ -0,0 +1,93 @@
+package descriptor
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+// options are mapped to descriptor fields by name, which is how both
+// `descriptor.proto` and the core identify them.
+
+// toOptions fills a descriptor options message from the options of an item.
+// `flags` are the options modelled as flags on the item itself. it reports
+// whether any option is set, since `protoc` leaves the message unset
+// otherwise.
+func toOptions(out proto.Message, flags map[string]bool, options []core.Option) bool {
+	m := out.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	set := false
+	for name, value := range flags {
+		if value {
+			m.Set(fields.ByName(protoreflect.Name(name)), protoreflect.ValueOfBool(true))
+			set = true
+		}
+	}
+	for _, o := range options {
+		if !o.IsSet() {
+			continue
+		}
+		f := fields.ByName(protoreflect.Name(o.Name()))
+		switch v := o.(type) {
+		case *core.BoolOption:
+			m.Set(f, protoreflect.ValueOfBool(*v.Get()))
+		case *core.StringOption:
+			m.Set(f, protoreflect.ValueOfString(*v.Get()))
+		case *core.EnumOption:
+			value := f.Enum().Values().ByName(protoreflect.Name(*v.Get()))
+			m.Set(f, protoreflect.ValueOfEnum(value.Number()))
+		default:
+			panic(fmt.Sprintf("unhandled option type %T", v))
+		}
+		set = true
+	}
+	return set
+}
+
+// fromOptions sets the options of an item from a descriptor options message,
+// and fails on any option the item does not support.
+func fromOptions(in proto.Message, flags map[string]*core.Flag, options []core.Option) (err error) {
+	m := in.ProtoReflect()
+	if !m.IsValid() {
+		return nil
+	}
+	m.Range(func(f protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(f.Name())
+		if flag, ok := flags[name]; ok {
+			err = flag.Set(v.Bool())
+			return err == nil
+		}
+		for _, o := range options {
+			if o.Name() == name {
+				err = setOption(o, f, v)
+				return err == nil
+			}
+		}
+		err = fmt.Errorf("unsupported option %s", name)
+		return false
+	})
+	if err == nil && len(m.GetUnknown()) > 0 {
+		err = fmt.Errorf("custom options are not supported")
+	}
+	return
+}
+
+func setOption(o core.Option, f protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch o := o.(type) {
+	case *core.BoolOption:
+		return o.Set(v.Bool())
+	case *core.StringOption:
+		return o.Set(v.String())
+	case *core.EnumOption:
+		value := f.Enum().Values().ByNumber(v.Enum())
+		if value == nil {
+			return fmt.Errorf("option %s: unknown value %d", o.Name(), v.Enum())
+		}
+		return o.Set(string(value.Name()))
+	default:
+		panic(fmt.Sprintf("unhandled option type %T", o))
+	}
+}
@@ -0,0 +1,264 @@
+package descriptor
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+// ToFileDescriptorProto converts a document to its descriptor. documents do
+// not know their own file name, so the caller has to set the `name` field.
+func ToFileDescriptorProto(d *core.Document) (*descriptorpb.FileDescriptorProto, error) {
+	f := &descriptorpb.FileDescriptorProto{
+		Syntax: proto.String(syntax),
+	}
+	if p := d.Package().Get(); p != "" {
+		f.Package = proto.String(p)
+	}
+	options := &descriptorpb.FileOptions{}
+	if toOptions(options, map[string]bool{"deprecated": d.Deprecated().Get()}, d.Options().List()) {
+		f.Options = options
+	}
+	for i, imp := range d.Imports() {
+		f.Dependency = append(f.Dependency, imp.Path().Get())
+		if imp.Public().Get() {
+			f.PublicDependency = append(f.PublicDependency, int32(i))
+		}
+	}
+	for _, m := range d.Messages() {
+		out, err := toMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		f.MessageType = append(f.MessageType, out)
+	}
+	for _, e := range d.Enums() {
+		f.EnumType = append(f.EnumType, toEnum(e))
+	}
+	for _, s := range d.Services() {
+		f.Service = append(f.Service, toService(s))
+	}
+	return f, nil
+}
+
+func toMessage(m core.Message) (*descriptorpb.DescriptorProto, error) {
+	out := &descriptorpb.DescriptorProto{
+		Name: proto.String(m.Label().Get()),
+	}
+	options := &descriptorpb.MessageOptions{}
+	if toOptions(options, map[string]bool{"deprecated": m.Deprecated().Get()}, m.Options().List()) {
+		out.Options = options
+	}
+	for _, n := range m.Messages() {
+		nested, err := toMessage(n)
+		if err != nil {
+			return nil, err
+		}
+		out.NestedType = append(out.NestedType, nested)
+	}
+	for _, e := range m.Enums() {
+		out.EnumType = append(out.EnumType, toEnum(e))
+	}
+	for _, field := range m.Fields() {
+		switch f := field.(type) {
+		case *core.Field:
+			out.Field = append(out.Field, toField(f, f.Type(), f.Repeated().Get()))
+		case *core.Map:
+			entry := toMapEntry(f)
+			for _, n := range out.NestedType {
+				if n.GetName() == entry.GetName() {
+					return nil, fmt.Errorf("%s: map entry %s conflicts with nested message of the same name", fullName(m), entry.GetName())
+				}
+			}
+			out.NestedType = append(out.NestedType, entry)
+			v := toField(f, f.Type(), true)
+			v.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			v.TypeName = proto.String(fmt.Sprint(".", qualify(fullName(m), entry.GetName())))
+			out.Field = append(out.Field, v)
+		case *core.OneOf:
+			index := proto.Int32(int32(len(out.OneofDecl)))
+			out.OneofDecl = append(out.OneofDecl, &descriptorpb.OneofDescriptorProto{
+				Name: proto.String(f.Label().Get()),
+			})
+			for _, o := range f.Fields() {
+				v := toField(o, o.Type(), false)
+				v.OneofIndex = index
+				out.Field = append(out.Field, v)
+			}
+		case *core.ReservedNumber:
+			out.ReservedRange = append(out.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+				Start: proto.Int32(*f.Get()),
+				// message reserved ranges are exclusive
+				End: proto.Int32(*f.Get() + 1),
+			})
+		case *core.ReservedRange:
+			out.ReservedRange = append(out.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+				Start: proto.Int32(*f.Start().Get()),
+				End:   proto.Int32(*f.End().Get() + 1),
+			})
+		case *core.ReservedLabel:
+			out.ReservedName = append(out.ReservedName, f.Get())
+		default:
+			panic(fmt.Sprintf("unhandled message field type %T", f))
+		}
+	}
+	return out, nil
+}
+
+// numberedField is implemented by all fields with a label and a number
+type numberedField interface {
+	Label() *core.Label
+	Number() *core.Number
+	Deprecated() *core.Flag
+	Options() *core.FieldOptions
+}
+
+func toField(f numberedField, t *core.Type, repeated bool) *descriptorpb.FieldDescriptorProto {
+	out := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(f.Label().Get()),
+		Number:   proto.Int32(*f.Number().Get()),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(core.DefaultJSONName(f.Label().Get())),
+	}
+	if repeated {
+		out.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	setType(out, t.Get())
+	// `json_name` is declared like an option, but is not part of
+	// `FieldOptions`
+	var typed []core.Option
+	for _, o := range f.Options().List() {
+		if o != f.Options().JSONName() {
+			typed = append(typed, o)
+		}
+	}
+	if name := f.Options().JSONName().Get(); name != nil {
+		out.JsonName = proto.String(*name)
+	}
+	options := &descriptorpb.FieldOptions{}
+	if toOptions(options, map[string]bool{"deprecated": f.Deprecated().Get()}, typed) {
+		out.Options = options
+	}
+	return out
+}
+
+func setType(f *descriptorpb.FieldDescriptorProto, t core.ValueType) {
+	switch v := t.(type) {
+	case core.Message:
+		f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		f.TypeName = proto.String(fmt.Sprint(".", fullName(v)))
+	case core.Enum:
+		f.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		f.TypeName = proto.String(fmt.Sprint(".", fullName(v)))
+	default:
+		f.Type = scalarTypes[v].Enum()
+	}
+}
+
+// toMapEntry synthesizes the nested message type `protoc` generates for map
+// fields
+func toMapEntry(m *core.Map) *descriptorpb.DescriptorProto {
+	key := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("key"),
+		Number:   proto.Int32(1),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String("key"),
+	}
+	setType(key, m.KeyType().Get().(core.ValueType))
+	value := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String("value"),
+	}
+	setType(value, m.Type().Get())
+	return &descriptorpb.DescriptorProto{
+		Name:    proto.String(mapEntryName(m.Label().Get())),
+		Field:   []*descriptorpb.FieldDescriptorProto{key, value},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+}
+
+func toEnum(e core.Enum) *descriptorpb.EnumDescriptorProto {
+	out := &descriptorpb.EnumDescriptorProto{
+		Name: proto.String(e.Label().Get()),
+	}
+	aliased := false
+	for _, variants := range e.Aliases() {
+		if len(variants) > 1 {
+			aliased = true
+		}
+	}
+	// `protoc` rejects `allow_alias` without aliasing in place
+	flags := map[string]bool{
+		"allow_alias": e.AllowAlias().Get() && aliased,
+		"deprecated":  e.Deprecated().Get(),
+	}
+	options := &descriptorpb.EnumOptions{}
+	if toOptions(options, flags, nil) {
+		out.Options = options
+	}
+	for _, field := range e.Fields() {
+		switch f := field.(type) {
+		case *core.Variant:
+			v := &descriptorpb.EnumValueDescriptorProto{
+				Name:   proto.String(f.Label().Get()),
+				Number: proto.Int32(*f.Number().Get()),
+			}
+			options := &descriptorpb.EnumValueOptions{}
+			if toOptions(options, map[string]bool{"deprecated": f.Deprecated().Get()}, nil) {
+				v.Options = options
+			}
+			out.Value = append(out.Value, v)
+		case *core.ReservedNumber:
+			out.ReservedRange = append(out.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{
+				Start: proto.Int32(*f.Get()),
+				// enum reserved ranges are inclusive
+				End: proto.Int32(*f.Get()),
+			})
+		case *core.ReservedRange:
+			out.ReservedRange = append(out.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{
+				Start: proto.Int32(*f.Start().Get()),
+				End:   proto.Int32(*f.End().Get()),
+			})
+		case *core.ReservedLabel:
+			out.ReservedName = append(out.ReservedName, f.Get())
+		default:
+			panic(fmt.Sprintf("unhandled enum field type %T", f))
+		}
+	}
+	return out
+}
+
+func toService(s *core.Service) *descriptorpb.ServiceDescriptorProto {
+	out := &descriptorpb.ServiceDescriptorProto{
+		Name: proto.String(s.Label().Get()),
+	}
+	options := &descriptorpb.ServiceOptions{}
+	if toOptions(options, map[string]bool{"deprecated": s.Deprecated().Get()}, nil) {
+		out.Options = options
+	}
+	for _, r := range s.RPCs() {
+		m := &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(r.Label().Get()),
+			InputType:  proto.String(fmt.Sprint(".", fullName(r.Request().Get()))),
+			OutputType: proto.String(fmt.Sprint(".", fullName(r.Response().Get()))),
+		}
+		// like `protoc`, only set stream flags if enabled
+		if r.Request().Stream().Get() {
+			m.ClientStreaming = proto.Bool(true)
+		}
+		if r.Response().Stream().Get() {
+			m.ServerStreaming = proto.Bool(true)
+		}
+		options := &descriptorpb.MethodOptions{}
+		if toOptions(options, map[string]bool{"deprecated": r.Deprecated().Get()}, r.Options().List()) {
+			m.Options = options
+		}
+		out.Method = append(out.Method, m)
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package editor
+
+import (
+	"errors"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+var errCannotAdd = errors.New("cannot add an item here")
+
+// addSibling of the item at the cursor, at an offset from its position
+func (e *Editor) addSibling(offset int) error {
+	item := e.cursor.Item()
+	positioned, ok := item.(interface{ Position() *core.Position })
+	if !ok {
+		return errCannotAdd
+	}
+	index := positioned.Position().Get() + offset
+	var tentative interface{}
+	switch v := item.(type) {
+	case core.Message:
+		tentative = e.addMessage(v.Parent(), index)
+	case core.Enum:
+		tentative = e.addEnum(v.Parent(), index)
+	case *core.Field:
+		tentative = e.addField(v.Parent(), index)
+	case *core.Map:
+		tentative = e.addField(v.Parent(), index)
+	case *core.OneOf:
+		tentative = e.addField(v.Parent(), index)
+	case *core.OneOfField:
+		tentative = e.addOneOfField(v.Parent(), index)
+	case *core.Variant:
+		tentative = e.addVariant(v.Parent(), index)
+	case *core.ReservedNumber:
+		tentative = e.addReservedSibling(v.Parent(), index)
+	case *core.ReservedRange:
+		tentative = e.addReservedSibling(v.Parent(), index)
+	case *core.ReservedLabel:
+		tentative = e.addReservedSibling(v.Parent(), index)
+	}
+	if tentative == nil {
+		return errCannotAdd
+	}
+	e.tentative, e.placement = tentative, Above
+	if offset > 0 {
+		e.placement = Below
+	}
+	return nil
+}
+
+// addReservedSibling adds a field or a variant next to a reservation
+func (e *Editor) addReservedSibling(d core.Definition, index int) interface{} {
+	switch p := d.(type) {
+	case core.Message:
+		return e.addField(p, index)
+	case core.Enum:
+		return e.addVariant(p, index)
+	default:
+		return nil
+	}
+}
+
+// addChild as the last item in the one at the cursor
+func (e *Editor) addChild() error {
+	var tentative interface{}
+	switch v := e.cursor.Item().(type) {
+	case *core.Document:
+		tentative = e.addMessage(v, len(v.Messages()))
+	case core.Message:
+		tentative = e.addField(v, len(v.Fields()))
+	case *core.OneOf:
+		tentative = e.addOneOfField(v, len(v.Fields()))
+	case core.Enum:
+		tentative = e.addVariant(v, len(v.Fields()))
+	default:
+		return errCannotAdd
+	}
+	e.tentative, e.placement = tentative, Inside
+	return nil
+}
+
+// label, then continue
+func (e *Editor) label(l *core.Label, then func() error) {
+	e.read("label", func(text string) error {
+		if err := l.Set(text); err != nil {
+			return err
+		}
+		return then()
+	})
+}
+
+// number, then continue
+func (e *Editor) number(n *core.Number, then func() error) {
+	e.read("number", func(text string) error {
+		value, err := parseNumber(text)
+		if err != nil {
+			return err
+		}
+		if err := n.Set(value); err != nil {
+			return err
+		}
+		return then()
+	})
+}
+
+func (e *Editor) addField(m core.Message, index int) interface{} {
+	f := m.NewField()
+	e.label(f.Label(), func() error {
+		e.number(f.Number(), func() error {
+			e.pickType(f.Type(), func() error {
+				if err := f.InsertIntoParentAt(index); err != nil {
+					return err
+				}
+				return e.cursor.MoveTo(f)
+			})
+			return nil
+		})
+		return nil
+	})
+	return f
+}
+
+func (e *Editor) addOneOfField(o *core.OneOf, index int) interface{} {
+	f := o.NewField()
+	e.label(f.Label(), func() error {
+		e.number(f.Number(), func() error {
+			e.pickType(f.Type(), func() error {
+				if err := f.InsertIntoParentAt(index); err != nil {
+					return err
+				}
+				return e.cursor.MoveTo(f)
+			})
+			return nil
+		})
+		return nil
+	})
+	return f
+}
+
+func (e *Editor) addVariant(en core.Enum, index int) interface{} {
+	v := en.NewVariant()
+	e.label(v.Label(), func() error {
+		e.number(v.Number(), func() error {
+			if err := v.InsertIntoParentAt(index); err != nil {
+				return err
+			}
+			return e.cursor.MoveTo(v)
+		})
+		return nil
+	})
+	return v
+}
+
+// addMessage to a container. inserting copies the new message, so the cursor
+// moves to the copy.
+func (e *Editor) addMessage(c core.DefinitionContainer, index int) interface{} {
+	m := c.NewMessage()
+	e.label(m.Label(), func() error {
+		if err := m.InsertIntoParentAt(index); err != nil {
+			return err
+		}
+		return e.cursor.MoveTo(c.Messages()[index])
+	})
+	return m
+}
+
+func (e *Editor) addEnum(c core.DefinitionContainer, index int) interface{} {
+	en := c.NewEnum()
+	e.label(en.Label(), func() error {
+		if err := en.InsertIntoParentAt(index); err != nil {
+			return err
+		}
+		return e.cursor.MoveTo(c.Enums()[index])
+	})
+	return en
+}
@@ -0,0 +1,404 @@
+// Package editor implements modal keyboard control over documents, without
+// presenting them. a front end feeds it keys and renders the document, the
+// cursor, and the pending input or choices.
+//
+// all edits go through the validating setters and insertion methods of the
+// core package, so keys can never produce an invalid document. an edit which
+// fails validation returns its error and leaves the editor in the mode it was
+// in, such that the input can be corrected or cancelled with `<Esc>`.
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+// Mode of the editor, which determines what keys do
+type Mode int
+
+const (
+	// Normal mode moves the cursor and starts edits
+	Normal Mode = iota
+	// Insert mode reads text, such as a label or a number
+	Insert
+	// Select mode picks one of several choices, such as a type
+	Select
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Normal:
+		return "normal"
+	case Insert:
+		return "insert"
+	case Select:
+		return "select"
+	default:
+		panic(fmt.Sprintf("unhandled mode %d", int(m)))
+	}
+}
+
+// Editor of a document. in normal mode, these keys are bound:
+//
+//	h  parent            j  next sibling      k  previous sibling
+//	l  first child       g  go to definition
+//	o  add below         O  add above         a  add as last child
+//	r  rename            n  set number        t  set type
+//	R  toggle repeated   D  toggle deprecated x  remove
+//	u  undo              U  redo
+//
+// in insert mode, `<Enter>` applies the text typed, `<BS>` deletes the last
+// character, and `<Esc>` cancels. in select mode, `j` and `k` move through the
+// choices, `<Enter>` applies the selected one, and `<Esc>` cancels.
+//
+// adding an item asks for its label, then its number and type where
+// applicable, and inserts it once all of them are set. cancelling at any step
+// discards the item.
+type Editor struct {
+	cursor    *core.Cursor
+	mode      Mode
+	input     *input
+	choice    *choice
+	tentative interface{}
+	placement Placement
+}
+
+// Placement of a tentative item relative to the item at the cursor
+type Placement int
+
+const (
+	Below Placement = iota
+	Above
+	Inside
+)
+
+// input read in insert mode
+type input struct {
+	prompt string
+	text   []rune
+	apply  func(string) error
+}
+
+// choice made in select mode
+type choice struct {
+	prompt   string
+	names    []string
+	values   []interface{}
+	selected int
+	apply    func(interface{}) error
+}
+
+func New(d *core.Document) *Editor {
+	return &Editor{cursor: d.Cursor()}
+}
+
+// Document being edited, which is the one the cursor is in
+func (e *Editor) Document() *core.Document {
+	return e.cursor.Document()
+}
+
+func (e *Editor) Cursor() *core.Cursor {
+	return e.cursor
+}
+
+func (e *Editor) Mode() Mode {
+	return e.mode
+}
+
+// Prompt describing the pending input or choice
+func (e *Editor) Prompt() string {
+	switch e.mode {
+	case Insert:
+		return e.input.prompt
+	case Select:
+		return e.choice.prompt
+	default:
+		return ""
+	}
+}
+
+// Input typed so far in insert mode
+func (e *Editor) Input() string {
+	if e.mode != Insert {
+		return ""
+	}
+	return string(e.input.text)
+}
+
+// Choices offered in select mode, and the index of the selected one
+func (e *Editor) Choices() ([]string, int) {
+	if e.mode != Select {
+		return nil, -1
+	}
+	out := make([]string, len(e.choice.names))
+	copy(out, e.choice.names)
+	return out, e.choice.selected
+}
+
+// Tentative item being added, if any, such as a `*core.Field` or a
+// `*core.NewMessage`, and where it will be inserted
+func (e *Editor) Tentative() (interface{}, Placement) {
+	return e.tentative, e.placement
+}
+
+// Run a script of keys, as parsed by `ParseKeys`, stopping at the first error
+func (e *Editor) Run(script string) error {
+	keys, err := ParseKeys(script)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := e.Press(k); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// Press a key
+func (e *Editor) Press(k Key) error {
+	switch e.mode {
+	case Insert:
+		return e.insertKey(k)
+	case Select:
+		return e.selectKey(k)
+	default:
+		return e.normalKey(k)
+	}
+}
+
+var errNoMove = errors.New("cannot move there")
+
+func (e *Editor) normalKey(k Key) error {
+	c := e.cursor
+	switch k {
+	case 'h':
+		return moved(c.Parent())
+	case 'j':
+		return moved(c.NextSibling())
+	case 'k':
+		return moved(c.PrevSibling())
+	case 'l':
+		return moved(c.FirstChild())
+	case 'g':
+		return moved(c.Definition())
+	case 'o':
+		return e.addSibling(1)
+	case 'O':
+		return e.addSibling(0)
+	case 'a':
+		return e.addChild()
+	case 'r':
+		return e.rename()
+	case 'n':
+		return e.renumber()
+	case 't':
+		return e.retype()
+	case 'R':
+		f, ok := c.Item().(*core.Field)
+		if !ok {
+			return errors.New("only fields can be repeated")
+		}
+		return f.Repeated().Set(!f.Repeated().Get())
+	case 'D':
+		d, ok := c.Item().(interface{ Deprecated() *core.Flag })
+		if !ok {
+			return errors.New("cannot be deprecated")
+		}
+		return d.Deprecated().Set(!d.Deprecated().Get())
+	case 'x':
+		r, ok := c.Item().(interface{ Remove() error })
+		if !ok {
+			return errors.New("cannot be removed")
+		}
+		return r.Remove()
+	case 'u':
+		return c.Document().Undo()
+	case 'U':
+		return c.Document().Redo()
+	default:
+		return fmt.Errorf("key %s is not bound", k)
+	}
+}
+
+func moved(ok bool) error {
+	if !ok {
+		return errNoMove
+	}
+	return nil
+}
+
+func (e *Editor) insertKey(k Key) error {
+	in := e.input
+	switch k {
+	case Escape:
+		e.cancel()
+	case Backspace:
+		if len(in.text) > 0 {
+			in.text = in.text[:len(in.text)-1]
+		}
+	case Enter:
+		e.normal()
+		if err := in.apply(string(in.text)); err != nil {
+			e.mode, e.input = Insert, in
+			return err
+		}
+		e.done()
+	default:
+		in.text = append(in.text, rune(k))
+	}
+	return nil
+}
+
+func (e *Editor) selectKey(k Key) error {
+	ch := e.choice
+	switch k {
+	case Escape:
+		e.cancel()
+	case 'j':
+		if ch.selected < len(ch.values)-1 {
+			ch.selected++
+		}
+	case 'k':
+		if ch.selected > 0 {
+			ch.selected--
+		}
+	case Enter:
+		e.normal()
+		if err := ch.apply(ch.values[ch.selected]); err != nil {
+			e.mode, e.choice = Select, ch
+			return err
+		}
+		e.done()
+	default:
+		return fmt.Errorf("key %s is not bound", k)
+	}
+	return nil
+}
+
+func (e *Editor) normal() {
+	e.mode = Normal
+	e.input = nil
+	e.choice = nil
+}
+
+// done with an edit, unless applying it asked for more input
+func (e *Editor) done() {
+	if e.mode == Normal {
+		e.tentative = nil
+	}
+}
+
+// cancel an edit, discarding the tentative item
+func (e *Editor) cancel() {
+	e.normal()
+	e.tentative = nil
+}
+
+// read text in insert mode, then apply it. applying may ask for more input.
+func (e *Editor) read(prompt string, apply func(string) error) {
+	e.mode = Insert
+	e.input = &input{prompt: prompt, apply: apply}
+}
+
+// pick one of several values in select mode, then apply it
+func (e *Editor) pick(prompt string, names []string, values []interface{}, apply func(interface{}) error) {
+	e.mode = Select
+	e.choice = &choice{prompt: prompt, names: names, values: values, apply: apply}
+}
+
+func parseNumber(text string) (int32, error) {
+	n, err := strconv.ParseInt(text, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return int32(n), nil
+}
+
+func (e *Editor) rename() error {
+	switch v := e.cursor.Item().(type) {
+	case *core.Package:
+		e.read("package", v.Set)
+	case *core.Import:
+		e.read("path", v.Path().Set)
+	case *core.ReservedLabel:
+		e.read("label", v.Set)
+	case interface{ Label() *core.Label }:
+		e.read("label", v.Label().Set)
+	default:
+		return errors.New("cannot be renamed")
+	}
+	return nil
+}
+
+func (e *Editor) renumber() error {
+	var set func(int32) error
+	switch v := e.cursor.Item().(type) {
+	case *core.ReservedNumber:
+		set = v.Set
+	case interface{ Number() *core.Number }:
+		set = v.Number().Set
+	default:
+		return errors.New("has no number")
+	}
+	e.read("number", func(text string) error {
+		n, err := parseNumber(text)
+		if err != nil {
+			return err
+		}
+		return set(n)
+	})
+	return nil
+}
+
+func (e *Editor) retype() error {
+	t, ok := e.cursor.Item().(interface{ Type() *core.Type })
+	if !ok {
+		return errors.New("has no type")
+	}
+	e.pickType(t.Type(), func() error { return nil })
+	return nil
+}
+
+// pickType for a field among the built-in types and the definitions visible
+// from the document, then continue
+func (e *Editor) pickType(t *core.Type, then func() error) {
+	names, values := types(t)
+	e.pick("type", names, values, func(v interface{}) error {
+		if err := t.Set(v.(core.ValueType)); err != nil {
+			return err
+		}
+		return then()
+	})
+	if current := t.Get(); current != nil {
+		for i, v := range values {
+			if v == current {
+				e.choice.selected = i
+			}
+		}
+	}
+}
+
+var builtins = []core.ValueType{
+	core.Double, core.Float, core.Int32, core.Int64, core.Uint32, core.Uint64,
+	core.Sint32, core.Sint64, core.Fixed32, core.Fixed64, core.Sfixed32,
+	core.Sfixed64, core.Bool, core.String, core.Bytes,
+}
+
+// types which can be picked for a field, named as they are printed in its
+// scope
+func types(t *core.Type) (names []string, values []interface{}) {
+	for _, b := range builtins {
+		names = append(names, fmt.Sprint(b))
+		values = append(values, b)
+	}
+	labels, definitions := t.Definitions()
+	for i, v := range definitions {
+		names = append(names, labels[i])
+		values = append(values, v)
+	}
+	return names, values
+}
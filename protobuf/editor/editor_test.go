@@ -0,0 +1,200 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+func parse(t *testing.T, input string) *core.Document {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	return d
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("ab<Enter><Esc><lt>ü<BS>")
+	require.Nil(t, err)
+	assert.Equal(t, []Key{'a', 'b', Enter, Escape, '<', 'ü', Backspace}, keys)
+	_, err = ParseKeys("<Foo>")
+	assert.NotNil(t, err)
+	_, err = ParseKeys("<Enter")
+	assert.NotNil(t, err)
+	_, err = ParseKeys("a\xff")
+	assert.NotNil(t, err, "invalid UTF-8")
+}
+
+func TestEditor(t *testing.T) {
+	d := parse(t, `syntax = "proto3";
+
+message A {
+  string foo = 1;
+}`)
+	e := New(d)
+	require.Nil(t, e.Run("llrbar<Enter>n2<Enter>RD"))
+	require.Nil(t, e.Run("obaz<Enter>3<Enter>"))
+	assert.Equal(t, Select, e.Mode())
+	choices, selected := e.Choices()
+	assert.Contains(t, choices, "A")
+	assert.Equal(t, "double", choices[selected])
+	require.Nil(t, e.Run("j<Enter>"))
+	assert.Equal(t, Normal, e.Mode())
+	assert.Equal(t, `syntax = "proto3";
+
+message A {
+  repeated string bar = 2 [deprecated=true];
+  float baz = 3;
+}`, d.String())
+
+	require.Nil(t, e.Run("t"))
+	choices, selected = e.Choices()
+	assert.Equal(t, "float", choices[selected], "current type is selected")
+	for choices[selected] != "A" {
+		require.Nil(t, e.Press('j'))
+		choices, selected = e.Choices()
+	}
+	require.Nil(t, e.Run("<Enter>kx"))
+	require.Nil(t, e.Run("hhatext<BS><BS><BS><BS>B<Enter>"))
+	assert.Equal(t, `syntax = "proto3";
+
+message A {
+  A baz = 3;
+}
+
+message B {}`, d.String())
+
+	require.Nil(t, e.Run("uuu"))
+	assert.Equal(t, `syntax = "proto3";
+
+message A {
+  repeated string bar = 2 [deprecated=true];
+  float baz = 3;
+}`, d.String())
+	require.Nil(t, e.Run("UUU"))
+	assert.Equal(t, "B", e.Cursor().Document().Messages()[1].Label().Get())
+}
+
+func TestEditorValidation(t *testing.T) {
+	d := parse(t, `syntax = "proto3";
+
+message A {
+  E foo = 1;
+}
+
+enum E {
+  NONE = 0;
+}`)
+	e := New(d)
+	require.Nil(t, e.Run("ljlr"))
+	assert.Equal(t, Insert, e.Mode())
+	assert.Equal(t, "label", e.Prompt())
+	assert.NotNil(t, e.Run("1foo<Enter>"), "invalid identifier")
+	assert.Equal(t, Insert, e.Mode(), "input can be corrected")
+	assert.Equal(t, "1foo", e.Input())
+	require.Nil(t, e.Run("<Esc>"))
+	assert.Equal(t, Normal, e.Mode())
+
+	require.Nil(t, e.Run("obar<Enter>"))
+	tentative, where := e.Tentative()
+	require.IsType(t, &core.Field{}, tentative)
+	assert.Equal(t, "bar", tentative.(*core.Field).Label().Get())
+	assert.Equal(t, Below, where)
+	assert.NotNil(t, e.Run("1<Enter>"), "number in use")
+	assert.NotNil(t, e.Run("<BS>x<Enter>"), "not a number")
+	require.Nil(t, e.Run("<BS>2<Enter><Esc>"))
+	assert.Len(t, core.FindMessage(d, "A").Fields(), 1, "cancelled items are discarded")
+	tentative, _ = e.Tentative()
+	assert.Nil(t, tentative)
+
+	require.Nil(t, e.Run("hklOSOME<Enter>"))
+	assert.NotNil(t, e.Run("1<Enter>"), "first variant must be 0")
+	require.Nil(t, e.Run("<Esc>"))
+	assert.NotNil(t, e.Run("hx"), "enum is referenced")
+	assert.NotNil(t, e.Run("hh"), "no parent")
+	assert.NotNil(t, e.Run("R"), "cannot repeat document")
+	assert.NotNil(t, e.Run("Q"), "not bound")
+}
+
+func TestEditorDefinition(t *testing.T) {
+	d := parse(t, `syntax = "proto3";
+
+enum E {
+  NONE = 0;
+}
+
+message A {
+  E e = 1;
+  oneof choice {
+    int32 i = 2;
+  }
+}`)
+	e := New(d)
+	require.Nil(t, e.Run("ljl"))
+	assert.Equal(t, core.FindMessage(d, "A").Fields()[0], e.Cursor().Item())
+	require.Nil(t, e.Run("g"))
+	assert.Equal(t, core.FindEnum(d, "E"), e.Cursor().Item())
+	require.Nil(t, e.Run("aNEXT<Enter>1<Enter>"))
+	require.Nil(t, e.Run("hjljlostr<Enter>3<Enter>"))
+	choices, _ := e.Choices()
+	for i, c := range choices {
+		if c == "string" {
+			require.Nil(t, e.Run(strings.Repeat("j", i)+"<Enter>"))
+		}
+	}
+	assert.Equal(t, `syntax = "proto3";
+
+enum E {
+  NONE = 0;
+  NEXT = 1;
+}
+
+message A {
+  E e = 1;
+  oneof choice {
+    int32 i = 2;
+    string str = 3;
+  }
+}`, d.String())
+}
+
+func TestEditorImportedType(t *testing.T) {
+	w := core.NewWorkspace()
+	_, err := w.Parse("a.proto", strings.NewReader(`syntax = "proto3";
+package a;
+message A {
+  message N {}
+}
+enum E {
+  NONE = 0;
+}`))
+	require.Nil(t, err)
+	_, err = w.Parse("c.proto", strings.NewReader(`syntax = "proto3";
+message C {}`))
+	require.Nil(t, err)
+	d, err := w.Parse("b.proto", strings.NewReader(`syntax = "proto3";
+package b;
+import "a.proto";
+message B {
+  string s = 1;
+  message N {}
+}`))
+	require.Nil(t, err)
+	e := New(d)
+	require.Nil(t, e.Run("ljjl"))
+	assert.Equal(t, core.FindMessage(d, "B").Fields()[0], e.Cursor().Item())
+	require.Nil(t, e.Run("t"))
+	choices, _ := e.Choices()
+	assert.Equal(t, []string{"B", "N", "a.A", "a.A.N", "a.E"}, choices[len(choices)-5:],
+		"names are relative to the scope of the field")
+	assert.NotContains(t, choices, "C", "c.proto is not imported")
+	for i, c := range choices {
+		if c == "a.E" {
+			require.Nil(t, e.Run(strings.Repeat("j", i)+"<Enter>"))
+		}
+	}
+	assert.Contains(t, d.String(), "a.E s = 1;")
+}
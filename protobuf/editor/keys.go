@@ -0,0 +1,65 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key pressed by the user. printable keys are their character, special keys
+// are the corresponding control characters.
+type Key rune
+
+const (
+	Tab       Key = '\t'
+	Enter     Key = '\r'
+	Escape    Key = 0x1b
+	Backspace Key = 0x7f
+)
+
+var keyNames = map[string]Key{
+	"Tab":   Tab,
+	"Enter": Enter,
+	"CR":    Enter,
+	"Esc":   Escape,
+	"BS":    Backspace,
+	"lt":    '<',
+}
+
+func (k Key) String() string {
+	for name, key := range keyNames {
+		if key == k && name != "CR" {
+			return fmt.Sprintf("<%s>", name)
+		}
+	}
+	return string(k)
+}
+
+// ParseKeys from a script in the notation of Vim mappings, where special keys
+// are written as `<Enter>`, `<Esc>`, `<BS>` and `<Tab>`, and `<lt>` stands
+// for `<`.
+func ParseKeys(script string) ([]Key, error) {
+	var out []Key
+	for len(script) > 0 {
+		if script[0] == '<' {
+			end := strings.IndexByte(script, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key name in %q", script)
+			}
+			k, ok := keyNames[script[1:end]]
+			if !ok {
+				return nil, fmt.Errorf("unknown key %s", script[:end+1])
+			}
+			out = append(out, k)
+			script = script[end+1:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(script)
+		if r == utf8.RuneError && size == 1 {
+			return nil, fmt.Errorf("invalid UTF-8 in %q", script)
+		}
+		out = append(out, Key(r))
+		script = script[size:]
+	}
+	return out, nil
+}
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/descriptor"
+)
+
+// Error reported by a plugin in its response
+type Error struct {
+	Plugin  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Plugin, e.Message)
+}
+
+// NewRequest builds a request to generate code for the documents at the named
+// paths of a workspace. if no paths are named, code is generated for all
+// documents.
+func NewRequest(w *core.Workspace, parameter string, generate ...string) (*pluginpb.CodeGeneratorRequest, error) {
+	req := &pluginpb.CodeGeneratorRequest{}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	if len(generate) == 0 {
+		for _, d := range w.Documents() {
+			generate = append(generate, d.Path())
+		}
+	}
+	for _, name := range generate {
+		if w.Document(name) == nil {
+			return nil, fmt.Errorf("%s: file not found", name)
+		}
+		req.FileToGenerate = append(req.FileToGenerate, name)
+	}
+	for _, d := range dependencyOrder(w) {
+		f, err := descriptor.ToFileDescriptorProto(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Path(), err)
+		}
+		f.Name = proto.String(d.Path())
+		req.ProtoFile = append(req.ProtoFile, f)
+	}
+	return req, nil
+}
+
+// dependencyOrder of documents, such that each document comes after all
+// documents it imports, as plugins expect it. a workspace cannot contain
+// import cycles or missing imports.
+func dependencyOrder(w *core.Workspace) []*core.Document {
+	var out []*core.Document
+	done := make(map[*core.Document]bool)
+	var visit func(*core.Document)
+	visit = func(d *core.Document) {
+		if done[d] {
+			return
+		}
+		done[d] = true
+		for _, i := range d.Imports() {
+			visit(w.Document(i.Path().Get()))
+		}
+		out = append(out, d)
+	}
+	// keep output stable, as documents are ordered by path
+	for _, d := range w.Documents() {
+		visit(d)
+	}
+	return out
+}
+
+// Run a plugin executable, passing the request via stdin and reading the
+// response from stdout. an error reported in the response is returned as
+// `*Error`.
+func Run(executable string, req *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorResponse, error) {
+	in, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(executable)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("%s: %w: %s", executable, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil, fmt.Errorf("%s: %w", executable, err)
+	}
+	res := &pluginpb.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(stdout.Bytes(), res); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %w", executable, err)
+	}
+	if res.Error != nil {
+		return res, &Error{Plugin: executable, Message: res.GetError()}
+	}
+	return res, nil
+}
+
+// WriteFiles of a response to a directory
+func WriteFiles(dir string, res *pluginpb.CodeGeneratorResponse) error {
+	for _, f := range res.File {
+		if err := writeFile(dir, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(dir string, f *pluginpb.CodeGeneratorResponse_File) error {
+	if f.GetInsertionPoint() != "" {
+		return fmt.Errorf("%s: insertion points are not supported", f.GetName())
+	}
+	name := filepath.FromSlash(f.GetName())
+	if name == "" || filepath.IsAbs(name) || name != filepath.Clean(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid output file name %q", f.GetName())
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(f.GetContent()), 0644)
+}
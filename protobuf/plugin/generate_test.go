@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+)
+
+// the test binary doubles as a plugin, which writes one file per file to
+// generate, or fails on request
+func TestMain(m *testing.M) {
+	if os.Getenv("STRED_TEST_PLUGIN") != "" {
+		testPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func testPlugin() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		panic(err)
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		panic(err)
+	}
+	res := &pluginpb.CodeGeneratorResponse{}
+	if req.GetParameter() == "fail" {
+		res.Error = proto.String("failed on request")
+	}
+	for _, f := range req.FileToGenerate {
+		res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(strings.TrimSuffix(f, ".proto") + ".txt"),
+			Content: proto.String(f),
+		})
+	}
+	out, err := proto.Marshal(res)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout.Write(out)
+}
+
+func parse(t *testing.T, w *core.Workspace, path, input string) {
+	_, err := w.Parse(path, strings.NewReader(input))
+	require.Nil(t, err)
+}
+
+func TestNewRequest(t *testing.T) {
+	w := core.NewWorkspace()
+	parse(t, w, "dir/a.proto", `syntax = "proto3"; message A {}`)
+	parse(t, w, "b.proto", `syntax = "proto3"; import "dir/a.proto";`)
+	parse(t, w, "c.proto", `syntax = "proto3"; import "dir/a.proto"; import "b.proto";`)
+	req, err := NewRequest(w, "foo=bar", "c.proto")
+	require.Nil(t, err)
+	assert.Equal(t, "foo=bar", req.GetParameter())
+	assert.Equal(t, []string{"c.proto"}, req.FileToGenerate)
+	names := make([]string, len(req.ProtoFile))
+	for i, f := range req.ProtoFile {
+		names[i] = f.GetName()
+	}
+	assert.Equal(t, []string{"dir/a.proto", "b.proto", "c.proto"}, names)
+
+	_, err = NewRequest(w, "", "missing.proto")
+	assert.NotNil(t, err)
+}
+
+func TestRun(t *testing.T) {
+	os.Setenv("STRED_TEST_PLUGIN", "1")
+	defer os.Unsetenv("STRED_TEST_PLUGIN")
+	w := core.NewWorkspace()
+	parse(t, w, "dir/a.proto", `syntax = "proto3"; message A {}`)
+	req, err := NewRequest(w, "")
+	require.Nil(t, err)
+	res, err := Run(os.Args[0], req)
+	require.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "stred")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, WriteFiles(dir, res))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "dir", "a.txt"))
+	require.Nil(t, err)
+	assert.Equal(t, "dir/a.proto", string(content))
+
+	req.Parameter = proto.String("fail")
+	_, err = Run(os.Args[0], req)
+	var pluginError *Error
+	require.True(t, errors.As(err, &pluginError))
+	assert.Equal(t, "failed on request", pluginError.Message)
+}
+
+func TestWriteFilesOutsideDirectory(t *testing.T) {
+	res := &pluginpb.CodeGeneratorResponse{
+		File: []*pluginpb.CodeGeneratorResponse_File{{
+			Name:    proto.String("../escape.txt"),
+			Content: proto.String(""),
+		}},
+	}
+	assert.NotNil(t, WriteFiles(os.TempDir(), res))
+}
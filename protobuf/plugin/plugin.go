@@ -0,0 +1,59 @@
+// Package plugin connects documents to the `protoc` plugin protocol, which
+// exchanges `CodeGeneratorRequest` and `CodeGeneratorResponse` messages.
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/descriptor"
+)
+
+// ImportError collects the errors for all files in a request which could not
+// be converted to documents.
+type ImportError []error
+
+func (e ImportError) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Import all files of a request, as passed by `protoc` to a plugin, into
+// documents of a new workspace, keyed by file name.
+// conversion continues after errors, so that all files `protoc` accepted but
+// the core rejects are reported at once. files depending on a rejected file
+// are skipped.
+func Import(req *pluginpb.CodeGeneratorRequest) (*core.Workspace, error) {
+	w := core.NewWorkspace()
+	c := descriptor.NewConverter(w)
+	failed := make(map[string]bool)
+	var errs ImportError
+files:
+	for _, f := range req.ProtoFile {
+		for _, dep := range f.Dependency {
+			if failed[dep] {
+				failed[f.GetName()] = true
+				continue files
+			}
+			if w.Document(dep) == nil {
+				failed[f.GetName()] = true
+				errs = append(errs, fmt.Errorf("%s: dependency %s not found", f.GetName(), dep))
+				continue files
+			}
+		}
+		if _, err := c.Convert(f); err != nil {
+			failed[f.GetName()] = true
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return w, errs
+	}
+	return w, nil
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/fricklerhandwerk/stred-proto/protobuf/core"
+	"github.com/fricklerhandwerk/stred-proto/protobuf/descriptor"
+)
+
+func file(t *testing.T, name, input string) *descriptorpb.FileDescriptorProto {
+	d, err := core.Parse(strings.NewReader(input))
+	require.Nil(t, err)
+	f, err := descriptor.ToFileDescriptorProto(d)
+	require.Nil(t, err)
+	f.Name = proto.String(name)
+	return f
+}
+
+func TestImport(t *testing.T) {
+	a := file(t, "a.proto", `syntax = "proto3"; message A {}`)
+	b := file(t, "b.proto", `syntax = "proto3"; message B {}`)
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto", "b.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{a, b},
+	}
+	w, err := Import(req)
+	require.Nil(t, err)
+	assert.Len(t, w.Documents(), 2)
+}
+
+func TestImportAcrossFiles(t *testing.T) {
+	a := file(t, "a.proto", `syntax = "proto3"; package a; message A {}`)
+	b := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("b.proto"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"a.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("B"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("a"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".a.A"),
+			}},
+		}},
+	}
+	w, err := Import(&pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{a, b},
+	})
+	require.Nil(t, err)
+	field := w.Document("b.proto").Messages()[0].Fields()[0].(*core.Field)
+	assert.Equal(t, w.Document("a.proto"), field.Type().Get().(core.Message).Document())
+}
+
+func TestImportReportsAllErrors(t *testing.T) {
+	a := file(t, "a.proto", `syntax = "proto3"; message A {}`)
+	// something `protoc` accepts, but the core does not
+	a.MessageType[0].Field = []*descriptorpb.FieldDescriptorProto{{
+		Name:   proto.String("_a"),
+		Number: proto.Int32(1),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+	}}
+	b := file(t, "b.proto", `syntax = "proto3"; message B {}`)
+	b.Syntax = proto.String("proto2")
+	c := file(t, "c.proto", `syntax = "proto3"; import "a.proto"; message C {}`)
+	d := file(t, "d.proto", `syntax = "proto3"; message D {}`)
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{a, b, c, d},
+	}
+	w, err := Import(req)
+	var importError ImportError
+	require.True(t, errors.As(err, &importError))
+	assert.Len(t, importError, 2)
+	assert.Contains(t, err.Error(), "a.proto")
+	assert.Contains(t, err.Error(), "b.proto")
+	require.Len(t, w.Documents(), 1)
+	assert.Equal(t, "d.proto", w.Documents()[0].Path())
+}